@@ -9,10 +9,13 @@ License: NA
 Source0: %{name}.tar.gz
 
 BuildRequires: golang > 1.21
+BuildRequires: systemd-rpm-macros
 
 %description
 qt-enaclave device plugin gives your pods and containers the ability to access the qtbox_service0.
 qt-enaclave-export collects qt vm cpu and memory usage, and sends to k8s through prometheus metrics interface.
+qt-enclave-webhook wires pods up for enclaves and enforces the enclave policy through kubernetes admission webhooks.
+qt-enclave-scheduler-extender filters and scores nodes for the kubernetes scheduler by their live enclave load.
 
 %prep
 cp %{SOURCE0} .
@@ -26,16 +29,30 @@ cd %_topdir/BUILD/%{name}/qt-enclave/qt-enclave-device-plugin
 make
 cd %_topdir/BUILD/%{name}/qt-enclave/qt-enclave-exporter
 make
+cd %_topdir/BUILD/%{name}/qt-enclave/qt-enclave-webhook
+make
+cd %_topdir/BUILD/%{name}/qt-enclave/qt-enclave-scheduler-extender
+make
 
 %install
 install -d %{buildroot}%{_bindir}
 # install binary
 install -p -m 550 %_topdir/BUILD/%{name}/qt-enclave/qt-enclave-exporter/qt-enclave-exporter %{buildroot}%{_bindir}/qt-enclave-exporter
 install -p -m 550 %_topdir/BUILD/%{name}/qt-enclave/qt-enclave-device-plugin/qt-enclave-k8s-device-plugin %{buildroot}%{_bindir}/qt-enclave-k8s-device-plugin
+install -p -m 550 %_topdir/BUILD/%{name}/qt-enclave/qt-enclave-webhook/qt-enclave-webhook %{buildroot}%{_bindir}/qt-enclave-webhook
+install -p -m 550 %_topdir/BUILD/%{name}/qt-enclave/qt-enclave-scheduler-extender/qt-enclave-scheduler-extender %{buildroot}%{_bindir}/qt-enclave-scheduler-extender
+# install systemd units
+install -d %{buildroot}%{_unitdir}
+install -p -m 644 %_topdir/BUILD/%{name}/qt-enclave/qt-enclave-exporter/qt-enclave-exporter.service %{buildroot}%{_unitdir}/qt-enclave-exporter.service
+install -p -m 644 %_topdir/BUILD/%{name}/qt-enclave/qt-enclave-device-plugin/qt-enclave-k8s-device-plugin.service %{buildroot}%{_unitdir}/qt-enclave-k8s-device-plugin.service
 
 %files
 %attr(0550,root,root) %{_bindir}/qt-enclave-exporter
 %attr(0550,root,root) %{_bindir}/qt-enclave-k8s-device-plugin
+%attr(0550,root,root) %{_bindir}/qt-enclave-webhook
+%attr(0550,root,root) %{_bindir}/qt-enclave-scheduler-extender
+%attr(0644,root,root) %{_unitdir}/qt-enclave-exporter.service
+%attr(0644,root,root) %{_unitdir}/qt-enclave-k8s-device-plugin.service
 %defattr(0640,root,root,0750)
 
 %changelog
@@ -0,0 +1,323 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide access control of the plugin sockets
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultSocketMode  = 0600
+	defaultAllowedUIDs = "0"
+
+	peerCredAuthType = "peercred"
+)
+
+// peerCredInfo carries the credentials of the process at the other end of
+// a unix socket.
+type peerCredInfo struct {
+	credentials.CommonAuthInfo
+	ucred *unix.Ucred
+}
+
+func (peerCredInfo) AuthType() string {
+	return peerCredAuthType
+}
+
+// peerCredentials reads SO_PEERCRED during the server handshake. It adds no
+// transport security, the socket is local.
+type peerCredentials struct{}
+
+func (peerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	info := peerCredInfo{CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}
+
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return conn, info, nil
+	}
+	ucred, err := peerUcred(uc)
+	if err != nil {
+		return nil, nil, err
+	}
+	info.ucred = ucred
+
+	return conn, info, nil
+}
+
+// peerUcred reads the SO_PEERCRED credentials of a unix connection.
+func peerUcred(conn *net.UnixConn) (*unix.Ucred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var ucred *unix.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		ucred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read peer credentials: %v", err)
+	}
+	return ucred, nil
+}
+
+func (peerCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, peerCredInfo{CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}, nil
+}
+
+func (peerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: peerCredAuthType}
+}
+
+func (c peerCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (peerCredentials) OverrideServerName(string) error {
+	return nil
+}
+
+// socketAccess restricts who can use a plugin socket: the socket file gets
+// a restrictive mode and only callers with an allowed UID get through.
+type socketAccess struct {
+	mode os.FileMode
+	// uids are the allowed caller UIDs, nil allows everybody.
+	uids map[uint32]bool
+	// helper creates the sockets and connects to kubelet when the daemon
+	// dropped its privileges.
+	helper *privHelper
+}
+
+func newSocketAccess(mode os.FileMode, uids []uint32) *socketAccess {
+	a := &socketAccess{mode: mode}
+	if uids != nil {
+		a.uids = map[uint32]bool{}
+		for _, uid := range uids {
+			a.uids[uid] = true
+		}
+	}
+	return a
+}
+
+// defaultSocketAccess allows root, which kubelet runs as, and the user of
+// the plugin itself.
+func defaultSocketAccess() *socketAccess {
+	return newSocketAccess(defaultSocketMode, []uint32{0, uint32(os.Getuid())})
+}
+
+// parseUIDs parses a comma separated list of UIDs. An empty list allows
+// everybody.
+func parseUIDs(list string) ([]uint32, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+
+	uids := []uint32{}
+	for _, item := range strings.Split(list, ",") {
+		uid, err := strconv.ParseUint(strings.TrimSpace(item), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UID %q: %v", item, err)
+		}
+		uids = append(uids, uint32(uid))
+	}
+	return uids, nil
+}
+
+// parseSocketMode parses an octal file mode such as 0600.
+func parseSocketMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode&^0777 != 0 {
+		return 0, fmt.Errorf("invalid socket mode %q, expected octal permissions such as 0600", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// listen creates the unix socket with the configured mode.
+func (a *socketAccess) listen(path string) (net.Listener, error) {
+	if a.helper != nil {
+		return a.helper.listen(path, a.mode)
+	}
+	return bindUnix(path, a.mode, nil)
+}
+
+// bindUnix creates the unix socket at path with mode, owned by cred unless
+// nil. The socket is bound in a new 0700 directory next to path and renamed
+// into place once it has its mode and owner, so it is never reachable with
+// the mode given by the umask. The directory is hidden from the kubelet
+// plugin watcher by its leading dot. The socket is not removed on close.
+func bindUnix(path string, mode os.FileMode, cred *syscall.Credential) (*net.UnixListener, error) {
+	// Short names keep the temporary path within the limit of unix socket
+	// paths.
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "s")
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	lis.SetUnlinkOnClose(false)
+	err = os.Chmod(tmp, mode&os.ModePerm)
+	if err == nil && cred != nil {
+		err = os.Chown(tmp, int(cred.Uid), int(cred.Gid))
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return lis, nil
+}
+
+// remove removes a stale socket.
+func (a *socketAccess) remove(path string) error {
+	if a.helper != nil {
+		return a.helper.remove(path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// connect opens a plain connection to a unix socket, such as the handover
+// socket of the previous daemon.
+func (a *socketAccess) connect(path string, timeout time.Duration) (net.Conn, error) {
+	if a.helper == nil {
+		return net.DialTimeout("unix", path, timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return a.helper.dial(ctx, path)
+}
+
+// dial connects to a kubelet socket.
+func (a *socketAccess) dial(path string, timeout time.Duration) (*grpc.ClientConn, error) {
+	if a == nil || a.helper == nil {
+		return dial(path, timeout)
+	}
+	helper := a.helper
+	return grpc.Dial(path, grpc.WithInsecure(), grpc.WithBlock(),
+		grpc.WithTimeout(timeout),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			return helper.dial(ctx, addr)
+		}),
+	)
+}
+
+// serverOptions returns the gRPC options enforcing the allowed UIDs.
+func (a *socketAccess) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.Creds(peerCredentials{}),
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := a.authorize(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := a.authorize(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// authorize checks the peer credentials of a call.
+func (a *socketAccess) authorize(ctx context.Context, method string) error {
+	if a.uids == nil {
+		return nil
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		glog.Errorf("Rejected %s: no peer information", method)
+		return status.Error(codes.PermissionDenied, "unknown caller")
+	}
+	info, ok := p.AuthInfo.(peerCredInfo)
+	if !ok || info.ucred == nil {
+		glog.Errorf("Rejected %s: no peer credentials", method)
+		return status.Error(codes.PermissionDenied, "unknown caller")
+	}
+	if !a.uids[info.ucred.Uid] {
+		glog.Errorf("Rejected %s from pid %d uid %d gid %d, allowed UIDs: %v",
+			method, info.ucred.Pid, info.ucred.Uid, info.ucred.Gid, a.allowedUIDs())
+		return status.Errorf(codes.PermissionDenied, "uid %d is not allowed", info.ucred.Uid)
+	}
+	return nil
+}
+
+// allowsConn checks the peer credentials of a plain connection accepted on
+// a socket created by listen, the way authorize checks the gRPC calls.
+func (a *socketAccess) allowsConn(conn net.Conn, what string) bool {
+	if a.uids == nil {
+		return true
+	}
+
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		glog.Errorf("Rejected %s: not a unix connection", what)
+		return false
+	}
+	ucred, err := peerUcred(uc)
+	if err != nil {
+		glog.Errorf("Rejected %s: %v", what, err)
+		return false
+	}
+	if !a.uids[ucred.Uid] {
+		glog.Errorf("Rejected %s from pid %d uid %d gid %d, allowed UIDs: %v",
+			what, ucred.Pid, ucred.Uid, ucred.Gid, a.allowedUIDs())
+		return false
+	}
+	return true
+}
+
+func (a *socketAccess) allowedUIDs() []uint32 {
+	uids := make([]uint32, 0, len(a.uids))
+	for uid := range a.uids {
+		uids = append(uids, uid)
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	return uids
+}
@@ -0,0 +1,136 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide access control of the plugin sockets testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// serveWithAccess serves a test plugin on a socket restricted by access.
+func serveWithAccess(t *testing.T, access *socketAccess) pluginapi.DevicePluginClient {
+	sock := filepath.Join(t.TempDir(), "plugin.sock")
+	lis, err := access.listen(sock)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", sock, err)
+	}
+	info, err := os.Stat(sock)
+	if err != nil || info.Mode().Perm() != access.mode {
+		t.Fatalf("Expected socket mode %s, got %v: %v", access.mode, info, err)
+	}
+
+	server := grpc.NewServer(access.serverOptions()...)
+	pluginapi.RegisterDevicePluginServer(server, newTestAllocationPlugin())
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := dial(sock, devicePluginServerReadyTimeout)
+	if err != nil {
+		t.Fatalf("Failed to dial %s: %v", sock, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pluginapi.NewDevicePluginClient(conn)
+}
+
+func TestSocketAccessAllowsConfiguredUIDs(t *testing.T) {
+	client := serveWithAccess(t, newSocketAccess(0600, []uint32{uint32(os.Getuid())}))
+
+	if _, err := client.GetDevicePluginOptions(context.Background(), &pluginapi.Empty{}); err != nil {
+		t.Fatalf("Allowed caller rejected: %v", err)
+	}
+	stream, err := client.ListAndWatch(context.Background(), &pluginapi.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Allowed caller could not watch devices: %v", err)
+	}
+}
+
+func TestSocketAccessRejectsOtherUIDs(t *testing.T) {
+	client := serveWithAccess(t, newSocketAccess(0660, []uint32{uint32(os.Getuid()) + 1}))
+
+	_, err := client.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"}))
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected PermissionDenied for Allocate but got %v", err)
+	}
+	stream, err := client.ListAndWatch(context.Background(), &pluginapi.Empty{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected PermissionDenied for ListAndWatch but got %v", err)
+	}
+}
+
+func TestParseSocketAccessFlags(t *testing.T) {
+	if uids, err := parseUIDs("0, 1000"); err != nil || len(uids) != 2 || uids[1] != 1000 {
+		t.Fatalf("Unexpected UIDs %v: %v", uids, err)
+	}
+	if uids, err := parseUIDs(""); err != nil || uids != nil {
+		t.Fatalf("Empty UID list should allow everybody, got %v: %v", uids, err)
+	}
+	if _, err := parseUIDs("root"); err == nil {
+		t.Fatal("Non numeric UID should be rejected")
+	}
+
+	if mode, err := parseSocketMode("0660"); err != nil || mode != 0660 {
+		t.Fatalf("Unexpected socket mode %v: %v", mode, err)
+	}
+	for _, value := range []string{"rw", "1777", "0999"} {
+		if _, err := parseSocketMode(value); err == nil {
+			t.Fatalf("Socket mode %q should be rejected", value)
+		}
+	}
+}
+
+func TestBindUnix(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "plugin.sock")
+	if err := os.WriteFile(sock, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The socket replaces the file at its path and gets its mode before
+	// it is moved there.
+	lis, err := bindUnix(sock, 0600, nil)
+	if err != nil {
+		t.Fatalf("Failed to bind %s: %v", sock, err)
+	}
+	defer lis.Close()
+	info, err := os.Stat(sock)
+	if err != nil || info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected a socket with mode 0600, got %v: %v", info, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("Temporary directory left behind: %v", entries)
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("Failed to connect to %s: %v", sock, err)
+	}
+	conn.Close()
+}
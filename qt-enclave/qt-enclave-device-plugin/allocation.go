@@ -0,0 +1,276 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide allocation checks and device cordons
+ *********************************************************************************/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+const (
+	// doubleBookingTimeout bounds the PodResources call of Allocate.
+	doubleBookingTimeout = 2 * time.Second
+
+	// The double booking check of the devices allocated before: skipped,
+	// skipped with a warning when PodResources fails, or rejecting the
+	// request then.
+	doubleBookingOff        = "off"
+	doubleBookingFailOpen   = "fail-open"
+	doubleBookingFailClosed = "fail-closed"
+)
+
+func validateDoubleBookingCheck(check string) error {
+	switch check {
+	case doubleBookingOff, doubleBookingFailOpen, doubleBookingFailClosed:
+		return nil
+	}
+	return fmt.Errorf("invalid double booking check %q, expected off, fail-open or fail-closed", check)
+}
+
+// readCordonFile returns the device IDs listed in the cordon file, one per
+// line. Empty lines and lines starting with '#' are ignored, and a missing
+// file means no device is cordoned.
+func readCordonFile(file string) (map[string]bool, error) {
+	cordons := map[string]bool{}
+	if file == "" {
+		return cordons, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cordons, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cordons[line] = true
+	}
+
+	return cordons, scanner.Err()
+}
+
+// reloadCordons refreshes the admin cordons from the cordon file. On read
+// errors the previous cordons are kept.
+func (qtedp *QtEnclavesDevicePlugin) reloadCordons() {
+	cordons, err := readCordonFile(qtedp.cordonFile)
+	if err != nil {
+		glog.Errorf("Failed to read cordon file %s: %v", qtedp.cordonFile, err)
+		return
+	}
+
+	qtedp.mu.Lock()
+	defer qtedp.mu.Unlock()
+	for id := range cordons {
+		if !qtedp.cordoned[id] {
+			glog.V(0).Infof("Device %s cordoned", id)
+			qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditCordon, Devices: []string{id}}, nil)
+		}
+	}
+	for id := range qtedp.cordoned {
+		if !cordons[id] {
+			glog.V(0).Infof("Device %s uncordoned", id)
+			qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditUncordon, Devices: []string{id}}, nil)
+		}
+	}
+	qtedp.cordoned = cordons
+}
+
+func (qtedp *QtEnclavesDevicePlugin) findDevice(id string) *pluginapi.Device {
+	for _, d := range qtedp.devs {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+// validateAllocation checks the whole request before anything is handed
+// out, so a rejected request leaves no device half-allocated. It returns the
+// requested devices allocated before, checked by checkDoubleBooking. Must be
+// called with qtedp.mu held.
+func (qtedp *QtEnclavesDevicePlugin) validateAllocation(reqs *pluginapi.AllocateRequest) ([]string, error) {
+	var allocated []string
+	requested := map[string]bool{}
+	for _, req := range reqs.ContainerRequests {
+		for _, id := range req.DevicesIDs {
+			dev := qtedp.findDevice(id)
+			if dev == nil {
+				return nil, status.Errorf(codes.NotFound, "invalid allocation request: unknown device: %s", id)
+			}
+			if requested[id] {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid allocation request: device %s requested more than once", id)
+			}
+			requested[id] = true
+
+			if qtedp.cordoned[id] {
+				return nil, status.Errorf(codes.FailedPrecondition, "invalid allocation request: device %s is cordoned", id)
+			}
+			if dev.Health != pluginapi.Healthy {
+				return nil, status.Errorf(codes.Unavailable, "invalid allocation request: device %s is %s", id, dev.Health)
+			}
+			if qtedp.allocating[id] {
+				return nil, status.Errorf(codes.Aborted, "invalid allocation request: device %s is being allocated by another request", id)
+			}
+			if _, ok := qtedp.allocations[id]; ok {
+				allocated = append(allocated, id)
+			}
+		}
+	}
+
+	return allocated, nil
+}
+
+// checkDoubleBooking asks kubelet which containers hold devices allocated
+// before. kubelet calls Allocate again with the devices of the init
+// containers of a pod for its app containers, PodResources does not report
+// init containers so those devices pass. A device reported as assigned to a
+// container is double-booked, its holder is returned with the error. When
+// PodResources fails the check is skipped or rejects the request, depending
+// on qtedp.doubleBookingCheck.
+func (qtedp *QtEnclavesDevicePlugin) checkDoubleBooking(ids []string) (*podRef, error) {
+	if qtedp.doubleBookingCheck == doubleBookingOff {
+		return nil, nil
+	}
+
+	inUse, err := qtedp.lookupDevicesInUse()
+	if err != nil {
+		if qtedp.doubleBookingCheck == doubleBookingFailClosed {
+			return nil, status.Errorf(codes.Unavailable, "cannot check devices %v for double booking: %v", ids, err)
+		}
+		glog.Warningf("Cannot check devices %v for double booking: %v", ids, err)
+		return nil, nil
+	}
+	for _, id := range ids {
+		if pod, ok := inUse[id]; ok {
+			return pod.ref(), status.Errorf(codes.AlreadyExists, "invalid allocation request: device %s is already allocated to %s", id, pod)
+		}
+	}
+	return nil, nil
+}
+
+// lookupDevicesInUse asks kubelet which containers hold the devices, the
+// call is bounded by doubleBookingTimeout.
+func (qtedp *QtEnclavesDevicePlugin) lookupDevicesInUse() (map[string]podRef, error) {
+	if _, err := os.Stat(qtedp.paths.PodResourcesSocket); err != nil {
+		return nil, err
+	}
+	conn, err := qtedp.access.dial(qtedp.paths.PodResourcesSocket, doubleBookingTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return listDevicesInUse(podresourcesapi.NewPodResourcesListerClient(conn), qtedp.provider.resourceName())
+}
+
+// auditPod returns the container kubelet reports holding the devices, for
+// the audit record of a container start. It is nil without audit log or
+// when PodResources does not know the devices.
+func (qtedp *QtEnclavesDevicePlugin) auditPod(ids []string) *podRef {
+	if qtedp.audit == nil {
+		return nil
+	}
+	inUse, err := qtedp.lookupDevicesInUse()
+	if err != nil {
+		glog.Warningf("Cannot look up the container of devices %v for the audit log: %v", ids, err)
+		return nil
+	}
+	for _, id := range ids {
+		if pod, ok := inUse[id]; ok {
+			return pod.ref()
+		}
+	}
+	return nil
+}
+
+// recordAllocation reserves the devices of a validated request, marked as
+// being allocated until finishAllocation, so that concurrent requests for
+// them are rejected. Must be called with qtedp.mu held.
+func (qtedp *QtEnclavesDevicePlugin) recordAllocation(reqs *pluginapi.AllocateRequest) {
+	now := time.Now()
+	for _, req := range reqs.ContainerRequests {
+		for _, id := range req.DevicesIDs {
+			qtedp.allocations[id] = now
+			qtedp.allocating[id] = true
+		}
+	}
+}
+
+// finishAllocation ends the reservation of recordAllocation.
+func (qtedp *QtEnclavesDevicePlugin) finishAllocation(reqs *pluginapi.AllocateRequest) {
+	qtedp.mu.Lock()
+	defer qtedp.mu.Unlock()
+	for _, id := range requestedDevices(reqs) {
+		delete(qtedp.allocating, id)
+	}
+}
+
+// forgetAllocation undoes recordAllocation and the CID assignment when the
+// allocation is aborted after validation. Devices allocated before the
+// request stay allocated and keep their CIDs, only the CIDs assigned by the
+// request are released. Must be called with qtedp.mu held.
+func (qtedp *QtEnclavesDevicePlugin) forgetAllocation(reqs *pluginapi.AllocateRequest, allocated, assigned []string) {
+	kept := map[string]bool{}
+	for _, id := range allocated {
+		kept[id] = true
+	}
+	for _, id := range requestedDevices(reqs) {
+		if !kept[id] {
+			delete(qtedp.allocations, id)
+		}
+	}
+	for _, id := range assigned {
+		qtedp.cids.release(id)
+	}
+}
+
+// requestedDevices returns the device IDs of all containers of a request.
+func requestedDevices(reqs *pluginapi.AllocateRequest) []string {
+	var ids []string
+	for _, req := range reqs.ContainerRequests {
+		ids = append(ids, req.DevicesIDs...)
+	}
+	return ids
+}
+
+// deviceIDs returns the IDs of the managed devices.
+func (qtedp *QtEnclavesDevicePlugin) deviceIDs() []string {
+	qtedp.mu.Lock()
+	defer qtedp.mu.Unlock()
+
+	ids := make([]string, 0, len(qtedp.devs))
+	for _, d := range qtedp.devs {
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
@@ -0,0 +1,229 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide allocation checks testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func newTestAllocationPlugin() *QtEnclavesDevicePlugin {
+	qtedp := newTestDevicePlugin(newKubeletPaths(os.TempDir()), registrationModeDevicePlugin)
+	qtedp.devs = []*pluginapi.Device{
+		{ID: "qtbox_service0", Health: pluginapi.Healthy},
+		{ID: "qtbox_service1", Health: pluginapi.Healthy},
+		{ID: "qtbox_service2", Health: pluginapi.Unhealthy},
+		{ID: "qtbox_service3", Health: pluginapi.Healthy},
+	}
+	qtedp.cordoned["qtbox_service3"] = true
+	return qtedp
+}
+
+func allocateRequest(ids ...[]string) *pluginapi.AllocateRequest {
+	req := &pluginapi.AllocateRequest{}
+	for _, c := range ids {
+		req.ContainerRequests = append(req.ContainerRequests, &pluginapi.ContainerAllocateRequest{DevicesIDs: c})
+	}
+	return req
+}
+
+func TestAllocateRejections(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *pluginapi.AllocateRequest
+		code codes.Code
+	}{
+		{"unknown", allocateRequest([]string{"qtbox_service9"}), codes.NotFound},
+		{"same container twice", allocateRequest([]string{"qtbox_service0", "qtbox_service0"}), codes.InvalidArgument},
+		{"two containers", allocateRequest([]string{"qtbox_service0"}, []string{"qtbox_service0"}), codes.InvalidArgument},
+		{"unhealthy", allocateRequest([]string{"qtbox_service0", "qtbox_service2"}), codes.Unavailable},
+		{"cordoned", allocateRequest([]string{"qtbox_service1"}, []string{"qtbox_service3"}), codes.FailedPrecondition},
+	}
+
+	for _, tt := range tests {
+		qtedp := newTestAllocationPlugin()
+		_, err := qtedp.Allocate(context.Background(), tt.req)
+		if status.Code(err) != tt.code {
+			t.Fatalf("%s: expected %s but got %v", tt.name, tt.code, err)
+		}
+		if len(qtedp.allocations) != 0 {
+			t.Fatalf("%s: rejected request left allocations behind: %v", tt.name, qtedp.allocations)
+		}
+	}
+}
+
+func TestAllocateAlreadyAllocated(t *testing.T) {
+	qtedp := newTestAllocationPlugin()
+	qtedp.paths.PodResourcesSocket = filepath.Join(t.TempDir(), "missing.sock")
+
+	if _, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"})); err != nil {
+		t.Fatalf("Allocation failed: %v", err)
+	}
+	// Without PodResources kubelet is trusted to reuse devices.
+	if _, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"})); err != nil {
+		t.Fatalf("Reallocation failed: %v", err)
+	}
+
+	fake, _ := startFakePodResources(t)
+	qtedp.paths.PodResourcesSocket = fake.socket
+	fake.setPods(map[string][]string{"pod-a": {"qtbox_service0"}})
+	_, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service1", "qtbox_service0"}))
+	if status.Code(err) != codes.AlreadyExists || !strings.Contains(err.Error(), "default/pod-a/enclave") {
+		t.Fatalf("Expected %s naming the holder but got %v", codes.AlreadyExists, err)
+	}
+	if _, ok := qtedp.allocations["qtbox_service1"]; ok {
+		t.Fatal("Rejected request allocated qtbox_service1")
+	}
+}
+
+func TestAllocateInProgress(t *testing.T) {
+	qtedp := newTestAllocationPlugin()
+	qtedp.hooks = newHookRunner(resourceName, map[string]string{hookAllocate: writeHookScript(t, "sleep 1")}, 5*time.Second)
+
+	done := make(chan error)
+	go func() {
+		_, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"}))
+		done <- err
+	}()
+	for deadline := time.Now().Add(time.Second); ; {
+		qtedp.mu.Lock()
+		reserved := qtedp.allocating["qtbox_service0"]
+		qtedp.mu.Unlock()
+		if reserved {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("qtbox_service0 was not reserved by the first request")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service1"}, []string{"qtbox_service0"}))
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("Expected %s for a device being allocated but got %v", codes.Aborted, err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("First allocation failed: %v", err)
+	}
+	if _, ok := qtedp.allocations["qtbox_service1"]; ok {
+		t.Fatal("Rejected request allocated qtbox_service1")
+	}
+	if len(qtedp.allocating) != 0 {
+		t.Fatalf("Finished requests left reservations behind: %v", qtedp.allocating)
+	}
+}
+
+func TestAllocateDoubleBookingCheck(t *testing.T) {
+	tests := []struct {
+		check string
+		code  codes.Code
+	}{
+		{"", codes.OK},
+		{doubleBookingOff, codes.OK},
+		{doubleBookingFailOpen, codes.OK},
+		{doubleBookingFailClosed, codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		qtedp := newTestAllocationPlugin()
+		qtedp.paths.PodResourcesSocket = filepath.Join(t.TempDir(), "missing.sock")
+		qtedp.doubleBookingCheck = tt.check
+		qtedp.allocations["qtbox_service0"] = time.Now()
+
+		_, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service1", "qtbox_service0"}))
+		if status.Code(err) != tt.code {
+			t.Fatalf("%q: expected %s but got %v", tt.check, tt.code, err)
+		}
+		if _, ok := qtedp.allocations["qtbox_service1"]; ok != (tt.code == codes.OK) {
+			t.Fatalf("%q: unexpected allocations %v", tt.check, qtedp.allocations)
+		}
+		if _, ok := qtedp.allocations["qtbox_service0"]; !ok {
+			t.Fatalf("%q: qtbox_service0 should stay allocated", tt.check)
+		}
+	}
+
+	if err := validateDoubleBookingCheck("strict"); err == nil {
+		t.Fatal("Invalid double booking check should be rejected")
+	}
+}
+
+func TestAllocateReusesInitContainerDevices(t *testing.T) {
+	qtedp := newTestAllocationPlugin()
+	qtedp.releaseTracked = true
+	fake, _ := startFakePodResources(t)
+	qtedp.paths.PodResourcesSocket = fake.socket
+
+	// kubelet allocates the init container devices again to the app
+	// containers, PodResources does not report init containers.
+	for _, container := range []string{"init", "app"} {
+		if _, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"})); err != nil {
+			t.Fatalf("Allocation to the %s container failed: %v", container, err)
+		}
+	}
+}
+
+func TestAbortedAllocationKeepsEarlierCIDs(t *testing.T) {
+	qtedp := newTestAllocationPlugin()
+	qtedp.cids, _ = newCIDPool("10-11", filepath.Join(t.TempDir(), "vsock"))
+	if _, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"})); err != nil {
+		t.Fatalf("Allocation failed: %v", err)
+	}
+
+	qtedp.hooks = newHookRunner(resourceName, map[string]string{hookAllocate: writeHookScript(t, "exit 1")}, time.Second)
+	req := allocateRequest([]string{"qtbox_service0"}, []string{"qtbox_service1"})
+	if _, err := qtedp.Allocate(context.Background(), req); err == nil {
+		t.Fatal("Allocation with a failing hook should fail")
+	}
+
+	cids := qtedp.cids.snapshot()
+	if cids["qtbox_service0"] != 10 {
+		t.Fatalf("qtbox_service0 should keep CID 10 of the earlier allocation, got %v", cids)
+	}
+	if _, ok := cids["qtbox_service1"]; ok {
+		t.Fatalf("CID assigned by the aborted request should be released, got %v", cids)
+	}
+	if _, ok := qtedp.allocations["qtbox_service0"]; !ok {
+		t.Fatal("qtbox_service0 should stay allocated")
+	}
+}
+
+func TestReadCordonFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cordons")
+	if err := os.WriteFile(file, []byte("# maintenance\nqtbox_service1\n\n  qtbox_service4 \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cordons, err := readCordonFile(file)
+	if err != nil {
+		t.Fatalf("Failed to read cordon file: %v", err)
+	}
+	if len(cordons) != 2 || !cordons["qtbox_service1"] || !cordons["qtbox_service4"] {
+		t.Fatalf("Unexpected cordons: %v", cordons)
+	}
+
+	cordons, err = readCordonFile(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(cordons) != 0 {
+		t.Fatalf("Missing cordon file should mean no cordons, got %v, %v", cordons, err)
+	}
+}
@@ -0,0 +1,344 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide attestation evidence to the containers
+ *********************************************************************************/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	attestationCommand = "command"
+	attestationSim     = "sim"
+
+	attestationFail   = "fail"
+	attestationIgnore = "ignore"
+
+	defaultAttestationDir           = "/run/qt-enclave/attestation"
+	defaultAttestationContainerPath = "/run/qt-enclave/attestation"
+	defaultAttestationRetries       = 2
+	defaultAttestationTimeout       = 30 * time.Second
+	attestationRetryDelay           = time.Second
+
+	// The evidence files of a device, in <container-path>/<device-id>.
+	evidenceReport      = "report.bin"
+	evidenceCertChain   = "cert-chain.pem"
+	evidenceMeasurement = "measurement"
+
+	helperAttestation = "attestation"
+)
+
+// attestationEvidence is the evidence of an enclave.
+type attestationEvidence struct {
+	Device string
+	// Report is the measurement report signed by the platform.
+	Report []byte
+	// CertChain is the PEM certificate chain of the report signing key,
+	// leaf first.
+	CertChain []byte
+	// Measurement is the hex measurement of the enclave image.
+	Measurement string
+}
+
+// attestationProvider collects the evidence of a device.
+type attestationProvider interface {
+	// collect writes the evidence files of device id of resource to dir.
+	collect(ctx context.Context, resource, id, devicePath, dir string) error
+}
+
+// commandAttestation runs a command with the device path and the output
+// directory as last arguments. It must write the evidence files there.
+type commandAttestation struct {
+	command string
+	// helper runs the command when the daemon dropped its privileges, with
+	// the output directory relative to root.
+	helper *privHelper
+	root   string
+}
+
+func (a *commandAttestation) collect(ctx context.Context, resource, id, devicePath, dir string) error {
+	if a.helper != nil {
+		rel, err := filepath.Rel(a.root, dir)
+		if err != nil {
+			return err
+		}
+		return a.helper.run(ctx, helperRequest{Name: helperAttestation, Resource: resource, Device: id, Dir: rel})
+	}
+	args := []string{devicePath, dir}
+	env := []string{"QT_ENCLAVE_DEVICE_ID=" + id}
+	return runCommand(ctx, a.command, args, env, nil)
+}
+
+// simMeasurement is the measurement of every simulated enclave.
+var simMeasurement = sha256.Sum256([]byte("qt-enclave-sim"))
+
+// simAttestation produces evidence for simulated devices, signed by a
+// self-signed certificate made at startup.
+type simAttestation struct {
+	once sync.Once
+	key  *ecdsa.PrivateKey
+	cert []byte
+	err  error
+}
+
+func (a *simAttestation) init() {
+	a.key, a.err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if a.err != nil {
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "qt-enclave simulated attestation"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &a.key.PublicKey, a.key)
+	if err != nil {
+		a.err = err
+		return
+	}
+	a.cert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func (a *simAttestation) collect(ctx context.Context, resource, id, devicePath, dir string) error {
+	a.once.Do(a.init)
+	if a.err != nil {
+		return a.err
+	}
+
+	report := []byte(fmt.Sprintf("device=%s\nmeasurement=%x\n", id, simMeasurement))
+	digest := sha256.Sum256(report)
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		evidenceReport:      append(report, signature...),
+		evidenceCertChain:   a.cert,
+		evidenceMeasurement: []byte(hex.EncodeToString(simMeasurement[:]) + "\n"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadEvidence reads and checks the evidence files in dir.
+func loadEvidence(id, dir string) (*attestationEvidence, error) {
+	e := &attestationEvidence{Device: id}
+	var err error
+	if e.Report, err = os.ReadFile(filepath.Join(dir, evidenceReport)); err != nil {
+		return nil, err
+	}
+	if len(e.Report) == 0 {
+		return nil, fmt.Errorf("empty measurement report")
+	}
+	if e.CertChain, err = os.ReadFile(filepath.Join(dir, evidenceCertChain)); err != nil {
+		return nil, err
+	}
+	certs := 0
+	for rest := e.CertChain; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("invalid certificate in %s: %v", evidenceCertChain, err)
+		}
+		certs++
+	}
+	if certs == 0 {
+		return nil, fmt.Errorf("no certificate in %s", evidenceCertChain)
+	}
+	measurement, err := os.ReadFile(filepath.Join(dir, evidenceMeasurement))
+	if err != nil {
+		return nil, err
+	}
+	e.Measurement = strings.ToLower(strings.TrimSpace(string(measurement)))
+	if _, err := hex.DecodeString(e.Measurement); err != nil || e.Measurement == "" {
+		return nil, fmt.Errorf("invalid measurement %q", e.Measurement)
+	}
+	return e, nil
+}
+
+// attestation places the evidence of the devices of a container in a
+// directory mounted by Allocate. The directory of a container is named
+// after its devices, the only thing Allocate and PreStartContainer share.
+type attestation struct {
+	provider      attestationProvider
+	dir           string
+	containerPath string
+	policy        string
+	retries       int
+	timeout       time.Duration
+}
+
+func newAttestation(provider attestationProvider, dir, containerPath, policy string) (*attestation, error) {
+	if policy != attestationFail && policy != attestationIgnore {
+		return nil, fmt.Errorf("invalid attestation failure policy %q, expected fail or ignore", policy)
+	}
+	if !filepath.IsAbs(containerPath) {
+		return nil, fmt.Errorf("attestation container path %q is not absolute", containerPath)
+	}
+	return &attestation{
+		provider:      provider,
+		dir:           dir,
+		containerPath: containerPath,
+		policy:        policy,
+		retries:       defaultAttestationRetries,
+		timeout:       defaultAttestationTimeout,
+	}, nil
+}
+
+// containerKey names the directories of the container with ids.
+func containerKey(ids []string) string {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// forgetContainers removes the directories in dir of the containers that
+// used device id.
+func forgetContainers(dir, id string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		for _, owner := range strings.Split(entry.Name(), ",") {
+			if owner == id {
+				if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+					glog.Errorf("Failed to remove %s: %v", filepath.Join(dir, entry.Name()), err)
+				}
+				break
+			}
+		}
+	}
+}
+
+// prepareContainerDir creates the empty directory of a container in dir
+// and returns its mount at containerPath.
+func prepareContainerDir(dir, containerPath string, ids []string) (*pluginapi.Mount, error) {
+	hostPath := filepath.Join(dir, containerKey(ids))
+	if err := os.RemoveAll(hostPath); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(hostPath, 0755); err != nil {
+		return nil, err
+	}
+	return &pluginapi.Mount{ContainerPath: containerPath, HostPath: hostPath, ReadOnly: true}, nil
+}
+
+// containerDir returns the host directory of the container with ids.
+func (a *attestation) containerDir(ids []string) string {
+	return filepath.Join(a.dir, containerKey(ids))
+}
+
+// prepare creates the empty evidence directory of a container and returns
+// its mount.
+func (a *attestation) prepare(ids []string) (*pluginapi.Mount, error) {
+	return prepareContainerDir(a.dir, a.containerPath, ids)
+}
+
+// collect places the evidence of every device of resource in
+// <dir>/<device-id>, retrying failed attempts.
+func (a *attestation) collect(resource string, ids []string, devicePath func(string) string) ([]*attestationEvidence, error) {
+	var all []*attestationEvidence
+	for _, id := range ids {
+		var e *attestationEvidence
+		var err error
+		for attempt := 0; attempt <= a.retries; attempt++ {
+			if attempt > 0 {
+				glog.Errorf("Attestation of device %s failed, retrying: %v", id, err)
+				time.Sleep(attestationRetryDelay)
+			}
+			if e, err = a.collectDevice(resource, ids, id, devicePath(id)); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("attestation of device %s failed: %v", id, err)
+		}
+		all = append(all, e)
+	}
+	return all, nil
+}
+
+// collectDevice has the provider write to a staging directory, checks the
+// evidence and moves it in place, so containers never see partial files.
+func (a *attestation) collectDevice(resource string, ids []string, id, devicePath string) (*attestationEvidence, error) {
+	parent := a.containerDir(ids)
+	staging, err := os.MkdirTemp(parent, "."+id+"-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+	if err := os.Chmod(staging, 0755); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+	if err := a.provider.collect(ctx, resource, id, devicePath, staging); err != nil {
+		return nil, err
+	}
+	e, err := loadEvidence(id, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	target := filepath.Join(parent, id)
+	if err := os.RemoveAll(target); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(staging, target); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// forget removes the evidence of the containers that used a released
+// device, nil-safe.
+func (a *attestation) forget(id string) {
+	if a != nil {
+		forgetContainers(a.dir, id)
+	}
+}
@@ -0,0 +1,113 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide attestation evidence to the containers testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func newTestAttestationPlugin(t *testing.T, provider attestationProvider, policy string) *QtEnclavesDevicePlugin {
+	a, err := newAttestation(provider, t.TempDir(), defaultAttestationContainerPath, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.retries = 1
+	qtedp := newTestAllocationPlugin()
+	qtedp.attestation = a
+	return qtedp
+}
+
+func TestAttestationEvidence(t *testing.T) {
+	qtedp := newTestAttestationPlugin(t, &simAttestation{}, attestationFail)
+	if opts, _ := qtedp.GetDevicePluginOptions(context.Background(), &pluginapi.Empty{}); !opts.PreStartRequired {
+		t.Fatal("Attestation needs PreStartContainer")
+	}
+
+	resp, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service1", "qtbox_service0"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounts := resp.ContainerResponses[0].Mounts
+	dir := filepath.Join(qtedp.attestation.dir, "qtbox_service0,qtbox_service1")
+	if len(mounts) != 1 || mounts[0].HostPath != dir || mounts[0].ContainerPath != defaultAttestationContainerPath || !mounts[0].ReadOnly {
+		t.Fatalf("Unexpected evidence mounts %v", mounts)
+	}
+
+	if _, err := qtedp.PreStartContainer(context.Background(), &pluginapi.PreStartContainerRequest{
+		DevicesIDs: []string{"qtbox_service0", "qtbox_service1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"qtbox_service0", "qtbox_service1"} {
+		e, err := loadEvidence(id, filepath.Join(dir, id))
+		if err != nil {
+			t.Fatalf("Invalid evidence of %s: %v", id, err)
+		}
+		if e.Measurement != hex.EncodeToString(simMeasurement[:]) || !strings.Contains(string(e.Report), "device="+id) {
+			t.Fatalf("Unexpected evidence of %s: %+v", id, e)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("Staging directories left behind: %v", entries)
+	}
+
+	qtedp.attestation.forget("qtbox_service1")
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("Evidence should be removed with the device, got %v", err)
+	}
+}
+
+func TestAttestationFailurePolicy(t *testing.T) {
+	attempts := filepath.Join(t.TempDir(), "attempts")
+	command := &commandAttestation{command: writeHookScript(t, "echo $QT_ENCLAVE_DEVICE_ID >> "+attempts+
+		"\necho not a certificate > $2/"+evidenceCertChain+"\necho report > $2/"+evidenceReport+
+		"\necho 00ff > $2/"+evidenceMeasurement)}
+	req := &pluginapi.PreStartContainerRequest{DevicesIDs: []string{"qtbox_service0"}}
+
+	qtedp := newTestAttestationPlugin(t, command, attestationFail)
+	if _, err := qtedp.Allocate(context.Background(), allocateRequest(req.DevicesIDs)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := qtedp.PreStartContainer(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), "no certificate") {
+		t.Fatalf("Invalid evidence should fail the container start, got %v", err)
+	}
+	if data, _ := os.ReadFile(attempts); string(data) != "qtbox_service0\nqtbox_service0\n" {
+		t.Fatalf("Expected one retry, got attempts %q", data)
+	}
+	if entries, _ := os.ReadDir(qtedp.attestation.containerDir(req.DevicesIDs)); len(entries) != 0 {
+		t.Fatalf("Invalid evidence should not be visible to the container: %v", entries)
+	}
+
+	qtedp = newTestAttestationPlugin(t, command, attestationIgnore)
+	if _, err := qtedp.Allocate(context.Background(), allocateRequest(req.DevicesIDs)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := qtedp.PreStartContainer(context.Background(), req); err != nil {
+		t.Fatalf("Ignored attestation failure should not fail the container start: %v", err)
+	}
+
+	if _, err := newAttestation(command, t.TempDir(), defaultAttestationContainerPath, "retry"); err == nil {
+		t.Fatal("Unknown failure policy should be rejected")
+	}
+}
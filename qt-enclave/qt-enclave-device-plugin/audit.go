@@ -0,0 +1,493 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide hash chained allocation audit log
+ *********************************************************************************/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	auditAllocate  = "allocate"
+	auditPreStart  = "pre-start"
+	auditRelease   = "release"
+	auditCordon    = "cordon"
+	auditUncordon  = "uncordon"
+	auditHealth    = "health"
+	auditRemediate = "remediate"
+	// auditGenesis opens a new log and auditCheckpoint every rotated file,
+	// so records dropped from the head of a file are detected.
+	auditGenesis    = "genesis"
+	auditCheckpoint = "checkpoint"
+	// auditTruncate records the partial last line, left by a crash in
+	// the middle of a write, removed when the log was opened.
+	auditTruncate = "truncate"
+
+	// auditSecretRelease records the secrets released to an enclave, or
+	// denied to it.
+	auditSecretRelease = "secret-release"
+	secretRelease      = "release"
+	secretDeny         = "deny"
+
+	defaultAuditMaxSize  = 100 * 1024 * 1024
+	defaultAuditMaxFiles = 5
+
+	// minAuditKeySize is the minimum size in bytes of the audit key.
+	minAuditKeySize = 32
+)
+
+// auditRecord is one JSON line of the audit log. Hash is the HMAC-SHA256,
+// keyed with the audit key, of the record marshalled with an empty Hash, and
+// Prev the Hash of the record before it, so editing or dropping a record
+// breaks the chain. The key is not stored with the log, so the chain cannot
+// be recomputed by whoever can write the log.
+type auditRecord struct {
+	Seq      uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Resource string    `json:"resource"`
+	Devices  []string  `json:"devices"`
+	Pod      *podRef   `json:"pod,omitempty"`
+	Health   string    `json:"health,omitempty"`
+	Action   string    `json:"action,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	// Truncated is the size in bytes of the partial line removed.
+	Truncated int64  `json:"truncated,omitempty"`
+	Prev      string `json:"prev"`
+	Hash      string `json:"hash"`
+}
+
+func (r auditRecord) digest(key []byte) (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// readAuditKey reads the key of the audit chain. The key must not be kept
+// in the directory of the log.
+func readAuditKey(keyFile, logFile string) ([]byte, error) {
+	if keyFile == "" {
+		return nil, fmt.Errorf("the audit log needs a key file")
+	}
+	if logFile != "" {
+		keyDir, err := filepath.Abs(filepath.Dir(keyFile))
+		if err != nil {
+			return nil, err
+		}
+		logDir, err := filepath.Abs(filepath.Dir(logFile))
+		if err != nil {
+			return nil, err
+		}
+		if keyDir == logDir {
+			return nil, fmt.Errorf("audit key %s must not be kept in the directory of the audit log", keyFile)
+		}
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) < minAuditKeySize {
+		return nil, fmt.Errorf("audit key %s has %d bytes, at least %d are needed", keyFile, len(key), minAuditKeySize)
+	}
+	return key, nil
+}
+
+// auditLog appends records to a file, rotated to <file>.1 ... <file>.N when
+// it grows beyond maxSize, with N = maxFiles. The chain continues across
+// rotations.
+type auditLog struct {
+	mu       sync.Mutex
+	path     string
+	key      []byte
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	seq      uint64
+	prev     string
+}
+
+func newAuditLog(path string, key []byte, maxSize int64, maxFiles int) (*auditLog, error) {
+	a := &auditLog{path: path, key: key, maxSize: maxSize, maxFiles: maxFiles}
+
+	truncated, err := truncateAuditTail(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to truncate audit log %s: %v", path, err)
+	}
+
+	// Resume the chain from the last record, which is in the rotated file
+	// if the current one is still empty, after checking the files and the
+	// anchor so a tampered log is not extended.
+	last, err := verifyAuditTail(path, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume audit log %s: %v", path, err)
+	}
+	if last != nil {
+		a.seq = last.Seq
+		a.prev = last.Hash
+	}
+
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if last == nil {
+		if err := a.write(auditRecord{Seq: 1, Time: time.Now().UTC(), Event: auditGenesis}); err != nil {
+			a.file.Close()
+			return nil, err
+		}
+	}
+	if truncated > 0 {
+		glog.Warningf("Removed a partial record of %d bytes from the end of audit log %s", truncated, path)
+		r := a.newRecord(auditTruncate)
+		r.Truncated = truncated
+		if err := a.write(r); err != nil {
+			a.file.Close()
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// truncateAuditTail removes the partial last line of the audit file, left
+// when the plugin stopped in the middle of a write, and returns its size.
+// Complete lines are left to the verification.
+func truncateAuditTail(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return 0, nil
+	}
+	size := int64(bytes.LastIndexByte(data, '\n') + 1)
+	if err := os.Truncate(path, size); err != nil {
+		return 0, err
+	}
+	return int64(len(data)) - size, nil
+}
+
+// anchorPath is the file holding the sequence number and hash of the last
+// record, so records dropped from the tail are detected.
+func anchorPath(path string) string {
+	return path + ".anchor"
+}
+
+// auditAnchor is the content of the anchor file.
+type auditAnchor struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// readAuditAnchor returns the anchor of an audit file, nil if it has none.
+func readAuditAnchor(path string) (*auditAnchor, error) {
+	data, err := os.ReadFile(anchorPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var anchor auditAnchor
+	if err := json.Unmarshal(data, &anchor); err != nil {
+		return nil, fmt.Errorf("invalid anchor %s: %v", anchorPath(path), err)
+	}
+	return &anchor, nil
+}
+
+// writeAnchor replaces the anchor file with the last record.
+func (a *auditLog) writeAnchor(r auditRecord) error {
+	data, err := json.Marshal(auditAnchor{Seq: r.Seq, Hash: r.Hash})
+	if err != nil {
+		return err
+	}
+	tmp := anchorPath(a.path) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, anchorPath(a.path))
+}
+
+// verifyAuditTail verifies the current audit file, and the last rotated one
+// it continues, against the anchor. It returns the last record, nil for a
+// new log.
+func verifyAuditTail(path string, key []byte) (*auditRecord, error) {
+	var files []string
+	for _, file := range []string{path + ".1", path} {
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	anchor, err := readAuditAnchor(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		if anchor != nil {
+			return nil, fmt.Errorf("audit files missing, anchor at record %d", anchor.Seq)
+		}
+		return nil, nil
+	}
+
+	last, _, err := verifyAuditFiles(files, anchor, key)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil && anchor == nil {
+		return nil, fmt.Errorf("anchor %s missing", anchorPath(path))
+	}
+	return last, nil
+}
+
+func (a *auditLog) open() error {
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	a.file = f
+	a.size = info.Size()
+	return nil
+}
+
+// rotate shifts <file>.i to <file>.i+1 and <file> to <file>.1, dropping
+// the oldest file beyond maxFiles.
+func (a *auditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	for i := a.maxFiles - 1; i >= 1; i-- {
+		err := os.Rename(a.path+"."+strconv.Itoa(i), a.path+"."+strconv.Itoa(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(a.path, a.path+".1"); err != nil {
+		return err
+	}
+
+	return a.open()
+}
+
+// newRecord returns the next record of the chain.
+func (a *auditLog) newRecord(event string) auditRecord {
+	return auditRecord{Seq: a.seq + 1, Time: time.Now().UTC(), Event: event, Prev: a.prev}
+}
+
+// record appends an audit record with the event details of r, the chain
+// fields are filled in. Failures are logged, the audited operation is not
+// affected.
+func (a *auditLog) record(r auditRecord, opErr error) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	next := a.newRecord(r.Event)
+	r.Seq, r.Time, r.Prev = next.Seq, next.Time, next.Prev
+	if opErr != nil {
+		r.Error = opErr.Error()
+	}
+
+	if err := a.write(r); err != nil {
+		glog.Errorf("Failed to write audit record %s for %v: %v", r.Event, r.Devices, err)
+	}
+}
+
+func (a *auditLog) write(r auditRecord) error {
+	hash, err := r.digest(a.key)
+	if err != nil {
+		return err
+	}
+	r.Hash = hash
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if a.size > 0 && a.size+int64(len(line)) > a.maxSize && r.Event != auditCheckpoint {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+		// The new file starts with a checkpoint chained to the rotated one.
+		if err := a.write(a.newRecord(auditCheckpoint)); err != nil {
+			return err
+		}
+		r.Seq, r.Prev = a.seq+1, a.prev
+		return a.write(r)
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	a.seq = r.Seq
+	a.prev = r.Hash
+	return a.writeAnchor(r)
+}
+
+func (a *auditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+func scanAuditRecords(r io.Reader, fn func(*auditRecord, int) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		rec := &auditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := fn(rec, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// verifyAuditLog checks the hash chain of audit files given oldest first,
+// e.g. <file>.2 <file>.1 <file>, against the anchor of the last one if it
+// exists, and returns the number of records checked.
+func verifyAuditLog(files []string, key []byte) (int, error) {
+	anchor, err := readAuditAnchor(files[len(files)-1])
+	if err != nil {
+		return 0, err
+	}
+	_, count, err := verifyAuditFiles(files, anchor, key)
+	return count, err
+}
+
+// verifyAuditFiles checks the hash chain of audit files given oldest first.
+// Every file starts with the genesis record or a checkpoint, older files may
+// have been rotated away. The last record must match anchor unless nil. It
+// returns the last record and the number of records checked.
+func verifyAuditFiles(files []string, anchor *auditAnchor, key []byte) (*auditRecord, int, error) {
+	var prev *auditRecord
+	count := 0
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return prev, count, err
+		}
+		err = scanAuditRecords(f, func(r *auditRecord, line int) error {
+			hash, err := r.digest(key)
+			if err != nil {
+				return err
+			}
+			if hash != r.Hash {
+				return fmt.Errorf("line %d: record %d was modified", line, r.Seq)
+			}
+			switch {
+			case line == 1 && r.Event == auditGenesis:
+				if r.Seq != 1 || r.Prev != "" || prev != nil {
+					return fmt.Errorf("line %d: genesis record in the middle of the log", line)
+				}
+			case line == 1 && r.Event == auditCheckpoint:
+				// The chain before the oldest file kept can not be
+				// checked once older files were rotated away.
+			case line == 1:
+				return fmt.Errorf("line %d: file does not start with a genesis or checkpoint record, records were removed", line)
+			case r.Event == auditGenesis || r.Event == auditCheckpoint:
+				return fmt.Errorf("line %d: %s record in the middle of a file", line, r.Event)
+			}
+			if prev != nil && (r.Seq != prev.Seq+1 || r.Prev != prev.Hash) {
+				return fmt.Errorf("line %d: chain broken between records %d and %d", line, prev.Seq, r.Seq)
+			}
+			prev = r
+			count++
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			return prev, count, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	if anchor != nil && (prev == nil || prev.Seq != anchor.Seq || prev.Hash != anchor.Hash) {
+		last := uint64(0)
+		if prev != nil {
+			last = prev.Seq
+		}
+		return prev, count, fmt.Errorf("log ends at record %d but the anchor is at record %d, records were removed from the tail",
+			last, anchor.Seq)
+	}
+	return prev, count, nil
+}
+
+// runVerify implements the verify subcommand.
+func runVerify(keyFile string, files []string) int {
+	if len(files) == 0 || keyFile == "" {
+		fmt.Fprintln(os.Stderr, "usage: qt-enclave-k8s-device-plugin -audit-key-file <key file> verify <audit file>... (oldest first)")
+		return 2
+	}
+	key, err := readAuditKey(keyFile, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read the audit key: %v\n", err)
+		return 1
+	}
+
+	count, err := verifyAuditLog(files, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit log verification failed after %d records: %v\n", count, err)
+		return 1
+	}
+
+	fmt.Printf("audit log verified: %d records\n", count)
+	return 0
+}
@@ -0,0 +1,213 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide audit log testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+var testAuditKey = []byte(strings.Repeat("k", minAuditKeySize))
+
+func writeAuditRecords(t *testing.T, path string, maxSize int64, n int) {
+	a, err := newAuditLog(path, testAuditKey, maxSize, 3)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer a.Close()
+
+	pod := &podRef{Namespace: "default", Name: "pod-a", Container: "enclave"}
+	for i := 0; i < n; i++ {
+		a.record(auditRecord{Resource: resourceName, Event: auditAllocate, Devices: []string{"qtbox_service0"}, Pod: pod}, nil)
+		a.record(auditRecord{Resource: resourceName, Event: auditRelease, Devices: []string{"qtbox_service0"}, Pod: pod},
+			errors.New("reset failed"))
+	}
+}
+
+func TestAuditLogChainAcrossRotationAndRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	writeAuditRecords(t, path, 1024, 3)
+	// Reopening resumes the chain.
+	writeAuditRecords(t, path, 1024, 3)
+
+	files := []string{path + ".3", path + ".2", path + ".1", path}
+	var existing []string
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			existing = append(existing, f)
+		}
+	}
+	if len(existing) < 2 {
+		t.Fatalf("Expected the audit log to be rotated, got %v", existing)
+	}
+
+	count, err := verifyAuditLog(existing, testAuditKey)
+	if err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+	if count == 0 {
+		t.Fatal("No record verified")
+	}
+}
+
+func TestAuditLogDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeAuditRecords(t, path, defaultAuditMaxSize, 3)
+
+	if _, err := verifyAuditLog([]string{path}, testAuditKey); err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+
+	edited := strings.Replace(string(data), "pod-a", "pod-b", 1)
+	dropped := strings.Join(append(append([]string{}, lines[:2]...), lines[3:]...), "")
+	head := strings.Join(lines[2:], "")
+	tail := strings.Join(lines[:len(lines)-2], "")
+	for name, content := range map[string]string{"edited": edited, "dropped": dropped, "head": head, "tail": tail} {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifyAuditLog([]string{path}, testAuditKey); err == nil {
+			t.Fatalf("Verification of the %s audit log should fail", name)
+		}
+		// A tampered log is not extended.
+		if _, err := newAuditLog(path, testAuditKey, defaultAuditMaxSize, 3); err == nil {
+			t.Fatalf("Opening the %s audit log should fail", name)
+		}
+	}
+}
+
+func TestAuditLogAnchor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeAuditRecords(t, path, defaultAuditMaxSize, 1)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `{"seq":1,`) || !strings.Contains(strings.SplitN(string(data), "\n", 2)[0], `"event":"genesis"`) {
+		t.Fatalf("Log should start with the genesis record: %s", data)
+	}
+
+	// The whole log removed with its anchor left behind.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newAuditLog(path, testAuditKey, defaultAuditMaxSize, 3); err == nil || !strings.Contains(err.Error(), "audit files missing") {
+		t.Fatalf("Opening a removed log should fail, got %v", err)
+	}
+}
+
+func TestAuditLogKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeAuditRecords(t, path, defaultAuditMaxSize, 1)
+
+	// The chain cannot be checked, or recomputed, without the key.
+	otherKey := []byte(strings.Repeat("x", minAuditKeySize))
+	if _, err := verifyAuditLog([]string{path}, otherKey); err == nil {
+		t.Fatal("Verification with another key should fail")
+	}
+	if _, err := newAuditLog(path, otherKey, defaultAuditMaxSize, 3); err == nil {
+		t.Fatal("Opening the log with another key should fail")
+	}
+
+	keyDir := t.TempDir()
+	keyFile := filepath.Join(keyDir, "audit.key")
+	if err := os.WriteFile(keyFile, testAuditKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := readAuditKey(keyFile, path); err != nil || string(key) != string(testAuditKey) {
+		t.Fatalf("Failed to read the audit key: %v", err)
+	}
+	if _, err := readAuditKey(keyFile, filepath.Join(keyDir, "audit.log")); err == nil {
+		t.Fatal("Key in the directory of the log should be rejected")
+	}
+	if err := os.WriteFile(keyFile, []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readAuditKey(keyFile, path); err == nil {
+		t.Fatal("Short key should be rejected")
+	}
+}
+
+func TestAuditLogTruncatesPartialRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeAuditRecords(t, path, defaultAuditMaxSize, 1)
+
+	// A crash in the middle of a write leaves a partial last line.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial := `{"seq":4,"time":"2026-10-19T00:00:00Z","event":"allo`
+	f.WriteString(partial)
+	f.Close()
+
+	writeAuditRecords(t, path, defaultAuditMaxSize, 1)
+	if _, err := verifyAuditLog([]string{path}, testAuditKey); err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), partial) {
+		t.Fatal("Partial record was not removed")
+	}
+	if !strings.Contains(string(data), fmt.Sprintf(`"event":"truncate","resource":"","devices":null,"truncated":%d`, len(partial))) {
+		t.Fatalf("Truncation was not recorded: %s", data)
+	}
+}
+
+func TestAuditPreStartRecordsPod(t *testing.T) {
+	qtedp := newTestAllocationPlugin()
+	fake, _ := startFakePodResources(t)
+	qtedp.paths.PodResourcesSocket = fake.socket
+	fake.setPods(map[string][]string{"pod-a": {"qtbox_service0"}})
+	path := filepath.Join(t.TempDir(), "audit.log")
+	qtedp.audit, _ = newAuditLog(path, testAuditKey, defaultAuditMaxSize, 1)
+	defer qtedp.audit.Close()
+
+	options, _ := qtedp.GetDevicePluginOptions(context.Background(), nil)
+	if !options.PreStartRequired {
+		t.Fatal("PreStartContainer should be required with the audit log")
+	}
+	req := &pluginapi.PreStartContainerRequest{DevicesIDs: []string{"qtbox_service0"}}
+	if _, err := qtedp.PreStartContainer(context.Background(), req); err != nil {
+		t.Fatalf("PreStartContainer failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"event":"pre-start","resource":"`+resourceName+`","devices":["qtbox_service0"],"pod":{"namespace":"default","name":"pod-a","container":"enclave"}`) {
+		t.Fatalf("Pre-start record should name the container: %s", data)
+	}
+}
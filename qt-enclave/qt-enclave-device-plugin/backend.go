@@ -0,0 +1,89 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide device backends
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const defaultDevRoot = "/dev"
+
+// deviceBackend is where the enclave devices come from.
+type deviceBackend interface {
+	// discover returns the IDs of the devices to advertise.
+	discover() ([]string, error)
+	// check returns an error when the device is not usable.
+	check(id string) error
+	// hostPath returns the path of the device node on the host.
+	hostPath(id string) string
+}
+
+// hostBackend serves the QingTian device nodes of the host.
+type hostBackend struct {
+	devRoot string
+	ids     []string
+	// nodes verifies the device nodes, nil only checks that they exist.
+	nodes *nodeVerifier
+}
+
+func newHostBackend(devRoot string) *hostBackend {
+	b := &hostBackend{devRoot: devRoot}
+	for i := 0; i < enclavesPerInstance; i++ {
+		b.ids = append(b.ids, generateDeviceID(deviceName))
+	}
+	return b
+}
+
+func (b *hostBackend) discover() ([]string, error) {
+	var ids []string
+	for _, id := range b.ids {
+		if b.nodes.verifyDiscovered(b.hostPath(id)) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (b *hostBackend) check(id string) error {
+	devPath := b.hostPath(id)
+	if err := b.nodes.verify(devPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("device is not exist: %s", devPath)
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *hostBackend) hostPath(id string) string {
+	return filepath.Join(b.devRoot, id)
+}
+
+// newDevices returns healthy devices for the IDs.
+func newDevices(ids []string) []*pluginapi.Device {
+	devs := []*pluginapi.Device{}
+	for _, id := range ids {
+		devs = append(devs, &pluginapi.Device{
+			ID:     id,
+			Health: pluginapi.Healthy,
+		})
+	}
+	return devs
+}
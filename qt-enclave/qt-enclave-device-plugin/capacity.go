@@ -0,0 +1,376 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide enclave memory and CPU capacity as extended resources
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	registerapi "k8s.io/kubelet/pkg/apis/pluginregistration/v1"
+)
+
+const (
+	memoryResourceName = "huawei.com/qt_enclave_memory"
+	cpuResourceName    = "huawei.com/qt_enclave_cpu"
+
+	defaultMemoryUnitMB         = 256
+	defaultHugepagesDir         = "/sys/kernel/mm/hugepages"
+	defaultCapacityPollInterval = 30 * time.Second
+
+	// Environment variables set by Allocate with the amount of enclave
+	// memory in MiB and the number of enclave CPUs granted to the container.
+	envEnclaveMemoryMB = "QT_ENCLAVE_MEMORY_MB"
+	envEnclaveCPUs     = "QT_ENCLAVE_CPUS"
+)
+
+// capacitySource returns the number of units of a resource on the node.
+type capacitySource func() (int, error)
+
+// staticCapacity is a capacity taken from the configuration.
+func staticCapacity(units int) capacitySource {
+	return func() (int, error) {
+		return units, nil
+	}
+}
+
+// hugepagesCapacity counts the units of unitMB in the hugepage pools of the
+// host, summed over all hugepage sizes.
+func hugepagesCapacity(dir string, unitMB int) capacitySource {
+	return func() (int, error) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return 0, err
+		}
+
+		var totalKB uint64
+		for _, e := range entries {
+			size, ok := strings.CutPrefix(e.Name(), "hugepages-")
+			if !ok {
+				continue
+			}
+			sizeKB, err := strconv.ParseUint(strings.TrimSuffix(size, "kB"), 10, 64)
+			if err != nil {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, e.Name(), "nr_hugepages"))
+			if err != nil {
+				return 0, err
+			}
+			pages, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid hugepage count in %s: %v", e.Name(), err)
+			}
+			totalKB += pages * sizeKB
+		}
+
+		return int(totalKB / 1024 / uint64(unitMB)), nil
+	}
+}
+
+// cpuPoolCapacity counts the CPUs listed in a sysfs cpulist file.
+func cpuPoolCapacity(file string) capacitySource {
+	return func() (int, error) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return 0, err
+		}
+		cpus, err := parseCPUList(string(data))
+		if err != nil {
+			return 0, fmt.Errorf("invalid CPU pool in %s: %v", file, err)
+		}
+		return len(cpus), nil
+	}
+}
+
+// parseCPUList parses the kernel cpulist format, such as "2-5,8".
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			last = first
+		}
+		lo, err := strconv.Atoi(first)
+		if err != nil || lo < 0 {
+			return nil, fmt.Errorf("invalid CPU %q", part)
+		}
+		hi, err := strconv.Atoi(last)
+		if err != nil || hi < lo {
+			return nil, fmt.Errorf("invalid CPU range %q", part)
+		}
+		for cpu := lo; cpu <= hi; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	return cpus, nil
+}
+
+// capacityPlugin advertises a counted resource, one device per unit. The
+// number of units follows its source and is re-advertised when it changes.
+type capacityPlugin struct {
+	resource string
+	// unitEnv is set by Allocate to the number of units times unitSize.
+	unitEnv  string
+	unitSize int
+	source   capacitySource
+
+	socket           string
+	regSocket        string
+	paths            kubeletPaths
+	registrationMode string
+	pollInterval     time.Duration
+	access           *socketAccess
+	tracer           *spanExporter
+
+	mu     sync.Mutex
+	units  int
+	update chan struct{}
+	stop   chan interface{}
+	server *grpc.Server
+}
+
+func newCapacityPlugin(paths kubeletPaths, registrationMode, resource, unitEnv string, unitSize int, source capacitySource) *capacityPlugin {
+	name := deviceName + "-" + strings.TrimPrefix(resource, "huawei.com/qt_enclave_")
+	return &capacityPlugin{
+		resource:         resource,
+		unitEnv:          unitEnv,
+		unitSize:         unitSize,
+		source:           source,
+		socket:           filepath.Join(paths.DevicePluginDir, name+".sock"),
+		regSocket:        filepath.Join(paths.PluginsRegistryDir, name+"-reg.sock"),
+		paths:            paths,
+		registrationMode: registrationMode,
+		pollInterval:     defaultCapacityPollInterval,
+		access:           defaultSocketAccess(),
+		update:           make(chan struct{}, 1),
+	}
+}
+
+func (qtcp *capacityPlugin) cleanup() error {
+	for _, sock := range []string{qtcp.socket, qtcp.regSocket} {
+		if err := qtcp.access.remove(sock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refresh reads the capacity and notifies ListAndWatch when it changed.
+func (qtcp *capacityPlugin) refresh() {
+	units, err := qtcp.source()
+	if err != nil {
+		glog.Errorf("Failed to read capacity of %s: %v", qtcp.resource, err)
+		return
+	}
+
+	qtcp.mu.Lock()
+	changed := units != qtcp.units
+	if changed {
+		glog.V(0).Infof("Capacity of %s changed from %d to %d", qtcp.resource, qtcp.units, units)
+		qtcp.units = units
+	}
+	qtcp.mu.Unlock()
+
+	if changed {
+		select {
+		case qtcp.update <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (qtcp *capacityPlugin) watchCapacity() {
+	for {
+		select {
+		case <-qtcp.stop:
+			return
+		case <-time.After(qtcp.pollInterval):
+		}
+		qtcp.refresh()
+	}
+}
+
+func (qtcp *capacityPlugin) listDevices() []*pluginapi.Device {
+	qtcp.mu.Lock()
+	defer qtcp.mu.Unlock()
+
+	ids := make([]string, 0, qtcp.units)
+	for i := 0; i < qtcp.units; i++ {
+		ids = append(ids, strings.TrimPrefix(qtcp.resource, "huawei.com/")+"-"+strconv.Itoa(i))
+	}
+	return newDevices(ids)
+}
+
+func (qtcp *capacityPlugin) GetDevicePluginOptions(context.Context, *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
+	return &pluginapi.DevicePluginOptions{}, nil
+}
+
+// ListAndWatch advertises one device per unit of capacity
+func (qtcp *capacityPlugin) ListAndWatch(e *pluginapi.Empty, s pluginapi.DevicePlugin_ListAndWatchServer) error {
+	s.Send(&pluginapi.ListAndWatchResponse{Devices: qtcp.listDevices()})
+
+	for {
+		select {
+		case <-qtcp.stop:
+			return nil
+		case <-s.Context().Done():
+			return nil
+		case <-qtcp.update:
+			s.Send(&pluginapi.ListAndWatchResponse{Devices: qtcp.listDevices()})
+		}
+	}
+}
+
+func (qtcp *capacityPlugin) GetPreferredAllocation(context.Context, *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
+	return &pluginapi.PreferredAllocationResponse{}, nil
+}
+
+// Allocate tells the container how much of the resource it got
+func (qtcp *capacityPlugin) Allocate(ctx context.Context, reqs *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	responses := pluginapi.AllocateResponse{}
+	for _, req := range reqs.ContainerRequests {
+		responses.ContainerResponses = append(responses.ContainerResponses, &pluginapi.ContainerAllocateResponse{
+			Envs: map[string]string{qtcp.unitEnv: strconv.Itoa(len(req.DevicesIDs) * qtcp.unitSize)},
+		})
+	}
+	return &responses, nil
+}
+
+func (qtcp *capacityPlugin) PreStartContainer(context.Context, *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
+	return &pluginapi.PreStartContainerResponse{}, nil
+}
+
+// GetInfo is called by the kubelet plugin watcher to identify the plugin
+func (qtcp *capacityPlugin) GetInfo(ctx context.Context, req *registerapi.InfoRequest) (*registerapi.PluginInfo, error) {
+	return &registerapi.PluginInfo{
+		Type:              registerapi.DevicePlugin,
+		Name:              qtcp.resource,
+		Endpoint:          qtcp.socket,
+		SupportedVersions: []string{pluginapi.Version},
+	}, nil
+}
+
+// NotifyRegistrationStatus is called by the kubelet plugin watcher with the registration result
+func (qtcp *capacityPlugin) NotifyRegistrationStatus(ctx context.Context, status *registerapi.RegistrationStatus) (*registerapi.RegistrationStatusResponse, error) {
+	if !status.PluginRegistered {
+		glog.Errorf("Plugin watcher failed to register %s! (Reason: %s)", qtcp.resource, status.Error)
+	}
+	return &registerapi.RegistrationStatusResponse{}, nil
+}
+
+// Start capacity plugin server
+func (qtcp *capacityPlugin) Start() error {
+	if err := qtcp.cleanup(); err != nil {
+		return err
+	}
+	glog.V(0).Info("Starting capacity plugin server for ", qtcp.resource)
+
+	sock, err := qtcp.access.listen(qtcp.socket)
+	if err != nil {
+		glog.Error("Error while creating socket: ", qtcp.socket)
+		return err
+	}
+
+	qtcp.server = grpc.NewServer(append(rpcServerOptions(qtcp.resource, qtcp.tracer), qtcp.access.serverOptions()...)...)
+	pluginapi.RegisterDevicePluginServer(qtcp.server, qtcp)
+	qtcp.stop = make(chan interface{})
+
+	if qtcp.registrationMode == registrationModePluginWatcher {
+		regSock, err := qtcp.access.listen(qtcp.regSocket)
+		if err != nil {
+			glog.Error("Error while creating socket: ", qtcp.regSocket)
+			sock.Close()
+			return err
+		}
+		registerapi.RegisterRegistrationServer(qtcp.server, qtcp)
+		go qtcp.server.Serve(regSock)
+	}
+
+	go qtcp.server.Serve(sock)
+
+	conn, err := dial(qtcp.socket, devicePluginServerReadyTimeout)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+
+	qtcp.refresh()
+
+	if qtcp.registrationMode != registrationModePluginWatcher {
+		if err := registerWithKubelet(qtcp.access, qtcp.paths.KubeletSocket, qtcp.socket, qtcp.resource); err != nil {
+			glog.Errorf("Error while registering %s with kubelet! (Reason: %s)", qtcp.resource, err)
+			qtcp.Stop()
+			return err
+		}
+		glog.V(0).Info("Registered device plugin with Kubelet: ", qtcp.resource)
+	}
+
+	go qtcp.watchCapacity()
+
+	return nil
+}
+
+// Stop capacity plugin server
+func (qtcp *capacityPlugin) Stop() error {
+	if qtcp.server != nil {
+		qtcp.server.Stop()
+		qtcp.server = nil
+		close(qtcp.stop)
+	}
+	return qtcp.cleanup()
+}
+
+// pluginGroup starts and stops several device plugins together.
+type pluginGroup []IBasicDevicePlugin
+
+func (g pluginGroup) Start() error {
+	for i, p := range g {
+		if err := p.Start(); err != nil {
+			for _, started := range g[:i] {
+				started.Stop()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (g pluginGroup) Stop() error {
+	var firstErr error
+	for _, p := range g {
+		if err := p.Stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,110 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide enclave capacity resources testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/qt-enclave-k8s-device-plugin/fakekubelet"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func writeHugepages(t *testing.T, dir, size, pages string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, "hugepages-"+size), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hugepages-"+size, "nr_hugepages"), []byte(pages+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHugepagesCapacity(t *testing.T) {
+	dir := t.TempDir()
+	writeHugepages(t, dir, "2048kB", "256")
+	writeHugepages(t, dir, "1048576kB", "2")
+
+	// 512 MiB of 2M pages and 2 GiB of 1G pages in 256 MiB units.
+	units, err := hugepagesCapacity(dir, 256)()
+	if err != nil || units != 10 {
+		t.Fatalf("Expected 10 units but got %d: %v", units, err)
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := parseCPUList("2-5,8\n")
+	if err != nil || len(cpus) != 5 || cpus[0] != 2 || cpus[4] != 8 {
+		t.Fatalf("Unexpected CPUs %v: %v", cpus, err)
+	}
+	if cpus, err := parseCPUList(""); err != nil || len(cpus) != 0 {
+		t.Fatalf("Empty list should have no CPUs, got %v: %v", cpus, err)
+	}
+	for _, list := range []string{"5-2", "a", "-1"} {
+		if _, err := parseCPUList(list); err == nil {
+			t.Fatalf("CPU list %q should be rejected", list)
+		}
+	}
+}
+
+func TestCapacityPluginReadvertises(t *testing.T) {
+	paths := newKubeletPaths(t.TempDir())
+	if err := os.MkdirAll(paths.DevicePluginDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	kubelet := fakekubelet.New(paths.DevicePluginDir)
+	if err := kubelet.Start(); err != nil {
+		t.Fatalf("Failed to start fake kubelet: %v", err)
+	}
+	t.Cleanup(kubelet.Stop)
+
+	pool := filepath.Join(t.TempDir(), "cpus")
+	if err := os.WriteFile(pool, []byte("2-3\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	qtcp := newCapacityPlugin(paths, registrationModeDevicePlugin, cpuResourceName, envEnclaveCPUs, 1, cpuPoolCapacity(pool))
+	qtcp.pollInterval = 50 * time.Millisecond
+	if err := qtcp.Start(); err != nil {
+		t.Fatalf("Failed to start capacity plugin: %v", err)
+	}
+	t.Cleanup(func() { qtcp.Stop() })
+
+	p, err := kubelet.WaitForPlugin(cpuResourceName, e2eTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.WaitForDevices(func(devs []*pluginapi.Device) bool { return len(devs) == 2 }, e2eTimeout); err != nil {
+		t.Fatalf("CPU pool not advertised: %v", err)
+	}
+
+	if err := os.WriteFile(pool, []byte("2-5\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	devs, err := p.WaitForDevices(func(devs []*pluginapi.Device) bool { return len(devs) == 4 }, e2eTimeout)
+	if err != nil {
+		t.Fatalf("Grown CPU pool not re-advertised: %v", err)
+	}
+
+	resp, err := p.Allocate([]string{devs[0].ID, devs[1].ID})
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	if got := resp.ContainerResponses[0].Envs[envEnclaveCPUs]; got != "2" {
+		t.Fatalf("Expected %s=2 but got %q", envEnclaveCPUs, got)
+	}
+}
@@ -0,0 +1,291 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the check of the kubelet CPU manager against the enclave CPU pool
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	cpuOverlapLog          = "log"
+	cpuOverlapMetric       = "metric"
+	cpuOverlapCondition    = "node-condition"
+	cpuOverlapDeviceHealth = "device-health"
+
+	defaultCPUOverlapReport = cpuOverlapLog + "," + cpuOverlapMetric
+	cpuOverlapConditionType = "QtEnclaveCPUOverlap"
+)
+
+var cpuOverlapCPUs = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "qt_enclave_device_plugin",
+	Name:      "cpu_overlap_cpus",
+	Help:      "CPUs of the enclave CPU pool the kubelet CPU manager assigned to exclusive containers.",
+})
+
+func init() {
+	metricsRegistry.MustRegister(cpuOverlapCPUs)
+}
+
+// cpuManagerCheckpoint is the kubelet cpu_manager_state file. Entries maps
+// pod UIDs to the cpuset of each container with exclusive CPUs.
+type cpuManagerCheckpoint struct {
+	PolicyName    string                       `json:"policyName"`
+	DefaultCPUSet string                       `json:"defaultCpuSet"`
+	Entries       map[string]map[string]string `json:"entries,omitempty"`
+}
+
+// cpuConflict is a CPU of the enclave pool assigned to a container.
+type cpuConflict struct {
+	CPU       int
+	PodUID    string
+	Container string
+}
+
+func (c cpuConflict) String() string {
+	return fmt.Sprintf("cpu%d (pod %s container %s)", c.CPU, c.PodUID, c.Container)
+}
+
+// parseCPUOverlapReport parses the comma separated ways to report an
+// overlap.
+func parseCPUOverlapReport(list string) (map[string]bool, error) {
+	report := map[string]bool{}
+	for _, r := range strings.Split(list, ",") {
+		switch r = strings.TrimSpace(r); r {
+		case "":
+		case cpuOverlapLog, cpuOverlapMetric, cpuOverlapCondition, cpuOverlapDeviceHealth:
+			report[r] = true
+		default:
+			return nil, fmt.Errorf("invalid CPU overlap report %q, expected log, metric, node-condition or device-health", r)
+		}
+	}
+	return report, nil
+}
+
+// readCPUManagerState returns the exclusive CPUs of the checkpoint. A
+// missing checkpoint has none: the CPU manager is not running.
+func readCPUManagerState(file string) (map[int]cpuConflict, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint cpuManagerCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid CPU manager checkpoint %s: %v", file, err)
+	}
+	exclusive := map[int]cpuConflict{}
+	for uid, containers := range checkpoint.Entries {
+		for name, set := range containers {
+			cpus, err := parseCPUList(set)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cpuset of pod %s container %s in %s: %v", uid, name, file, err)
+			}
+			for _, cpu := range cpus {
+				exclusive[cpu] = cpuConflict{CPU: cpu, PodUID: uid, Container: name}
+			}
+		}
+	}
+	return exclusive, nil
+}
+
+// nodeAPI is the part of the Kubernetes API the overlap check reports
+// through.
+type nodeAPI interface {
+	nodeCondition(ctx context.Context, node string, conditionType corev1.NodeConditionType) (*corev1.NodeCondition, error)
+	setNodeCondition(ctx context.Context, node string, condition corev1.NodeCondition) error
+}
+
+// cpuOverlapChecker compares the CPUs the kubelet CPU manager assigned to
+// exclusive containers with the enclave CPU pools.
+type cpuOverlapChecker struct {
+	stateFile string
+	poolFiles []string
+	report    map[string]bool
+	node      string
+	api       nodeAPI
+
+	mu        sync.Mutex
+	conflicts []cpuConflict
+	// summary and conditionSet track what was last reported.
+	summary      string
+	checked      bool
+	conditionSet bool
+	// condition is the node condition last set, nil until the first one.
+	condition *corev1.NodeCondition
+}
+
+func newCPUOverlapChecker(stateFile string, poolFiles []string, report map[string]bool) *cpuOverlapChecker {
+	return &cpuOverlapChecker{stateFile: stateFile, poolFiles: poolFiles, report: report}
+}
+
+// check returns the CPUs of the enclave pools assigned to containers.
+func (c *cpuOverlapChecker) check() ([]cpuConflict, error) {
+	exclusive, err := readCPUManagerState(c.stateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []cpuConflict
+	for _, file := range c.poolFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		cpus, err := parseCPUList(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU pool in %s: %v", file, err)
+		}
+		for _, cpu := range cpus {
+			if conflict, ok := exclusive[cpu]; ok {
+				conflicts = append(conflicts, conflict)
+				delete(exclusive, cpu)
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].CPU < conflicts[j].CPU })
+	return conflicts, nil
+}
+
+// update reports the conflicts found by a check.
+func (c *cpuOverlapChecker) update(conflicts []cpuConflict) {
+	var parts []string
+	for _, conflict := range conflicts {
+		parts = append(parts, conflict.String())
+	}
+	summary := strings.Join(parts, ", ")
+
+	c.mu.Lock()
+	changed := !c.checked || summary != c.summary
+	c.conflicts, c.summary, c.checked = conflicts, summary, true
+	if changed {
+		c.conditionSet = false
+	}
+	setCondition := c.report[cpuOverlapCondition] && !c.conditionSet
+	c.mu.Unlock()
+
+	if c.report[cpuOverlapMetric] {
+		cpuOverlapCPUs.Set(float64(len(conflicts)))
+	}
+	if changed && c.report[cpuOverlapLog] {
+		if len(conflicts) > 0 {
+			glog.Errorf("Kubelet CPU manager assigned enclave CPUs to exclusive containers: %s", summary)
+		} else {
+			glog.V(0).Info("No enclave CPU assigned by the kubelet CPU manager")
+		}
+	}
+	if setCondition {
+		if err := c.setCondition(conflicts, summary); err != nil {
+			glog.Errorf("Failed to set node condition %s: %v", cpuOverlapConditionType, err)
+			return
+		}
+		c.mu.Lock()
+		if c.summary == summary {
+			c.conditionSet = true
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *cpuOverlapChecker) setCondition(conflicts []cpuConflict, summary string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), remediationTimeout)
+	defer cancel()
+
+	now := metav1.Now()
+	condition := corev1.NodeCondition{
+		Type:               cpuOverlapConditionType,
+		Status:             corev1.ConditionFalse,
+		Reason:             "NoOverlap",
+		Message:            "No enclave CPU is assigned to an exclusive container",
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+	if len(conflicts) > 0 {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = "ExclusiveCPUsInEnclavePool"
+		condition.Message = strconv.Itoa(len(conflicts)) + " enclave CPUs assigned to exclusive containers: " + summary
+	}
+
+	// The transition time only moves when the status flips, also across
+	// restarts of the daemon.
+	c.mu.Lock()
+	last := c.condition
+	c.mu.Unlock()
+	if last == nil {
+		var err error
+		if last, err = c.api.nodeCondition(ctx, c.node, cpuOverlapConditionType); err != nil {
+			return err
+		}
+	}
+	if last != nil && last.Status == condition.Status {
+		condition.LastTransitionTime = last.LastTransitionTime
+	}
+
+	if err := c.api.setNodeCondition(ctx, c.node, condition); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.condition = &condition
+	c.mu.Unlock()
+	return nil
+}
+
+// run checks the overlap every interval until stop is closed.
+func (c *cpuOverlapChecker) run(interval time.Duration, stop <-chan struct{}) {
+	for {
+		conflicts, err := c.check()
+		if err != nil {
+			glog.Errorf("Failed to check the kubelet CPU manager state: %v", err)
+		} else {
+			c.update(conflicts)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// healthErr returns the overlap as a device failure when it is reported
+// through the device health, nil-safe.
+func (c *cpuOverlapChecker) healthErr() error {
+	if c == nil || !c.report[cpuOverlapDeviceHealth] {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.conflicts) == 0 {
+		return nil
+	}
+	return fmt.Errorf("enclave CPUs assigned to exclusive containers: %s", c.summary)
+}
@@ -0,0 +1,184 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the check of the kubelet CPU manager against the enclave CPU pool testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testCPUManagerState = "testdata/cpu_manager_state"
+
+func TestCPUOverlap(t *testing.T) {
+	dir := t.TempDir()
+	pool := filepath.Join(dir, "enclave_cpus")
+	nitroPool := filepath.Join(dir, "ne_cpus")
+	writeTestFile(t, pool, "3-4\n")
+	writeTestFile(t, nitroPool, "8-9,16\n")
+
+	checker := newCPUOverlapChecker(testCPUManagerState, []string{pool, nitroPool}, map[string]bool{})
+	conflicts, err := checker.check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cpus []string
+	for _, c := range conflicts {
+		cpus = append(cpus, c.String())
+	}
+	expected := []string{
+		"cpu3 (pod 0d4a6f6e-9b8c-4c3e-8a1f-2f1d6c0e7b21 container app)",
+		"cpu4 (pod 7c1e2b9a-4f3d-4e5b-9c6a-1b2d3e4f5a6b container db)",
+		"cpu16 (pod 7c1e2b9a-4f3d-4e5b-9c6a-1b2d3e4f5a6b container sidecar)",
+	}
+	if strings.Join(cpus, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Unexpected conflicts %v", cpus)
+	}
+
+	// CPUs of the shared pool are not a conflict, nor is a missing checkpoint.
+	writeTestFile(t, pool, "0-1\n")
+	writeTestFile(t, nitroPool, "6\n")
+	if conflicts, err := checker.check(); err != nil || len(conflicts) != 0 {
+		t.Fatalf("Expected no conflict, got %v: %v", conflicts, err)
+	}
+	checker.stateFile = filepath.Join(dir, "cpu_manager_state")
+	if conflicts, err := checker.check(); err != nil || len(conflicts) != 0 {
+		t.Fatalf("Expected no conflict without checkpoint, got %v: %v", conflicts, err)
+	}
+
+	writeTestFile(t, checker.stateFile, `{"policyName":"static","entries":{"uid":{"app":"x"}}}`)
+	if _, err := checker.check(); err == nil {
+		t.Fatal("Invalid checkpoint should be rejected")
+	}
+}
+
+func TestCPUOverlapReport(t *testing.T) {
+	if _, err := parseCPUOverlapReport("log,taint"); err == nil {
+		t.Fatal("Unknown report should be rejected")
+	}
+	report, err := parseCPUOverlapReport("metric, node-condition,device-health")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := &fakeAPIServer{}
+	pool := filepath.Join(t.TempDir(), "enclave_cpus")
+	writeTestFile(t, pool, "2-3\n")
+	checker := newCPUOverlapChecker(testCPUManagerState, []string{pool}, report)
+	checker.node = "node-a"
+	checker.api = newTestKubeClient(t, api)
+
+	qtedp := newTestAllocationPlugin()
+	qtedp.cpuOverlap = checker
+	if err := qtedp.cpuOverlap.healthErr(); err != nil {
+		t.Fatalf("Devices should be healthy before the first check: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		conflicts, err := checker.check()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checker.update(conflicts)
+	}
+	if n := testutil.ToFloat64(cpuOverlapCPUs); n != 2 {
+		t.Fatalf("Expected 2 overlapping CPUs, got %v", n)
+	}
+	if err := qtedp.cpuOverlap.healthErr(); err == nil || !strings.Contains(err.Error(), "cpu2") {
+		t.Fatalf("Overlap should fail the devices, got %v", err)
+	}
+	if len(api.requests) != 2 || api.requests[0] != "GET /api/v1/nodes/node-a  Bearer secret" ||
+		api.requests[1] != "PATCH /api/v1/nodes/node-a/status application/strategic-merge-patch+json Bearer secret" {
+		t.Fatalf("Expected the node condition to be read and set once, got %v", api.requests)
+	}
+	var patch struct {
+		Status struct{ Conditions []corev1.NodeCondition }
+	}
+	if err := json.Unmarshal([]byte(api.bodies[1]), &patch); err != nil || len(patch.Status.Conditions) != 1 ||
+		patch.Status.Conditions[0].Type != cpuOverlapConditionType || patch.Status.Conditions[0].Status != "True" {
+		t.Fatalf("Unexpected node status patch %s: %v", api.bodies[1], err)
+	}
+
+	checker.update(nil)
+	if err := qtedp.cpuOverlap.healthErr(); err != nil {
+		t.Fatalf("Devices should recover with the overlap, got %v", err)
+	}
+	if len(api.requests) != 3 || !strings.Contains(api.bodies[2], `"status":"False"`) {
+		t.Fatalf("Expected the node condition to be cleared, got %v", api.bodies)
+	}
+}
+
+// fakeNodeAPI keeps the node condition set instead of calling an API server.
+type fakeNodeAPI struct {
+	condition *corev1.NodeCondition
+}
+
+func (f *fakeNodeAPI) nodeCondition(ctx context.Context, node string, conditionType corev1.NodeConditionType) (*corev1.NodeCondition, error) {
+	return f.condition, nil
+}
+
+func (f *fakeNodeAPI) setNodeCondition(ctx context.Context, node string, condition corev1.NodeCondition) error {
+	f.condition = &condition
+	return nil
+}
+
+func TestCPUOverlapConditionTransition(t *testing.T) {
+	set := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	api := &fakeNodeAPI{condition: &corev1.NodeCondition{Type: cpuOverlapConditionType, Status: corev1.ConditionFalse,
+		LastTransitionTime: set}}
+	checker := newCPUOverlapChecker(testCPUManagerState, nil, map[string]bool{cpuOverlapCondition: true})
+	checker.node = "node-a"
+	checker.api = api
+
+	// The condition set before a restart keeps its transition time.
+	checker.update(nil)
+	if !api.condition.LastTransitionTime.Equal(&set) || api.condition.LastHeartbeatTime.Equal(&set) {
+		t.Fatalf("Unchanged status should keep its transition time, got %+v", api.condition)
+	}
+
+	checker.update([]cpuConflict{{CPU: 2, PodUID: "uid", Container: "app"}})
+	flipped := api.condition.LastTransitionTime
+	if api.condition.Status != corev1.ConditionTrue || flipped.Equal(&set) {
+		t.Fatalf("Status flip should move the transition time, got %+v", api.condition)
+	}
+	checker.update([]cpuConflict{{CPU: 3, PodUID: "uid", Container: "app"}})
+	if !api.condition.LastTransitionTime.Equal(&flipped) || !strings.Contains(api.condition.Message, "cpu3") {
+		t.Fatalf("New conflicts of the same status should keep the transition time, got %+v", api.condition)
+	}
+}
+
+func TestCPUOverlapRunStops(t *testing.T) {
+	checker := newCPUOverlapChecker(filepath.Join(t.TempDir(), "cpu_manager_state"), nil, map[string]bool{cpuOverlapLog: true})
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		checker.run(time.Hour, stop)
+		close(done)
+	}()
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Overlap check did not stop")
+	}
+}
@@ -16,22 +16,26 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net"
-	"os"
-	"path"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	registerapi "k8s.io/kubelet/pkg/apis/pluginregistration/v1"
 )
 
 const (
 	deviceName                      = "qtbox_service"
-	socketPath                      = pluginapi.DevicePluginPath + deviceName + ".sock"
+	socketName                      = deviceName + ".sock"
 	resourceName                    = "huawei.com/qt_enclaves"
 	devicePluginServerReadyTimeout  = 10 * time.Second
 	devicePluginHealthCheckInterval = 5 * time.Second
@@ -50,6 +54,60 @@ type QtEnclavesDevicePlugin struct {
 	devs   []*pluginapi.Device
 	socket string
 
+	paths            kubeletPaths
+	registrationMode string
+	regSocket        string
+
+	// mu guards the device health, cordons and allocations, which are
+	// updated by the health check while kubelet calls are served.
+	mu          sync.Mutex
+	cordonFile  string
+	cordoned    map[string]bool
+	allocations map[string]time.Time
+	// allocating holds the devices of the Allocate calls in progress.
+	allocating map[string]bool
+	// doubleBookingCheck is how devices allocated before are checked,
+	// empty is doubleBookingFailOpen.
+	doubleBookingCheck string
+	// releaseTracked is set once something reports released devices, so the
+	// allocations can be trusted to reject double-booking across requests.
+	releaseTracked bool
+
+	pathMapper  containerPathMapper
+	permissions string
+
+	releaseDetection      bool
+	releasePollInterval   time.Duration
+	releaseCleanupCommand string
+	releaseCleanupTimeout time.Duration
+	// inUse is the last PodResources snapshot, releasing holds the released
+	// devices waiting for their cleanup.
+	inUse     map[string]podRef
+	releasing map[string]podRef
+
+	hooks *hookRunner
+	// cids assigns a vsock CID to every allocated device, nil if disabled.
+	cids  *cidPool
+	audit *auditLog
+	// helper runs the release cleanup when the daemon dropped its privileges.
+	helper *privHelper
+	// remediation acts on the pods of failed devices, nil if disabled.
+	remediation *remediator
+	// cpuOverlap fails the devices while the kubelet CPU manager uses the
+	// enclave CPUs, nil unless reported through the device health.
+	cpuOverlap *cpuOverlapChecker
+	// attestation provides the evidence of the devices to the containers,
+	// nil if disabled.
+	attestation *attestation
+	// keyBroker releases secrets to the attested enclaves, nil if disabled.
+	keyBroker *keyBroker
+
+	provider            EnclaveProvider
+	access              *socketAccess
+	tracer              *spanExporter
+	notifier            *sdNotifier
+	healthCheckInterval time.Duration
+
 	stop   chan interface{}
 	health chan *pluginapi.Device
 
@@ -58,6 +116,7 @@ type QtEnclavesDevicePlugin struct {
 	IBasicDevicePlugin
 }
 
+// devicePath returns the path of a device inside the container.
 func devicePath(deviceId string) string {
 	return "/dev/" + deviceId
 }
@@ -69,8 +128,10 @@ func generateDeviceID(deviceName string) string {
 }
 
 func (qtedp *QtEnclavesDevicePlugin) cleanup() error {
-	if err := os.Remove(qtedp.socket); err != nil && !os.IsNotExist(err) {
-		return err
+	for _, sock := range []string{qtedp.socket, qtedp.regSocket} {
+		if err := qtedp.access.remove(sock); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -93,9 +154,14 @@ func dial(unixSocketPath string, timeout time.Duration) (*grpc.ClientConn, error
 }
 
 func (qtedp *QtEnclavesDevicePlugin) register(kubeletEndpoint, resourceName string) error {
+	return registerWithKubelet(qtedp.access, kubeletEndpoint, qtedp.socket, resourceName)
+}
+
+// registerWithKubelet registers the plugin served on socket for resourceName.
+func registerWithKubelet(access *socketAccess, kubeletEndpoint, socket, resourceName string) error {
 	glog.V(0).Info("Attempting to connect to kubelet...")
 
-	conn, err := dial(kubeletEndpoint, devicePluginServerReadyTimeout)
+	conn, err := access.dial(kubeletEndpoint, devicePluginServerReadyTimeout)
 	if err != nil {
 		return err
 	}
@@ -106,7 +172,7 @@ func (qtedp *QtEnclavesDevicePlugin) register(kubeletEndpoint, resourceName stri
 	client := pluginapi.NewRegistrationClient(conn)
 	_, err = client.Register(context.Background(), &pluginapi.RegisterRequest{
 		Version:      pluginapi.Version,
-		Endpoint:     path.Base(qtedp.socket),
+		Endpoint:     filepath.Base(socket),
 		ResourceName: resourceName,
 	})
 
@@ -114,76 +180,278 @@ func (qtedp *QtEnclavesDevicePlugin) register(kubeletEndpoint, resourceName stri
 }
 
 func (qtedp *QtEnclavesDevicePlugin) healthcheck() {
+	loop := "health check of " + qtedp.provider.resourceName()
+	qtedp.notifier.watch(loop, qtedp.healthCheckInterval+healthCheckStallSlack)
+	defer qtedp.notifier.unwatch(loop)
+
 	for {
+		qtedp.notifier.beat(loop)
 		select {
 		case <-qtedp.stop:
 			return
 		default:
 		}
-		for _, dev := range qtedp.devs {
-			tmpHealth := dev.Health
-			devPath := devicePath(dev.ID)
-			_, err := os.Stat(devPath)
-			if err != nil {
-				if os.IsNotExist(err) {
-					glog.Error("Device is not exist:", devPath)
-				} else {
-					glog.Error("Device %s: %v", devPath, err)
-				}
+		qtedp.reloadCordons()
+		if !qtedp.refreshDevices() {
+			return
+		}
+		for _, dev := range qtedp.currentDevices() {
+			tmpHealth := pluginapi.Healthy
+			checkErr := qtedp.provider.check(dev.ID)
+			// Pods are not at fault for an overlap with the CPU manager.
+			qtedp.remediation.observe(dev.ID, checkErr, time.Now())
+			if checkErr == nil {
+				checkErr = qtedp.cpuOverlap.healthErr()
+			}
+			if checkErr != nil {
+				glog.Errorf("Device %s: %v", dev.ID, checkErr)
 				tmpHealth = pluginapi.Unhealthy
-			} else {
-				tmpHealth = pluginapi.Healthy
 			}
 
-			if dev.Health != tmpHealth {
-				dev.Health = tmpHealth
-				qtedp.health <- dev
+			qtedp.mu.Lock()
+			// Cordoned devices and released devices waiting for cleanup are
+			// advertised as unhealthy so that kubelet does not hand them out.
+			if _, ok := qtedp.releasing[dev.ID]; ok || qtedp.cordoned[dev.ID] {
+				tmpHealth = pluginapi.Unhealthy
+			}
+			changed := dev.Health != tmpHealth
+			dev.Health = tmpHealth
+			pod := qtedp.inUse[dev.ID].ref()
+			qtedp.mu.Unlock()
+
+			if changed {
+				qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditHealth, Devices: []string{dev.ID}, Pod: pod, Health: tmpHealth}, checkErr)
+				qtedp.hooks.notify(hookHealthChange, []string{dev.ID}, pod, tmpHealth)
+				if !qtedp.notify(dev) {
+					return
+				}
 			}
 		}
-		time.Sleep(devicePluginHealthCheckInterval)
+		select {
+		case <-qtedp.stop:
+			return
+		case <-time.After(qtedp.healthCheckInterval):
+		}
 	}
 }
 
-func (qtedp *QtEnclavesDevicePlugin) deviceExists(id string) bool {
+// refreshDevices adds the devices newly discovered by the provider and drops
+// the ones that disappeared. It returns false when the plugin stopped.
+func (qtedp *QtEnclavesDevicePlugin) refreshDevices() bool {
+	ids, err := qtedp.provider.discover()
+	if err != nil {
+		glog.Errorf("Failed to discover devices: %v", err)
+		return true
+	}
+
+	found := map[string]bool{}
+	for _, id := range ids {
+		found[id] = true
+	}
+
+	var changed []*pluginapi.Device
+	var removed []string
+	qtedp.mu.Lock()
+	devs := []*pluginapi.Device{}
 	for _, d := range qtedp.devs {
-		if d.ID == id {
-			return true
+		if found[d.ID] {
+			devs = append(devs, d)
+			delete(found, d.ID)
+		} else {
+			glog.V(0).Infof("Device %s removed", d.ID)
+			changed = append(changed, d)
+			removed = append(removed, d.ID)
+		}
+	}
+	for _, id := range ids {
+		if found[id] {
+			glog.V(0).Infof("Device %s discovered", id)
+			d := &pluginapi.Device{ID: id, Health: pluginapi.Healthy}
+			devs = append(devs, d)
+			changed = append(changed, d)
+		}
+	}
+	qtedp.devs = devs
+	qtedp.mu.Unlock()
+	qtedp.remediation.forget(removed)
+
+	for _, d := range changed {
+		if !qtedp.notify(d) {
+			return false
 		}
 	}
-	return false
+	return true
+}
+
+// currentDevices returns the devices managed right now.
+func (qtedp *QtEnclavesDevicePlugin) currentDevices() []*pluginapi.Device {
+	qtedp.mu.Lock()
+	defer qtedp.mu.Unlock()
+	return append([]*pluginapi.Device{}, qtedp.devs...)
+}
+
+// notify tells ListAndWatch that the device list changed. It never blocks:
+// a pending update already sends the whole list, so further ones are
+// coalesced into it. It returns false when the plugin stopped.
+func (qtedp *QtEnclavesDevicePlugin) notify(dev *pluginapi.Device) bool {
+	select {
+	case <-qtedp.stop:
+		return false
+	default:
+	}
+	select {
+	case qtedp.health <- dev:
+	default:
+	}
+	return true
+}
+
+// listDevices returns a snapshot of the devices, safe to send to kubelet
+// while the health check keeps updating them.
+func (qtedp *QtEnclavesDevicePlugin) listDevices() []*pluginapi.Device {
+	qtedp.mu.Lock()
+	defer qtedp.mu.Unlock()
+
+	devs := make([]*pluginapi.Device, 0, len(qtedp.devs))
+	for _, d := range qtedp.devs {
+		devs = append(devs, &pluginapi.Device{ID: d.ID, Health: d.Health, Topology: d.Topology})
+	}
+	return devs
 }
 
 // Allocate is called during container creation so that the Device
 // Plugin can run device specific operations and instruct Kubelet
 // of the steps to make the Device available in the container
 func (qtedp *QtEnclavesDevicePlugin) Allocate(ctx context.Context, reqs *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
-	responses := pluginapi.AllocateResponse{}
+	// The devices are reserved with the validation, the PodResources call
+	// runs without the lock.
+	qtedp.mu.Lock()
+	allocated, err := qtedp.validateAllocation(reqs)
+	if err == nil {
+		qtedp.recordAllocation(reqs)
+	}
+	qtedp.mu.Unlock()
+	var holder *podRef
+	if err == nil {
+		defer qtedp.finishAllocation(reqs)
+		if len(allocated) > 0 {
+			if holder, err = qtedp.checkDoubleBooking(allocated); err != nil {
+				qtedp.mu.Lock()
+				qtedp.forgetAllocation(reqs, allocated, nil)
+				qtedp.mu.Unlock()
+			}
+		}
+	}
+	if err != nil {
+		glog.Error(err)
+		qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditAllocate, Devices: requestedDevices(reqs), Pod: holder}, err)
+		return nil, err
+	}
+
+	var cids [][]uint32
+	var assigned []string
 	for _, req := range reqs.ContainerRequests {
+		c, taken, err := qtedp.cids.assign(req.DevicesIDs)
+		assigned = append(assigned, taken...)
+		if err != nil {
+			glog.Error(err)
+			qtedp.mu.Lock()
+			qtedp.forgetAllocation(reqs, allocated, assigned)
+			qtedp.mu.Unlock()
+			qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditAllocate, Devices: requestedDevices(reqs)}, err)
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		cids = append(cids, c)
+	}
+
+	if err := qtedp.hooks.run(hookAllocate, requestedDevices(reqs), nil, ""); err != nil {
+		glog.Error(err)
+		qtedp.mu.Lock()
+		qtedp.forgetAllocation(reqs, allocated, assigned)
+		qtedp.mu.Unlock()
+		qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditAllocate, Devices: requestedDevices(reqs)}, err)
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	mounts, err := qtedp.prepareMounts(reqs)
+	if err != nil {
+		glog.Error(err)
+		qtedp.mu.Lock()
+		qtedp.forgetAllocation(reqs, allocated, assigned)
+		qtedp.mu.Unlock()
+		qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditAllocate, Devices: requestedDevices(reqs)}, err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditAllocate, Devices: requestedDevices(reqs)}, nil)
+
+	responses := pluginapi.AllocateResponse{}
+	for n, req := range reqs.ContainerRequests {
 		var devicesList []*pluginapi.DeviceSpec
-		for _, id := range req.DevicesIDs {
-			if !qtedp.deviceExists(id) {
-				return nil, fmt.Errorf("invalid allocation request: unknown device: %s", id)
-			}
+		mounted := map[string]bool{}
+		for i, id := range req.DevicesIDs {
 			glog.V(1).Info("Allocation request for device ID: ", id)
 
-			ds := &pluginapi.DeviceSpec{
-				ContainerPath: devicePath(id),
-				HostPath:      devicePath(id),
-				Permissions:   "rw",
+			// Devices of some providers share their nodes, mount them once.
+			for _, ds := range qtedp.provider.deviceSpecs(id, qtedp.pathMapper.containerPath(id, i)) {
+				if mounted[ds.HostPath] {
+					continue
+				}
+				mounted[ds.HostPath] = true
+				ds.Permissions = qtedp.permissions
+				devicesList = append(devicesList, ds)
 			}
-			devicesList = append(devicesList, ds)
 		}
 
-		responses.ContainerResponses = append(responses.ContainerResponses, &pluginapi.ContainerAllocateResponse{
+		if len(cids[n]) > 0 {
+			glog.V(0).Infof("Assigned vsock CIDs %v to devices %v", cids[n], req.DevicesIDs)
+		}
+
+		resp := &pluginapi.ContainerAllocateResponse{
+			Envs:    cidEnvs(cids[n]),
 			Devices: devicesList,
-		})
+		}
+		if mounts != nil {
+			resp.Mounts = mounts[n]
+		}
+		responses.ContainerResponses = append(responses.ContainerResponses, resp)
 	}
 
 	return &responses, nil
 }
 
+// prepareMounts creates the attestation evidence and secret directories of
+// every container and returns their mounts.
+func (qtedp *QtEnclavesDevicePlugin) prepareMounts(reqs *pluginapi.AllocateRequest) ([][]*pluginapi.Mount, error) {
+	if qtedp.attestation == nil && qtedp.keyBroker == nil {
+		return nil, nil
+	}
+
+	var mounts [][]*pluginapi.Mount
+	for _, req := range reqs.ContainerRequests {
+		var container []*pluginapi.Mount
+		if qtedp.attestation != nil {
+			m, err := qtedp.attestation.prepare(req.DevicesIDs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to prepare attestation evidence of %v: %v", req.DevicesIDs, err)
+			}
+			container = append(container, m)
+		}
+		if qtedp.keyBroker != nil {
+			m, err := qtedp.keyBroker.prepare(req.DevicesIDs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to prepare secrets of %v: %v", req.DevicesIDs, err)
+			}
+			container = append(container, m)
+		}
+		mounts = append(mounts, container)
+	}
+	return mounts, nil
+}
+
 func (qtedp *QtEnclavesDevicePlugin) GetDevicePluginOptions(context.Context, *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
-	return &pluginapi.DevicePluginOptions{}, nil
+	return &pluginapi.DevicePluginOptions{
+		PreStartRequired: qtedp.hooks.enabled(hookPreStart) || qtedp.attestation != nil || qtedp.audit != nil,
+	}, nil
 }
 
 func (qtedp *QtEnclavesDevicePlugin) GetPreferredAllocation(context.Context, *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
@@ -192,24 +460,95 @@ func (qtedp *QtEnclavesDevicePlugin) GetPreferredAllocation(context.Context, *pl
 
 // ListAndWatch lists devices and update that list according to the health status
 func (qtedp *QtEnclavesDevicePlugin) ListAndWatch(e *pluginapi.Empty, s pluginapi.DevicePlugin_ListAndWatchServer) error {
-	s.Send(&pluginapi.ListAndWatchResponse{Devices: qtedp.devs})
+	s.Send(&pluginapi.ListAndWatchResponse{Devices: qtedp.listDevices()})
 
 	for {
 		select {
 		case <-qtedp.stop:
 			glog.V(0).Infof("Device stopped")
 			return nil
+		case <-s.Context().Done():
+			glog.V(0).Infof("ListAndWatch closed by kubelet")
+			return nil
 		case d := <-qtedp.health:
-			glog.V(1).Infof("Device %s health changed to: %s", d.ID, d.Health)
-			s.Send(&pluginapi.ListAndWatchResponse{Devices: qtedp.devs})
+			glog.V(1).Infof("Device %s health changed", d.ID)
+			s.Send(&pluginapi.ListAndWatchResponse{Devices: qtedp.listDevices()})
 		}
 	}
 }
 
-func (qtedp *QtEnclavesDevicePlugin) PreStartContainer(context.Context, *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
+// PreStartContainer is called before each container start when PreStartRequired is set
+func (qtedp *QtEnclavesDevicePlugin) PreStartContainer(ctx context.Context, req *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
+	var evidence []*attestationEvidence
+	err := qtedp.provider.preStart(req.DevicesIDs)
+	if err == nil {
+		evidence, err = qtedp.collectEvidence(req.DevicesIDs)
+	}
+	if err == nil {
+		err = qtedp.releaseSecrets(req.DevicesIDs, evidence)
+	}
+	if err == nil {
+		err = qtedp.hooks.run(hookPreStart, req.DevicesIDs, nil, "")
+	}
+	qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditPreStart, Devices: req.DevicesIDs, Pod: qtedp.auditPod(req.DevicesIDs)}, err)
+	if err != nil {
+		glog.Error(err)
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
 	return &pluginapi.PreStartContainerResponse{}, nil
 }
 
+// collectEvidence places the attestation evidence of the devices in the
+// directory mounted in the container. Failures are ignored if the policy
+// says so.
+func (qtedp *QtEnclavesDevicePlugin) collectEvidence(ids []string) ([]*attestationEvidence, error) {
+	if qtedp.attestation == nil {
+		return nil, nil
+	}
+	evidence, err := qtedp.attestation.collect(qtedp.provider.resourceName(), ids, qtedp.provider.hostPath)
+	if err != nil {
+		if qtedp.attestation.policy == attestationIgnore {
+			glog.Errorf("Starting container without attestation evidence: %v", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	glog.V(1).Infof("Attestation evidence of devices %v collected", ids)
+	return evidence, nil
+}
+
+// releaseSecrets presents the evidence of every device to the key broker
+// and places the secrets it releases in the directory mounted in the
+// container.
+func (qtedp *QtEnclavesDevicePlugin) releaseSecrets(ids []string, evidence []*attestationEvidence) error {
+	if qtedp.keyBroker == nil {
+		return nil
+	}
+	if len(evidence) != len(ids) {
+		err := fmt.Errorf("no attestation evidence of devices %v to release secrets", ids)
+		qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditSecretRelease, Action: secretDeny, Devices: ids}, err)
+		return err
+	}
+
+	for _, e := range evidence {
+		secrets, err := qtedp.keyBroker.release(qtedp.provider.resourceName(), e)
+		if err == nil {
+			err = qtedp.keyBroker.write(ids, e.Device, secrets)
+		}
+		action := secretRelease
+		if errors.Is(err, errMeasurementDenied) {
+			action = secretDeny
+		}
+		qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditSecretRelease, Action: action, Devices: []string{e.Device}}, err)
+		if err != nil {
+			return fmt.Errorf("secrets of device %s not released: %v", e.Device, err)
+		}
+		glog.V(0).Infof("Released %d secrets to device %s with measurement %s", len(secrets), e.Device, e.Measurement)
+	}
+	return nil
+}
+
 // Start device plugin server
 func (qtedp *QtEnclavesDevicePlugin) Start() error {
 	err := qtedp.cleanup()
@@ -218,16 +557,29 @@ func (qtedp *QtEnclavesDevicePlugin) Start() error {
 	}
 	glog.V(0).Info("Starting Qt Enclaves device plugin server...")
 
-	sock, err := net.Listen("unix", qtedp.socket)
+	sock, err := qtedp.access.listen(qtedp.socket)
 	if err != nil {
 		glog.Error("Error while creating socket: ", qtedp.socket)
 		return err
 	}
 
-	qtedp.server = grpc.NewServer([]grpc.ServerOption{}...)
+	qtedp.server = grpc.NewServer(append(rpcServerOptions(qtedp.provider.resourceName(), qtedp.tracer), qtedp.access.serverOptions()...)...)
 	pluginapi.RegisterDevicePluginServer(qtedp.server, qtedp)
 	qtedp.stop = make(chan interface{})
 
+	// In plugin watcher mode the kubelet discovers the plugin through a second
+	// socket in the plugins_registry directory, served by the same server.
+	if qtedp.registrationMode == registrationModePluginWatcher {
+		regSock, err := qtedp.access.listen(qtedp.regSocket)
+		if err != nil {
+			glog.Error("Error while creating socket: ", qtedp.regSocket)
+			sock.Close()
+			return err
+		}
+		registerapi.RegisterRegistrationServer(qtedp.server, qtedp)
+		go qtedp.server.Serve(regSock)
+	}
+
 	go qtedp.server.Serve(sock)
 
 	// Wait for server to start by launching a blocking connection
@@ -236,16 +588,29 @@ func (qtedp *QtEnclavesDevicePlugin) Start() error {
 		return err
 	}
 	conn.Close()
-
-	if err := qtedp.register(pluginapi.KubeletSocket, resourceName); err != nil {
-		glog.Errorf("Error while registering device plugin with kubelet! (Reason: %s)", err)
-		qtedp.Stop()
-		return err
+	// Serving is enough for systemd, kubelet may come up much later.
+	qtedp.notifier.ready()
+
+	if qtedp.registrationMode == registrationModePluginWatcher {
+		glog.V(0).Info("Waiting for kubelet plugin watcher on: ", qtedp.regSocket)
+	} else {
+		if err := qtedp.register(qtedp.paths.KubeletSocket, qtedp.provider.resourceName()); err != nil {
+			glog.Errorf("Error while registering device plugin with kubelet! (Reason: %s)", err)
+			qtedp.Stop()
+			return err
+		}
+		glog.V(0).Info("Registered device plugin with Kubelet: ", qtedp.provider.resourceName())
 	}
 
-	glog.V(0).Info("Registered device plugin with Kubelet: ", resourceName)
+	qtedp.hooks.notify(hookDiscovery, qtedp.deviceIDs(), nil, "")
 
 	go qtedp.healthcheck()
+	if qtedp.releaseDetection {
+		go qtedp.watchReleases()
+	}
+	if qtedp.remediation != nil {
+		go qtedp.watchFailures()
+	}
 
 	return nil
 }
@@ -267,17 +632,40 @@ func (qtedp *QtEnclavesDevicePlugin) Stop() error {
 }
 
 // NewQtEnclavesDevicePlugin returns an initialized QtEnclavesDevicePlugin
+// using the default kubelet paths and registration mode
 func NewQtEnclavesDevicePlugin() *QtEnclavesDevicePlugin {
-	devs := []*pluginapi.Device{}
-	for i := 0; i < enclavesPerInstance; i++ {
-		devs = append(devs, &pluginapi.Device{
-			ID:     generateDeviceID(deviceName),
-			Health: pluginapi.Healthy,
-		})
+	return newQtEnclavesDevicePlugin(newKubeletPaths(defaultKubeletRootDir), registrationModeDevicePlugin,
+		newQingTianProvider(newHostBackend(defaultDevRoot)))
+}
+
+func newQtEnclavesDevicePlugin(paths kubeletPaths, registrationMode string, provider EnclaveProvider) *QtEnclavesDevicePlugin {
+	qtedp := &QtEnclavesDevicePlugin{
+		socket:           filepath.Join(paths.DevicePluginDir, provider.socketName()),
+		paths:            paths,
+		registrationMode: registrationMode,
+		regSocket:        filepath.Join(paths.PluginsRegistryDir, registrationSocketName(provider.socketName())),
+		cordoned:         map[string]bool{},
+		allocations:      map[string]time.Time{},
+		allocating:       map[string]bool{},
+		pathMapper:       containerPathMapper{mode: containerPathHost},
+		permissions:      defaultDevicePermissions,
+
+		releasePollInterval:   defaultReleasePollInterval,
+		releaseCleanupTimeout: defaultReleaseCleanupTimeout,
+		inUse:                 map[string]podRef{},
+		releasing:             map[string]podRef{},
+
+		access:              defaultSocketAccess(),
+		healthCheckInterval: devicePluginHealthCheckInterval,
+		health:              make(chan *pluginapi.Device, 1),
 	}
-	return &QtEnclavesDevicePlugin{
-		devs:   devs,
-		socket: socketPath,
-		health: make(chan *pluginapi.Device),
+
+	ids, err := provider.discover()
+	if err != nil {
+		glog.Errorf("Failed to discover devices: %v", err)
 	}
+	qtedp.provider = provider
+	qtedp.devs = newDevices(ids)
+
+	return qtedp
 }
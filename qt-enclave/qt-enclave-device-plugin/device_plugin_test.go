@@ -53,3 +53,12 @@ func TestValidateDeviceNameSuccess(t *testing.T) {
 		return
 	}
 }
+
+// newTestDevicePlugin builds a plugin without consuming device IDs, so the
+// ID generation tests above do not depend on the test order.
+func newTestDevicePlugin(paths kubeletPaths, registrationMode string) *QtEnclavesDevicePlugin {
+	ctr := deviceIdCounter
+	defer func() { deviceIdCounter = ctr }()
+
+	return newQtEnclavesDevicePlugin(paths, registrationMode, newQingTianProvider(newHostBackend(defaultDevRoot)))
+}
@@ -0,0 +1,169 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide device node verification
+ *********************************************************************************/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// anyMajor and anyGID disable the major number and group checks.
+	anyMajor = -1
+	anyGID   = -1
+
+	// The SGX and nitro enclaves devices are misc devices.
+	miscMajor = 10
+
+	defaultDeviceOwnerUID = 0
+)
+
+// defaultDeviceMajors are the major numbers of the well known devices, per
+// provider.
+var defaultDeviceMajors = map[string]int{
+	providerSGX:   miscMajor,
+	providerNitro: miscMajor,
+}
+
+// nodeRejectedError tells that a device node exists but is not trusted.
+type nodeRejectedError struct {
+	path   string
+	reason string
+}
+
+func (e *nodeRejectedError) Error() string {
+	return fmt.Sprintf("rejected device node %s: %s", e.path, e.reason)
+}
+
+// isNodeRejected tells whether err is a rejected device node.
+func isNodeRejected(err error) bool {
+	var rejected *nodeRejectedError
+	return errors.As(err, &rejected)
+}
+
+// nodeVerifier checks that a device node is a character device of the
+// expected driver and owner, so that a regular file, a symlink or a node
+// anybody could have created is never advertised as an enclave.
+type nodeVerifier struct {
+	major int
+	uid   int
+	gid   int
+}
+
+func newNodeVerifier(major, uid, gid int) *nodeVerifier {
+	return &nodeVerifier{major: major, uid: uid, gid: gid}
+}
+
+// verify checks the node at path without following symlinks. A nil
+// verifier only checks that the node exists.
+func (v *nodeVerifier) verify(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	return v.verifyInfo(path, info)
+}
+
+func (v *nodeVerifier) verifyInfo(path string, info os.FileInfo) error {
+	reject := func(format string, args ...interface{}) error {
+		return &nodeRejectedError{path: path, reason: fmt.Sprintf(format, args...)}
+	}
+
+	mode := info.Mode()
+	if mode&os.ModeSymlink != 0 {
+		return reject("is a symlink")
+	}
+	if mode&os.ModeDevice == 0 || mode&os.ModeCharDevice == 0 {
+		return reject("is not a character device (mode %s)", mode)
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return reject("has no owner information")
+	}
+	if major := unix.Major(uint64(st.Rdev)); v.major != anyMajor && major != uint32(v.major) {
+		return reject("has major number %d, expected %d", major, v.major)
+	}
+	if mode.Perm()&0002 != 0 {
+		return reject("is world-writable (mode %s)", mode.Perm())
+	}
+	if int(st.Uid) != v.uid {
+		return reject("is owned by uid %d, expected %d", st.Uid, v.uid)
+	}
+	if v.gid != anyGID && int(st.Gid) != v.gid {
+		return reject("is owned by gid %d, expected %d", st.Gid, v.gid)
+	}
+	return nil
+}
+
+// verifyDiscovered reports whether a discovered node may be advertised.
+// Missing nodes are left to the health check, rejected ones are logged.
+func (v *nodeVerifier) verifyDiscovered(path string) bool {
+	err := v.verify(path)
+	if isNodeRejected(err) {
+		glog.Errorf("Not advertising device: %v", err)
+		return false
+	}
+	return true
+}
+
+// parseDeviceMajors parses "provider=major,..." on top of the defaults.
+func parseDeviceMajors(value string) (map[string]int, error) {
+	majors := map[string]int{}
+	for provider, major := range defaultDeviceMajors {
+		majors[provider] = major
+	}
+	if value == "" {
+		return majors, nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid device major %q, expected <provider>=<major>", item)
+		}
+		switch parts[0] {
+		case providerQingTian, providerSGX, providerNitro:
+		default:
+			return nil, fmt.Errorf("invalid device major %q: unknown enclave provider %s", item, parts[0])
+		}
+		major, err := strconv.Atoi(parts[1])
+		if err != nil || major < 0 {
+			return nil, fmt.Errorf("invalid major number %q for %s", parts[1], parts[0])
+		}
+		majors[parts[0]] = major
+	}
+
+	return majors, nil
+}
+
+// deviceMajor returns the major number configured for a provider, anyMajor
+// if there is none.
+func deviceMajor(majors map[string]int, provider string) int {
+	if major, ok := majors[provider]; ok {
+		return major
+	}
+	return anyMajor
+}
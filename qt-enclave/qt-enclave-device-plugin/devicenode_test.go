@@ -0,0 +1,122 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide device node verification testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// fakeNodeInfo describes a device node without creating it.
+type fakeNodeInfo struct {
+	mode os.FileMode
+	stat syscall.Stat_t
+}
+
+func (f fakeNodeInfo) Name() string       { return "qtbox_service0" }
+func (f fakeNodeInfo) Size() int64        { return 0 }
+func (f fakeNodeInfo) Mode() os.FileMode  { return f.mode }
+func (f fakeNodeInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeNodeInfo) IsDir() bool        { return false }
+func (f fakeNodeInfo) Sys() interface{}   { return &f.stat }
+
+func charDevice(major uint32, perm os.FileMode, uid, gid uint32) fakeNodeInfo {
+	return fakeNodeInfo{
+		mode: os.ModeDevice | os.ModeCharDevice | perm,
+		stat: syscall.Stat_t{Rdev: unix.Mkdev(major, 0), Uid: uid, Gid: gid},
+	}
+}
+
+func TestVerifyDeviceNode(t *testing.T) {
+	v := newNodeVerifier(240, 0, 0)
+
+	tests := []struct {
+		info   fakeNodeInfo
+		reason string
+	}{
+		{charDevice(240, 0600, 0, 0), ""},
+		{fakeNodeInfo{mode: 0600}, "not a character device"},
+		{fakeNodeInfo{mode: os.ModeDevice | 0600}, "not a character device"},
+		{charDevice(10, 0600, 0, 0), "major number 10"},
+		{charDevice(240, 0666, 0, 0), "world-writable"},
+		{charDevice(240, 0600, 1000, 0), "uid 1000"},
+		{charDevice(240, 0600, 0, 1000), "gid 1000"},
+	}
+	for _, tt := range tests {
+		err := v.verifyInfo("/dev/qtbox_service0", tt.info)
+		if tt.reason == "" {
+			if err != nil {
+				t.Fatalf("Trusted node rejected: %v", err)
+			}
+			continue
+		}
+		if !isNodeRejected(err) || !strings.Contains(err.Error(), tt.reason) {
+			t.Fatalf("Expected rejection for %q but got %v", tt.reason, err)
+		}
+	}
+
+	if err := newNodeVerifier(anyMajor, 0, anyGID).verifyInfo("/dev/x", charDevice(99, 0660, 0, 5)); err != nil {
+		t.Fatalf("Any major and group should be accepted: %v", err)
+	}
+}
+
+func TestHostBackendRejectsSpoofedNodes(t *testing.T) {
+	devRoot := t.TempDir()
+	b := &hostBackend{devRoot: devRoot, ids: []string{"qtbox_service0", "qtbox_service1", "qtbox_service2"}}
+	if err := os.WriteFile(b.hostPath("qtbox_service0"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/dev/null", b.hostPath("qtbox_service1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without verification, as in simulation, any node is fine.
+	if ids, _ := b.discover(); len(ids) != 3 {
+		t.Fatalf("Expected all devices without verification, got %v", ids)
+	}
+
+	b.nodes = newNodeVerifier(anyMajor, 0, anyGID)
+	ids, _ := b.discover()
+	if len(ids) != 1 || ids[0] != "qtbox_service2" {
+		t.Fatalf("Only the missing device should stay advertised, got %v", ids)
+	}
+	if err := b.check("qtbox_service1"); !isNodeRejected(err) || !strings.Contains(err.Error(), "symlink") {
+		t.Fatalf("Symlinked node should be rejected, got %v", err)
+	}
+	if err := b.check("qtbox_service2"); err == nil || isNodeRejected(err) {
+		t.Fatalf("Missing node should fail the check without rejection, got %v", err)
+	}
+}
+
+func TestParseDeviceMajors(t *testing.T) {
+	majors, err := parseDeviceMajors("qingtian=240,sgx=11")
+	if err != nil || majors[providerQingTian] != 240 || majors[providerSGX] != 11 || majors[providerNitro] != miscMajor {
+		t.Fatalf("Unexpected majors %v: %v", majors, err)
+	}
+	if major := deviceMajor(map[string]int{}, providerQingTian); major != anyMajor {
+		t.Fatalf("Unconfigured major should accept any, got %d", major)
+	}
+	for _, value := range []string{"qingtian", "=1", "sgx=x", "sgx=-1", "qtbox=240"} {
+		if _, err := parseDeviceMajors(value); err == nil {
+			t.Fatalf("Device majors %q should be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide container device paths and permissions
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// containerPathHost exposes a device under its host path.
+	containerPathHost = "host"
+	// containerPathStable exposes the devices of a container as
+	// /dev/qtbox_service0, /dev/qtbox_service1, ... in allocation order.
+	containerPathStable = "stable"
+	// containerPathTemplate exposes the devices under a configured template.
+	containerPathTemplate = "template"
+
+	templateIndex = "{index}"
+	templateID    = "{id}"
+
+	defaultDevicePermissions = "rw"
+)
+
+// containerPathMapper computes where an allocated device shows up inside
+// the container.
+type containerPathMapper struct {
+	mode     string
+	template string
+}
+
+func newContainerPathMapper(mode, template string) (containerPathMapper, error) {
+	switch mode {
+	case containerPathHost, containerPathStable:
+	case containerPathTemplate:
+		if !filepath.IsAbs(template) {
+			return containerPathMapper{}, fmt.Errorf("container path template must be absolute: %q", template)
+		}
+		if !strings.Contains(template, templateIndex) && !strings.Contains(template, templateID) {
+			return containerPathMapper{}, fmt.Errorf("container path template must contain %s or %s: %q",
+				templateIndex, templateID, template)
+		}
+	default:
+		return containerPathMapper{}, fmt.Errorf("unknown container path mode: %s", mode)
+	}
+
+	return containerPathMapper{mode: mode, template: template}, nil
+}
+
+// containerPath returns the container path of the index-th device of a
+// container request.
+func (m containerPathMapper) containerPath(id string, index int) string {
+	switch m.mode {
+	case containerPathStable:
+		return devicePath(deviceName + strconv.Itoa(index))
+	case containerPathTemplate:
+		r := strings.NewReplacer(templateIndex, strconv.Itoa(index), templateID, id)
+		return r.Replace(m.template)
+	default:
+		return devicePath(id)
+	}
+}
+
+// parseDevicePermissions parses "resource=perms,..." into a map, checking
+// that the permissions only use the cgroup device access letters.
+func parseDevicePermissions(value string) (map[string]string, error) {
+	perms := map[string]string{}
+	if value == "" {
+		return perms, nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid device permissions %q, expected <resource>=<perms>", item)
+		}
+		if parts[1] == "" || strings.Trim(parts[1], "rwm") != "" {
+			return nil, fmt.Errorf("invalid permissions %q for %s, expected a combination of r, w and m", parts[1], parts[0])
+		}
+		perms[parts[0]] = parts[1]
+	}
+
+	return perms, nil
+}
+
+// devicePermissions returns the permissions configured for a resource.
+func devicePermissions(perms map[string]string, resource string) string {
+	if p, ok := perms[resource]; ok {
+		return p
+	}
+	return defaultDevicePermissions
+}
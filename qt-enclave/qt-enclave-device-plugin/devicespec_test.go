@@ -0,0 +1,91 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide container device paths and permissions testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestStableContainerPaths(t *testing.T) {
+	qtedp := newTestAllocationPlugin()
+	qtedp.pathMapper = containerPathMapper{mode: containerPathStable}
+
+	resp, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service1", "qtbox_service0"}))
+	if err != nil {
+		t.Fatalf("Allocation failed: %v", err)
+	}
+
+	devs := resp.ContainerResponses[0].Devices
+	expected := [][2]string{
+		{"/dev/qtbox_service1", "/dev/qtbox_service0"},
+		{"/dev/qtbox_service0", "/dev/qtbox_service1"},
+	}
+	for i, e := range expected {
+		if devs[i].HostPath != e[0] || devs[i].ContainerPath != e[1] {
+			t.Fatalf("Expected %s -> %s but got %s -> %s", e[0], e[1], devs[i].HostPath, devs[i].ContainerPath)
+		}
+	}
+}
+
+func TestTemplateContainerPaths(t *testing.T) {
+	m, err := newContainerPathMapper(containerPathTemplate, "/dev/enclave/{index}-{id}")
+	if err != nil {
+		t.Fatalf("Failed to create mapper: %v", err)
+	}
+	if p := m.containerPath("qtbox_service3", 1); p != "/dev/enclave/1-qtbox_service3" {
+		t.Fatalf("Unexpected container path: %s", p)
+	}
+
+	for _, tmpl := range []string{"", "dev/enclave{index}", "/dev/enclave"} {
+		if _, err := newContainerPathMapper(containerPathTemplate, tmpl); err == nil {
+			t.Fatalf("Template %q should be rejected", tmpl)
+		}
+	}
+	if _, err := newContainerPathMapper("random", ""); err == nil {
+		t.Fatal("Unknown mode should be rejected")
+	}
+}
+
+func TestDevicePermissions(t *testing.T) {
+	perms, err := parseDevicePermissions("huawei.com/qt_enclaves=r, example.com/other=rwm")
+	if err != nil {
+		t.Fatalf("Failed to parse permissions: %v", err)
+	}
+	if p := devicePermissions(perms, resourceName); p != "r" {
+		t.Fatalf("Expected r but got %s", p)
+	}
+	if p := devicePermissions(perms, "example.com/unknown"); p != defaultDevicePermissions {
+		t.Fatalf("Expected default permissions but got %s", p)
+	}
+
+	for _, v := range []string{"rw", "huawei.com/qt_enclaves=", "huawei.com/qt_enclaves=rx"} {
+		if _, err := parseDevicePermissions(v); err == nil {
+			t.Fatalf("Permissions %q should be rejected", v)
+		}
+	}
+
+	qtedp := newTestAllocationPlugin()
+	qtedp.permissions = "r"
+	resp, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"}))
+	if err != nil {
+		t.Fatalf("Allocation failed: %v", err)
+	}
+	if p := resp.ContainerResponses[0].Devices[0].Permissions; p != "r" {
+		t.Fatalf("Expected r but got %s", p)
+	}
+}
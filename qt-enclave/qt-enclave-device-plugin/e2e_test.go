@@ -0,0 +1,142 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide end to end testcase against a fake kubelet
+ *********************************************************************************/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/qt-enclave-k8s-device-plugin/fakekubelet"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const e2eTimeout = 10 * time.Second
+
+// startE2EPlugin runs a simulated plugin against a fake kubelet, both in a
+// temporary kubelet root directory.
+func startE2EPlugin(t *testing.T, mode string, devices int) (*QtEnclavesDevicePlugin, *fakekubelet.Kubelet, *simBackend) {
+	paths := newKubeletPaths(t.TempDir())
+	for _, dir := range []string{paths.DevicePluginDir, paths.PluginsRegistryDir} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kubelet := fakekubelet.New(paths.DevicePluginDir)
+	if err := kubelet.Start(); err != nil {
+		t.Fatalf("Failed to start fake kubelet: %v", err)
+	}
+	t.Cleanup(kubelet.Stop)
+
+	sim, err := newSimBackend(filepath.Join(paths.RootDir, "dev"), simNodeFile, devices)
+	if err != nil {
+		t.Fatalf("Failed to create simulation backend: %v", err)
+	}
+	qtedp := newQtEnclavesDevicePlugin(paths, mode, newQingTianProvider(sim))
+	qtedp.healthCheckInterval = 50 * time.Millisecond
+	if err := qtedp.Start(); err != nil {
+		t.Fatalf("Failed to start device plugin: %v", err)
+	}
+	t.Cleanup(func() { qtedp.Stop() })
+
+	return qtedp, kubelet, sim
+}
+
+func healthOf(devs []*pluginapi.Device, id string) string {
+	for _, d := range devs {
+		if d.ID == id {
+			return d.Health
+		}
+	}
+	return ""
+}
+
+func TestE2ERegistrationAndAllocation(t *testing.T) {
+	_, kubelet, sim := startE2EPlugin(t, registrationModeDevicePlugin, 2)
+
+	p, err := kubelet.WaitForPlugin(resourceName, e2eTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regs := kubelet.Registrations()
+	if len(regs) != 1 || regs[0].Endpoint != socketName {
+		t.Fatalf("Unexpected registrations: %v", regs)
+	}
+
+	devs, err := p.WaitForDevices(func(devs []*pluginapi.Device) bool { return len(devs) == 2 }, e2eTimeout)
+	if err != nil {
+		t.Fatalf("Devices not advertised: %v (%v)", err, devs)
+	}
+
+	if _, err := p.GetPreferredAllocation([]string{"qtbox_service0", "qtbox_service1"}, nil, 1); err != nil {
+		t.Fatalf("GetPreferredAllocation failed: %v", err)
+	}
+	resp, err := p.Allocate([]string{"qtbox_service1"})
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	spec := resp.ContainerResponses[0].Devices[0]
+	if spec.HostPath != sim.hostPath("qtbox_service1") || spec.ContainerPath != "/dev/qtbox_service1" {
+		t.Fatalf("Unexpected device spec: %v", spec)
+	}
+	if _, err := p.PreStartContainer([]string{"qtbox_service1"}); err != nil {
+		t.Fatalf("PreStartContainer failed: %v", err)
+	}
+
+	// Breaking a simulated device shows up in ListAndWatch, and the device
+	// can no longer be allocated.
+	if err := sim.setBroken("qtbox_service0", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.WaitForDevices(func(devs []*pluginapi.Device) bool {
+		return healthOf(devs, "qtbox_service0") == pluginapi.Unhealthy
+	}, e2eTimeout); err != nil {
+		t.Fatalf("Broken device not reported: %v", err)
+	}
+	if _, err := p.Allocate([]string{"qtbox_service0"}); err == nil {
+		t.Fatal("Allocating a broken device should fail")
+	}
+
+	if err := sim.addDevice("qtbox_service2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.WaitForDevices(func(devs []*pluginapi.Device) bool {
+		return healthOf(devs, "qtbox_service2") == pluginapi.Healthy
+	}, e2eTimeout); err != nil {
+		t.Fatalf("Added device not reported: %v", err)
+	}
+
+	if calls := p.Calls(); len(calls) != 3 || calls[2].Err == nil {
+		t.Fatalf("Unexpected recorded calls: %v", calls)
+	}
+}
+
+func TestE2EPluginWatcherRegistration(t *testing.T) {
+	qtedp, kubelet, _ := startE2EPlugin(t, registrationModePluginWatcher, 1)
+
+	p, err := kubelet.ConnectPluginWatcher(qtedp.regSocket)
+	if err != nil {
+		t.Fatalf("Plugin watcher registration failed: %v", err)
+	}
+	if len(kubelet.Registrations()) != 0 {
+		t.Fatal("Plugin watcher mode should not call Register")
+	}
+	if _, err := p.WaitForDevices(func(devs []*pluginapi.Device) bool { return len(devs) == 1 }, e2eTimeout); err != nil {
+		t.Fatalf("Devices not advertised: %v", err)
+	}
+}
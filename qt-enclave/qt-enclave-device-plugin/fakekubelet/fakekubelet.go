@@ -0,0 +1,410 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide an in-process fake kubelet for device plugin tests
+ *********************************************************************************/
+
+// Package fakekubelet plays the kubelet side of the device plugin API, so
+// that device plugins can be tested end to end in go test, without root.
+//
+// The fake kubelet serves the Registration service on a socket in a
+// device plugin directory. When a plugin registers, it connects back to
+// the plugin endpoint, starts ListAndWatch and records every device list
+// and every call made through the Plugin handle.
+package fakekubelet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	registerapi "k8s.io/kubelet/pkg/apis/pluginregistration/v1"
+)
+
+// SocketName is the name of the kubelet registration socket.
+const SocketName = "kubelet.sock"
+
+const dialTimeout = 10 * time.Second
+
+// Call is a device plugin call made by the fake kubelet.
+type Call struct {
+	Method   string
+	Request  interface{}
+	Response interface{}
+	Err      error
+}
+
+// Kubelet is a fake kubelet serving the device plugin Registration service.
+type Kubelet struct {
+	dir    string
+	server *grpc.Server
+
+	mu       sync.Mutex
+	plugins  map[string]*Plugin
+	requests []*pluginapi.RegisterRequest
+	changed  chan struct{}
+	// connectErr is the last error connecting back to a registered plugin.
+	connectErr error
+}
+
+// New returns a fake kubelet for the device plugin directory dir.
+func New(dir string) *Kubelet {
+	return &Kubelet{
+		dir:     dir,
+		plugins: map[string]*Plugin{},
+		changed: make(chan struct{}),
+	}
+}
+
+// Socket returns the path of the registration socket.
+func (k *Kubelet) Socket() string {
+	return filepath.Join(k.dir, SocketName)
+}
+
+// Start serves the Registration service.
+func (k *Kubelet) Start() error {
+	if err := os.MkdirAll(k.dir, 0750); err != nil {
+		return err
+	}
+	if err := os.Remove(k.Socket()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lis, err := net.Listen("unix", k.Socket())
+	if err != nil {
+		return err
+	}
+
+	k.server = grpc.NewServer()
+	pluginapi.RegisterRegistrationServer(k.server, k)
+	go k.server.Serve(lis)
+
+	return nil
+}
+
+// Stop stops the Registration service and disconnects from the plugins.
+func (k *Kubelet) Stop() {
+	if k.server != nil {
+		k.server.Stop()
+		k.server = nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, p := range k.plugins {
+		p.close()
+	}
+	k.plugins = map[string]*Plugin{}
+}
+
+// Register implements the Registration service. Like kubelet, it connects
+// to the plugin endpoint once the registration is accepted.
+func (k *Kubelet) Register(ctx context.Context, req *pluginapi.RegisterRequest) (*pluginapi.Empty, error) {
+	if req.Version != pluginapi.Version {
+		return nil, fmt.Errorf("unsupported device plugin API version: %s", req.Version)
+	}
+
+	k.mu.Lock()
+	k.requests = append(k.requests, req)
+	k.mu.Unlock()
+
+	go func() {
+		if _, err := k.connect(req.ResourceName, filepath.Join(k.dir, req.Endpoint)); err != nil {
+			k.mu.Lock()
+			k.connectErr = err
+			k.mu.Unlock()
+		}
+	}()
+
+	return &pluginapi.Empty{}, nil
+}
+
+// Registrations returns the registration requests received so far.
+func (k *Kubelet) Registrations() []*pluginapi.RegisterRequest {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]*pluginapi.RegisterRequest{}, k.requests...)
+}
+
+// ConnectPluginWatcher registers a plugin the way the kubelet plugin
+// watcher does, through the Registration service of its plugins_registry
+// socket.
+func (k *Kubelet) ConnectPluginWatcher(regSocket string) (*Plugin, error) {
+	conn, err := dial(regSocket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	client := registerapi.NewRegistrationClient(conn)
+	info, err := client.GetInfo(ctx, &registerapi.InfoRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if info.Type != registerapi.DevicePlugin {
+		return nil, fmt.Errorf("unexpected plugin type: %s", info.Type)
+	}
+
+	endpoint := info.Endpoint
+	if endpoint == "" {
+		endpoint = regSocket
+	}
+	p, err := k.connect(info.Name, endpoint)
+	status := &registerapi.RegistrationStatus{PluginRegistered: err == nil}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	if _, notifyErr := client.NotifyRegistrationStatus(ctx, status); notifyErr != nil && err == nil {
+		err = notifyErr
+	}
+
+	return p, err
+}
+
+func (k *Kubelet) connect(resource, endpoint string) (*Plugin, error) {
+	conn, err := dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plugin{
+		Resource: resource,
+		Endpoint: endpoint,
+		conn:     conn,
+		client:   pluginapi.NewDevicePluginClient(conn),
+		changed:  make(chan struct{}),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	if p.Options, err = p.client.GetDevicePluginOptions(ctx, &pluginapi.Empty{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := p.watch(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	k.mu.Lock()
+	if old, ok := k.plugins[resource]; ok {
+		old.close()
+	}
+	k.plugins[resource] = p
+	close(k.changed)
+	k.changed = make(chan struct{})
+	k.mu.Unlock()
+
+	return p, nil
+}
+
+// WaitForPlugin waits until a plugin for the resource is connected.
+func (k *Kubelet) WaitForPlugin(resource string, timeout time.Duration) (*Plugin, error) {
+	deadline := time.After(timeout)
+	for {
+		k.mu.Lock()
+		p, ok := k.plugins[resource]
+		changed, err := k.changed, k.connectErr
+		k.mu.Unlock()
+		if ok {
+			return p, nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return nil, fmt.Errorf("timed out waiting for a plugin for %s (last error: %v)", resource, err)
+		}
+	}
+}
+
+// Plugin is the connection of the fake kubelet to a registered plugin.
+type Plugin struct {
+	Resource string
+	Endpoint string
+	Options  *pluginapi.DevicePluginOptions
+
+	conn   *grpc.ClientConn
+	client pluginapi.DevicePluginClient
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	updates [][]*pluginapi.Device
+	calls   []Call
+	changed chan struct{}
+	err     error
+}
+
+// watch starts ListAndWatch and records the device lists it streams.
+func (p *Plugin) watch() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := p.client.ListAndWatch(ctx, &pluginapi.Empty{})
+	if err != nil {
+		cancel()
+		return err
+	}
+	p.cancel = cancel
+
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			p.mu.Lock()
+			if err != nil {
+				p.err = err
+			} else {
+				p.updates = append(p.updates, resp.Devices)
+			}
+			close(p.changed)
+			p.changed = make(chan struct{})
+			p.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (p *Plugin) close() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.conn.Close()
+}
+
+// Updates returns all the device lists received through ListAndWatch.
+func (p *Plugin) Updates() [][]*pluginapi.Device {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]*pluginapi.Device{}, p.updates...)
+}
+
+// Devices returns the last device list received through ListAndWatch.
+func (p *Plugin) Devices() []*pluginapi.Device {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.updates) == 0 {
+		return nil
+	}
+	return p.updates[len(p.updates)-1]
+}
+
+// WaitForDevices waits until the last device list satisfies cond.
+func (p *Plugin) WaitForDevices(cond func([]*pluginapi.Device) bool, timeout time.Duration) ([]*pluginapi.Device, error) {
+	deadline := time.After(timeout)
+	for {
+		p.mu.Lock()
+		var devs []*pluginapi.Device
+		if len(p.updates) > 0 {
+			devs = p.updates[len(p.updates)-1]
+		}
+		changed, err := p.changed, p.err
+		p.mu.Unlock()
+
+		if devs != nil && cond(devs) {
+			return devs, nil
+		}
+		if err != nil {
+			return devs, fmt.Errorf("ListAndWatch of %s ended: %v", p.Resource, err)
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return devs, fmt.Errorf("timed out waiting for the devices of %s", p.Resource)
+		}
+	}
+}
+
+// Calls returns the calls made to the plugin so far, ListAndWatch excepted.
+func (p *Plugin) Calls() []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Call{}, p.calls...)
+}
+
+func (p *Plugin) record(method string, req, resp interface{}, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, Call{Method: method, Request: req, Response: resp, Err: err})
+}
+
+// GetPreferredAllocation asks the plugin which of the available devices it
+// prefers for a container of size devices.
+func (p *Plugin) GetPreferredAllocation(available, mustInclude []string, size int) (*pluginapi.PreferredAllocationResponse, error) {
+	req := &pluginapi.PreferredAllocationRequest{
+		ContainerRequests: []*pluginapi.ContainerPreferredAllocationRequest{{
+			AvailableDeviceIDs:   available,
+			MustIncludeDeviceIDs: mustInclude,
+			AllocationSize:       int32(size),
+		}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	resp, err := p.client.GetPreferredAllocation(ctx, req)
+	p.record("GetPreferredAllocation", req, resp, err)
+	return resp, err
+}
+
+// Allocate allocates devices, one list of device IDs per container.
+func (p *Plugin) Allocate(containers ...[]string) (*pluginapi.AllocateResponse, error) {
+	req := &pluginapi.AllocateRequest{}
+	for _, ids := range containers {
+		req.ContainerRequests = append(req.ContainerRequests, &pluginapi.ContainerAllocateRequest{DevicesIDs: ids})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	resp, err := p.client.Allocate(ctx, req)
+	p.record("Allocate", req, resp, err)
+	return resp, err
+}
+
+// PreStartContainer calls PreStartContainer when the plugin asked for it,
+// as kubelet does before starting a container.
+func (p *Plugin) PreStartContainer(ids []string) (*pluginapi.PreStartContainerResponse, error) {
+	if p.Options == nil || !p.Options.PreStartRequired {
+		return nil, nil
+	}
+
+	req := &pluginapi.PreStartContainerRequest{DevicesIDs: ids}
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	resp, err := p.client.PreStartContainer(ctx, req)
+	p.record("PreStartContainer", req, resp, err)
+	return resp, err
+}
+
+func dial(socket string) (*grpc.ClientConn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	return grpc.DialContext(ctx, socket,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", addr)
+		}),
+	)
+}
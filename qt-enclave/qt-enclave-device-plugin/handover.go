@@ -0,0 +1,379 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide handover between two daemons during an upgrade
+ *********************************************************************************/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	handoverSocketName     = "qt-enclave-device-plugin.handover"
+	handoverStateName      = "qt-enclave-device-plugin.state"
+	defaultHandoverTimeout = 20 * time.Second
+
+	handoverWithdraw = "withdraw"
+	handoverOK       = "ok"
+	handoverStateV1  = 1
+)
+
+// versionedSocket adds the generation of the daemon to a socket name, so
+// that two daemons can serve the same resource during a handover.
+func versionedSocket(socket, generation string) string {
+	return strings.TrimSuffix(socket, ".sock") + "-" + generation + ".sock"
+}
+
+// newGeneration returns a name unique to this daemon.
+func newGeneration() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// pluginState is the state of a device plugin carried over an upgrade.
+// Cordons and health are not part of it: they are read again from the
+// cordon file and the devices.
+type pluginState struct {
+	Allocations    map[string]time.Time `json:"allocations,omitempty"`
+	ReleaseTracked bool                 `json:"releaseTracked,omitempty"`
+	InUse          map[string]podRef    `json:"inUse,omitempty"`
+	Releasing      map[string]podRef    `json:"releasing,omitempty"`
+	CIDs           map[string]uint32    `json:"cids,omitempty"`
+}
+
+type handoverState struct {
+	Version   int                    `json:"version"`
+	Resources map[string]pluginState `json:"resources"`
+}
+
+// saveState returns the state of the plugin.
+func (qtedp *QtEnclavesDevicePlugin) saveState() pluginState {
+	qtedp.mu.Lock()
+	defer qtedp.mu.Unlock()
+
+	state := pluginState{
+		Allocations:    map[string]time.Time{},
+		ReleaseTracked: qtedp.releaseTracked,
+		InUse:          map[string]podRef{},
+		Releasing:      map[string]podRef{},
+		CIDs:           qtedp.cids.snapshot(),
+	}
+	for id, at := range qtedp.allocations {
+		state.Allocations[id] = at
+	}
+	for id, pod := range qtedp.inUse {
+		state.InUse[id] = pod
+	}
+	for id, pod := range qtedp.releasing {
+		state.Releasing[id] = pod
+	}
+	return state
+}
+
+// restoreState merges the state of the previous daemon into the plugin.
+// Devices the plugin does not serve are skipped.
+func (qtedp *QtEnclavesDevicePlugin) restoreState(state pluginState) {
+	known := map[string]bool{}
+	for _, id := range qtedp.deviceIDs() {
+		known[id] = true
+	}
+
+	var released []*pluginapi.Device
+	qtedp.mu.Lock()
+	for id, at := range state.Allocations {
+		if _, ok := qtedp.allocations[id]; known[id] && !ok {
+			qtedp.allocations[id] = at
+		}
+	}
+	qtedp.releaseTracked = qtedp.releaseTracked || state.ReleaseTracked
+	for id, pod := range state.InUse {
+		if _, ok := qtedp.inUse[id]; known[id] && !ok {
+			qtedp.inUse[id] = pod
+		}
+	}
+	for id, pod := range state.Releasing {
+		if _, ok := qtedp.releasing[id]; known[id] && !ok {
+			qtedp.releasing[id] = pod
+			if dev := qtedp.findDevice(id); dev != nil && dev.Health == pluginapi.Healthy {
+				dev.Health = pluginapi.Unhealthy
+				released = append(released, dev)
+			}
+		}
+	}
+	qtedp.mu.Unlock()
+
+	for id, cid := range state.CIDs {
+		if !known[id] {
+			continue
+		}
+		if err := qtedp.cids.restore(id, cid); err != nil {
+			glog.Errorf("Failed to restore vsock CID of device %s: %v", id, err)
+		}
+	}
+
+	// The health check would report them too, but only on its next run.
+	for _, dev := range released {
+		qtedp.notify(dev)
+	}
+	glog.V(0).Infof("Restored state of %s: %d allocations, %d releasing devices, %d CIDs",
+		qtedp.provider.resourceName(), len(state.Allocations), len(state.Releasing), len(state.CIDs))
+}
+
+// handover lets a new daemon take over from a running one without the
+// resources dropping to zero: the new daemon registers on its own socket,
+// then asks the old one to save its state and withdraw.
+type handover struct {
+	socket    string
+	stateFile string
+	timeout   time.Duration
+	plugins   []*QtEnclavesDevicePlugin
+	// sockets are the versioned sockets of this daemon.
+	sockets []string
+	// access creates the handover socket and checks its peers like the
+	// plugin sockets, through the privileged helper if any.
+	access *socketAccess
+
+	listener net.Listener
+	// requests receives the withdraw requests of a successor.
+	requests chan net.Conn
+}
+
+func newHandover(dir string, timeout time.Duration, plugins []*QtEnclavesDevicePlugin) *handover {
+	return &handover{
+		socket:    filepath.Join(dir, handoverSocketName),
+		stateFile: filepath.Join(dir, handoverStateName),
+		timeout:   timeout,
+		plugins:   plugins,
+		access:    defaultSocketAccess(),
+		requests:  make(chan net.Conn),
+	}
+}
+
+// takeOver asks the previous daemon, if any, to withdraw and restores its
+// state, then waits for a successor. It is called once the plugins are
+// registered.
+func (h *handover) takeOver() error {
+	defer func() {
+		if err := h.listen(); err != nil {
+			glog.Errorf("Failed to listen for a successor on %s: %v", h.socket, err)
+		}
+		h.removeStaleSockets()
+	}()
+
+	conn, err := h.access.connect(h.socket, h.timeout)
+	if err != nil {
+		glog.V(0).Infof("No previous daemon to take over from: %v", err)
+		return nil
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(h.timeout))
+	if _, err := fmt.Fprintln(conn, handoverWithdraw); err != nil {
+		return fmt.Errorf("failed to ask the previous daemon to withdraw: %v", err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("previous daemon did not withdraw: %v", err)
+	}
+	if reply = strings.TrimSpace(reply); reply != handoverOK {
+		return fmt.Errorf("previous daemon failed to withdraw: %s", reply)
+	}
+
+	if err := h.restore(); err != nil {
+		return err
+	}
+	glog.V(0).Info("Took over from the previous daemon")
+	return nil
+}
+
+// restore loads the state saved by the previous daemon and removes it.
+func (h *handover) restore() error {
+	data, err := os.ReadFile(h.stateFile)
+	if err != nil {
+		return fmt.Errorf("failed to read handover state: %v", err)
+	}
+	defer func() {
+		if err := h.removeState(); err != nil {
+			glog.Errorf("Failed to remove handover state %s: %v", h.stateFile, err)
+		}
+	}()
+
+	var state handoverState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid handover state %s: %v", h.stateFile, err)
+	}
+	if state.Version != handoverStateV1 {
+		return fmt.Errorf("unsupported handover state version %d", state.Version)
+	}
+	for _, qtedp := range h.plugins {
+		if s, ok := state.Resources[qtedp.provider.resourceName()]; ok {
+			qtedp.restoreState(s)
+		}
+	}
+	return nil
+}
+
+// listen accepts the withdraw requests of a successor, from the UIDs
+// allowed to call the plugin sockets only.
+func (h *handover) listen() error {
+	// The socket replaces the one of the predecessor. The successor
+	// replaces it in turn, it must survive our exit.
+	lis, err := h.access.listen(h.socket)
+	if err != nil {
+		return err
+	}
+	h.listener = lis
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			if !h.access.allowsConn(conn, "handover request") {
+				conn.Close()
+				continue
+			}
+			h.requests <- conn
+		}
+	}()
+	return nil
+}
+
+// withdraw saves the state of the plugins for the successor behind conn
+// and acknowledges the request. The caller stops the plugins afterwards.
+func (h *handover) withdraw(conn net.Conn) error {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(h.timeout))
+
+	reader := bufio.NewReader(conn)
+	request, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSpace(request) != handoverWithdraw {
+		return fmt.Errorf("invalid handover request %q: %v", request, err)
+	}
+
+	state := handoverState{Version: handoverStateV1, Resources: map[string]pluginState{}}
+	for _, qtedp := range h.plugins {
+		state.Resources[qtedp.provider.resourceName()] = qtedp.saveState()
+	}
+	if err := h.writeState(state); err != nil {
+		fmt.Fprintln(conn, err)
+		return fmt.Errorf("failed to save handover state: %v", err)
+	}
+
+	h.close()
+	if _, err := fmt.Fprintln(conn, handoverOK); err != nil {
+		return err
+	}
+	glog.V(0).Info("Handed over to the new daemon")
+	return nil
+}
+
+// removeStaleSockets removes the versioned sockets nothing listens on any
+// more, left behind by earlier daemons that crashed. The sockets of a
+// daemon still serving are kept.
+func (h *handover) removeStaleSockets() {
+	own := map[string]bool{}
+	for _, socket := range h.sockets {
+		own[socket] = true
+	}
+	for _, socket := range h.sockets {
+		i := strings.LastIndex(socket, "-")
+		if i < 0 {
+			continue
+		}
+		matches, _ := filepath.Glob(socket[:i] + "-*.sock")
+		for _, path := range matches {
+			if own[path] || !staleSocket(path) {
+				continue
+			}
+			if err := h.access.remove(path); err != nil {
+				glog.Errorf("Failed to remove stale socket %s: %v", path, err)
+				continue
+			}
+			glog.V(0).Info("Removed stale socket ", path)
+		}
+	}
+}
+
+// staleSocket tells whether path is a socket refusing connections.
+func staleSocket(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return false
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return false
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// close stops accepting requests, leaving the socket to the successor.
+func (h *handover) close() {
+	if h != nil && h.listener != nil {
+		h.listener.Close()
+		h.listener = nil
+	}
+}
+
+// writeState saves the state for the successor, through the privileged
+// helper if any.
+func (h *handover) writeState(state handoverState) error {
+	if h.access.helper == nil {
+		return writeFileAtomic(h.stateFile, state)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return h.access.helper.writeState(data)
+}
+
+// removeState removes the state restored from the predecessor.
+func (h *handover) removeState() error {
+	if h.access.helper != nil {
+		return h.access.helper.removeState()
+	}
+	if err := os.Remove(h.stateFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// writeFileAtomic writes v as JSON through a temporary file.
+func writeFileAtomic(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,220 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide handover between two daemons during an upgrade testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitee.com/openeuler/qt-enclave-k8s-device-plugin/fakekubelet"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// startHandoverDaemon runs a plugin and its monitor in handover mode, as a
+// daemon would. The returned channel is closed when the monitor exits.
+func startHandoverDaemon(t *testing.T, paths kubeletPaths, sim *simBackend) (*QtEnclavesDevicePlugin, chan struct{}) {
+	qtedp := newQtEnclavesDevicePlugin(paths, registrationModeDevicePlugin, newQingTianProvider(sim))
+	qtedp.socket = versionedSocket(qtedp.socket, newGeneration())
+	qtedp.healthCheckInterval = 50 * time.Millisecond
+	qtedp.cids, _ = newCIDPool("10-19", filepath.Join(paths.RootDir, "vsock"))
+
+	monitor := newPluginMonitor(qtedp, paths)
+	if monitor == nil {
+		t.Fatal("Failed to create plugin monitor")
+	}
+	monitor.handover = newHandover(paths.DevicePluginDir, e2eTimeout, []*QtEnclavesDevicePlugin{qtedp})
+	monitor.handover.sockets = []string{qtedp.socket}
+	previous, _ := os.Stat(monitor.handover.socket)
+
+	done := make(chan struct{})
+	go func() {
+		monitor.Run()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		monitor.fsWatcher.Close()
+		<-done
+	})
+
+	// The daemon is ready for a successor once it listens for one, on a
+	// socket replacing the one of its predecessor.
+	deadline := time.Now().Add(e2eTimeout)
+	for {
+		if info, err := os.Stat(monitor.handover.socket); err == nil && (previous == nil || !os.SameFile(previous, info)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Daemon did not finish its startup")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return qtedp, done
+}
+
+func TestHandover(t *testing.T) {
+	paths := newKubeletPaths(t.TempDir())
+	if err := os.MkdirAll(paths.DevicePluginDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	kubelet := fakekubelet.New(paths.DevicePluginDir)
+	if err := kubelet.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(kubelet.Stop)
+	sim, err := newSimBackend(filepath.Join(paths.RootDir, "dev"), simNodeFile, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, oldDone := startHandoverDaemon(t, paths, sim)
+	p, err := kubelet.WaitForPlugin(resourceName, e2eTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Allocate([]string{"qtbox_service1"}); err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	cid := old.cids.snapshot()["qtbox_service1"]
+	old.mu.Lock()
+	old.releasing["qtbox_service0"] = podRef{Namespace: "default", Name: "done"}
+	old.mu.Unlock()
+
+	successor, _ := startHandoverDaemon(t, paths, sim)
+	select {
+	case <-oldDone:
+	case <-time.After(e2eTimeout):
+		t.Fatal("Previous daemon did not withdraw")
+	}
+
+	regs := kubelet.Registrations()
+	if len(regs) != 2 || regs[0].Endpoint == regs[1].Endpoint || regs[1].Endpoint != filepath.Base(successor.socket) {
+		t.Fatalf("Unexpected registrations: %v", regs)
+	}
+	if _, err := os.Stat(old.socket); !os.IsNotExist(err) {
+		t.Fatalf("Previous socket should be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(paths.DevicePluginDir, handoverStateName)); !os.IsNotExist(err) {
+		t.Fatalf("Handover state should be consumed, got %v", err)
+	}
+
+	state := successor.saveState()
+	if _, ok := state.Allocations["qtbox_service1"]; !ok || state.CIDs["qtbox_service1"] != cid {
+		t.Fatalf("Allocation of the previous daemon not carried over: %+v", state)
+	}
+	if _, ok := state.Releasing["qtbox_service0"]; !ok {
+		t.Fatalf("Releasing device not carried over: %+v", state)
+	}
+
+	p, err = kubelet.WaitForPlugin(resourceName, e2eTimeout)
+	if err != nil || p.Endpoint != successor.socket {
+		t.Fatalf("Kubelet should use the new daemon, got %v: %v", p, err)
+	}
+	if _, err := p.WaitForDevices(func(devs []*pluginapi.Device) bool {
+		return len(devs) == 2 && healthOf(devs, "qtbox_service0") == pluginapi.Unhealthy
+	}, e2eTimeout); err != nil {
+		t.Fatalf("Releasing device should stay unhealthy: %v", err)
+	}
+}
+
+func TestRestoreCIDs(t *testing.T) {
+	pool, err := newCIDPool("10-11", filepath.Join(t.TempDir(), "vsock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.restore("qtbox_service0", 11); err != nil {
+		t.Fatal(err)
+	}
+	if cids, _, _ := pool.assign([]string{"qtbox_service1"}); len(cids) != 1 || cids[0] != 10 {
+		t.Fatalf("Restored CID should not be assigned again, got %v", cids)
+	}
+	if err := pool.restore("qtbox_service2", 11); err == nil {
+		t.Fatal("CID of another device should not be restored")
+	}
+	if err := pool.restore("qtbox_service2", 30); err == nil {
+		t.Fatal("CID outside of the pool should not be restored")
+	}
+}
+
+func TestRemoveStaleSockets(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "qtbox.sock")
+	own := versionedSocket(base, "own")
+	live := versionedSocket(base, "live")
+	stale := versionedSocket(base, "stale")
+	for _, path := range []string{own, live, stale} {
+		lis, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path == stale {
+			// A crashed daemon leaves its socket behind.
+			lis.(*net.UnixListener).SetUnlinkOnClose(false)
+		}
+		defer lis.Close()
+		if path == stale {
+			lis.Close()
+		}
+	}
+	other := versionedSocket(base, "file")
+	if err := os.WriteFile(other, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newHandover(dir, e2eTimeout, nil)
+	h.sockets = []string{own}
+	h.removeStaleSockets()
+
+	if _, err := os.Lstat(stale); !os.IsNotExist(err) {
+		t.Fatalf("Stale socket should be removed: %v", err)
+	}
+	for _, path := range []string{own, live, other} {
+		if _, err := os.Lstat(path); err != nil {
+			t.Fatalf("%s should be kept: %v", path, err)
+		}
+	}
+}
+
+func TestHandoverSocketAccess(t *testing.T) {
+	dir := t.TempDir()
+	h := newHandover(dir, e2eTimeout, nil)
+	h.access = newSocketAccess(0640, []uint32{uint32(os.Getuid()) + 1})
+	if err := h.listen(); err != nil {
+		t.Fatalf("Failed to listen on %s: %v", h.socket, err)
+	}
+	defer h.close()
+	if info, err := os.Stat(h.socket); err != nil || info.Mode().Perm() != 0640 {
+		t.Fatalf("Expected handover socket mode 0640, got %v: %v", info, err)
+	}
+
+	// A caller outside of the allowed UIDs is disconnected.
+	conn, err := net.Dial("unix", h.socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(e2eTimeout))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("Handover request from another UID should be rejected")
+	}
+	select {
+	case <-h.requests:
+		t.Fatal("Rejected handover request was passed on")
+	default:
+	}
+}
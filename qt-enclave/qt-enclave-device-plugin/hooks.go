@@ -0,0 +1,149 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide lifecycle hooks
+ *********************************************************************************/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+const (
+	hookDiscovery    = "discovery"
+	hookAllocate     = "allocate"
+	hookPreStart     = "pre-start"
+	hookRelease      = "release"
+	hookHealthChange = "health-change"
+
+	defaultHookTimeout = 10 * time.Second
+	// commandWaitDelay bounds the wait for the output of a killed command,
+	// in case it left children holding its stdout open.
+	commandWaitDelay = time.Second
+)
+
+// hookPayload is written as JSON to the standard input of a hook.
+type hookPayload struct {
+	Event    string   `json:"event"`
+	Resource string   `json:"resource"`
+	Devices  []string `json:"devices"`
+	Health   string   `json:"health,omitempty"`
+	Pod      *podRef  `json:"pod,omitempty"`
+}
+
+// hookRunner runs the site specific commands configured per lifecycle event.
+type hookRunner struct {
+	resource string
+	commands map[string]string
+	timeout  time.Duration
+	// helper runs the commands when the daemon dropped its privileges.
+	helper *privHelper
+}
+
+func newHookRunner(resource string, commands map[string]string, timeout time.Duration) *hookRunner {
+	hooks := &hookRunner{resource: resource, commands: map[string]string{}, timeout: timeout}
+	for event, command := range commands {
+		if strings.TrimSpace(command) != "" {
+			hooks.commands[event] = command
+		}
+	}
+
+	return hooks
+}
+
+// enabled tells whether a hook is configured for the event.
+func (h *hookRunner) enabled(event string) bool {
+	if h == nil {
+		return false
+	}
+	_, ok := h.commands[event]
+	return ok
+}
+
+// run executes the hook of the event, if any, and returns its error. The
+// hook fails when it exits non-zero or does not finish within the timeout.
+func (h *hookRunner) run(event string, devices []string, pod *podRef, health string) error {
+	if !h.enabled(event) {
+		return nil
+	}
+
+	payload, err := json.Marshal(hookPayload{
+		Event:    event,
+		Resource: h.resource,
+		Devices:  devices,
+		Health:   health,
+		Pod:      pod,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	if h.helper != nil {
+		err = h.helper.run(ctx, helperRequest{Name: helperHookPrefix + event, Stdin: payload})
+	} else {
+		err = runCommand(ctx, h.commands[event], nil, nil, bytes.NewReader(payload))
+	}
+	glog.V(1).Infof("Hook %s finished in %s (error: %v)", event, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("%s hook failed: %v", event, err)
+	}
+
+	return nil
+}
+
+// notify runs an informational hook in the background, logging its failure.
+func (h *hookRunner) notify(event string, devices []string, pod *podRef, health string) {
+	if !h.enabled(event) {
+		return
+	}
+
+	go func() {
+		if err := h.run(event, devices, pod, health); err != nil {
+			glog.Error(err)
+		}
+	}()
+}
+
+// runCommand runs a command line with extra arguments and environment,
+// returning its output in the error when it fails.
+func runCommand(ctx context.Context, command string, args, env []string, stdin io.Reader) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, fields[0], append(fields[1:], args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = stdin
+	cmd.WaitDelay = commandWaitDelay
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide lifecycle hooks testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func writeHookScript(t *testing.T, body string) string {
+	script := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"+body+"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestHookReceivesPayload(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "payload.json")
+	hooks := newHookRunner(resourceName, map[string]string{hookRelease: writeHookScript(t, "cat > "+out)}, time.Second)
+
+	pod := &podRef{Namespace: "default", Name: "pod-a", Container: "enclave"}
+	if err := hooks.run(hookRelease, []string{"qtbox_service0"}, pod, ""); err != nil {
+		t.Fatalf("Hook failed: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload hookPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("Invalid payload %s: %v", data, err)
+	}
+	if payload.Event != hookRelease || payload.Resource != resourceName ||
+		len(payload.Devices) != 1 || payload.Pod == nil || *payload.Pod != *pod {
+		t.Fatalf("Unexpected payload: %s", data)
+	}
+
+	// Unconfigured events are no-ops.
+	if err := hooks.run(hookAllocate, nil, nil, ""); err != nil {
+		t.Fatalf("Unconfigured hook failed: %v", err)
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	hooks := newHookRunner(resourceName, map[string]string{hookAllocate: writeHookScript(t, "exec sleep 5")}, 100*time.Millisecond)
+
+	start := time.Now()
+	if err := hooks.run(hookAllocate, []string{"qtbox_service0"}, nil, ""); err == nil {
+		t.Fatal("Hook should fail on timeout")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("Hook was not stopped on timeout")
+	}
+}
+
+func TestFailingHooksRejectOperations(t *testing.T) {
+	failing := writeHookScript(t, "echo enclave busy; exit 1")
+	qtedp := newTestAllocationPlugin()
+	qtedp.hooks = newHookRunner(resourceName, map[string]string{hookAllocate: failing, hookPreStart: failing}, time.Second)
+
+	_, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"}))
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Expected %s but got %v", codes.FailedPrecondition, err)
+	}
+	if len(qtedp.allocations) != 0 {
+		t.Fatalf("Rejected allocation left allocations behind: %v", qtedp.allocations)
+	}
+
+	opts, _ := qtedp.GetDevicePluginOptions(context.Background(), &pluginapi.Empty{})
+	if !opts.PreStartRequired {
+		t.Fatal("PreStartRequired should be set when a pre-start hook is configured")
+	}
+	_, err = qtedp.PreStartContainer(context.Background(), &pluginapi.PreStartContainerRequest{DevicesIDs: []string{"qtbox_service0"}})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Expected %s but got %v", codes.FailedPrecondition, err)
+	}
+}
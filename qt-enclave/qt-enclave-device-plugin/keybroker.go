@@ -0,0 +1,303 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the release of secrets gated on the enclave measurement
+ *********************************************************************************/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/sys/unix"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	defaultSecretDir           = "/run/qt-enclave/secrets"
+	defaultSecretContainerPath = "/run/qt-enclave/secrets"
+	defaultKeyBrokerTimeout    = 10 * time.Second
+	// maxSecretsSize bounds the key broker response.
+	maxSecretsSize = 1024 * 1024
+)
+
+// errMeasurementDenied is returned when the measurement of an enclave is
+// not allowed to receive secrets.
+var errMeasurementDenied = errors.New("measurement not allowed")
+
+// keyBrokerRequest presents the evidence of an enclave to the key broker.
+type keyBrokerRequest struct {
+	Resource    string `json:"resource"`
+	Device      string `json:"device"`
+	Measurement string `json:"measurement"`
+	// Report is base64 encoded.
+	Report    []byte `json:"report"`
+	CertChain string `json:"certChain"`
+}
+
+// keyBrokerResponse holds the sealed secrets by file name, base64 encoded.
+type keyBrokerResponse struct {
+	Secrets map[string][]byte `json:"secrets"`
+}
+
+// keyBroker gets the secrets of the enclaves from a key broker, which
+// answers 403 to evidence it does not trust, and writes them to a tmpfs
+// directory mounted by Allocate. Measurements outside of the allowlist are
+// denied without asking the broker.
+type keyBroker struct {
+	url    string
+	client *http.Client
+	// allowlist holds the allowed measurements, nil to leave the decision
+	// to the broker.
+	allowlist     map[string]bool
+	dir           string
+	containerPath string
+	// owner is the container user the secrets belong to, nil to keep the
+	// daemon user.
+	owner *syscall.Credential
+	// helper hands the secrets to owner when the daemon dropped its
+	// privileges.
+	helper *privHelper
+}
+
+// newKeyBroker returns a client of the key broker at url. An empty caFile
+// uses the system roots.
+func newKeyBroker(url, caFile, dir, containerPath string, timeout time.Duration) (*keyBroker, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid key broker %q, expected an http:// or https:// URL", url)
+	}
+	if !filepath.IsAbs(containerPath) {
+		return nil, fmt.Errorf("secret container path %q is not absolute", containerPath)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key broker CA: %v", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in key broker CA %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+
+	return &keyBroker{
+		url:           url,
+		client:        &http.Client{Transport: transport, Timeout: timeout},
+		dir:           dir,
+		containerPath: containerPath,
+	}, nil
+}
+
+// readMeasurementAllowlist reads hex measurements, one per line. Empty
+// lines and lines starting with # are skipped.
+func readMeasurementAllowlist(file string) (map[string]bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	allowlist := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := hex.DecodeString(line); err != nil {
+			return nil, fmt.Errorf("invalid measurement %q in %s", line, file)
+		}
+		allowlist[line] = true
+	}
+	return allowlist, scanner.Err()
+}
+
+// parseSecretOwner parses the numeric uid[:gid] of the container user. The
+// group defaults to the uid.
+func parseSecretOwner(spec string) (*syscall.Credential, error) {
+	uidSpec, gidSpec, hasGid := strings.Cut(spec, ":")
+	if !hasGid {
+		gidSpec = uidSpec
+	}
+	uid, err := strconv.ParseUint(uidSpec, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret owner %q, expected uid[:gid]", spec)
+	}
+	gid, err := strconv.ParseUint(gidSpec, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret owner %q, expected uid[:gid]", spec)
+	}
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}, nil
+}
+
+// checkTmpfs makes sure secrets written to dir never reach a disk.
+func checkTmpfs(dir string) error {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(dir, &fs); err != nil {
+		return err
+	}
+	if fs.Type != unix.TMPFS_MAGIC {
+		return fmt.Errorf("secret directory %s is not on a tmpfs", dir)
+	}
+	return nil
+}
+
+// prepare creates the empty secret directory of a container and returns its
+// mount. Only the owner may read the secrets.
+func (b *keyBroker) prepare(ids []string) (*pluginapi.Mount, error) {
+	hostPath := filepath.Join(b.dir, containerKey(ids))
+	if err := os.RemoveAll(hostPath); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(hostPath, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(hostPath, b.dirMode()); err != nil {
+		return nil, err
+	}
+	return &pluginapi.Mount{ContainerPath: b.containerPath, HostPath: hostPath, ReadOnly: true}, nil
+}
+
+// dirMode is the mode of the secret directories. They stay with the daemon,
+// which replaces and removes the secrets without privileges; only the files
+// are handed to the owner. An owner other than the daemon user lists and
+// enters the directories, the files stay private to it.
+func (b *keyBroker) dirMode() os.FileMode {
+	if b.owner == nil || b.owner.Uid == uint32(os.Getuid()) {
+		return 0700
+	}
+	return 0755
+}
+
+// own hands a secret file to the owner of the secrets, through the helper
+// if the daemon dropped its privileges.
+func (b *keyBroker) own(path string) error {
+	if b.owner == nil {
+		return nil
+	}
+	if b.helper != nil {
+		rel, err := filepath.Rel(b.dir, path)
+		if err != nil {
+			return err
+		}
+		return b.helper.run(context.Background(), helperRequest{Name: helperOwnSecret, Dir: rel})
+	}
+	return os.Lchown(path, int(b.owner.Uid), int(b.owner.Gid))
+}
+
+// release gets the secrets of an enclave.
+func (b *keyBroker) release(resource string, e *attestationEvidence) (map[string][]byte, error) {
+	if b.allowlist != nil && !b.allowlist[e.Measurement] {
+		return nil, fmt.Errorf("%w: %s", errMeasurementDenied, e.Measurement)
+	}
+
+	body, err := json.Marshal(keyBrokerRequest{
+		Resource:    resource,
+		Device:      e.Device,
+		Measurement: e.Measurement,
+		Report:      e.Report,
+		CertChain:   string(e.CertChain),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("key broker unavailable: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSecretsSize+1))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("%w by the key broker: %s", errMeasurementDenied, bytes.TrimSpace(data))
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("key broker returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	case len(data) > maxSecretsSize:
+		return nil, fmt.Errorf("key broker response larger than %d bytes", maxSecretsSize)
+	}
+
+	var secrets keyBrokerResponse
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("invalid key broker response: %v", err)
+	}
+	for name := range secrets.Secrets {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+			return nil, fmt.Errorf("invalid secret name %q", name)
+		}
+	}
+	return secrets.Secrets, nil
+}
+
+// write places the secrets of a device in <dir>/<device-id>, moved in
+// place once complete.
+func (b *keyBroker) write(ids []string, id string, secrets map[string][]byte) error {
+	parent := filepath.Join(b.dir, containerKey(ids))
+	staging, err := os.MkdirTemp(parent, "."+id+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	if err := os.Chmod(staging, b.dirMode()); err != nil {
+		return err
+	}
+	for name, data := range secrets {
+		file := filepath.Join(staging, name)
+		if err := os.WriteFile(file, data, 0600); err != nil {
+			return err
+		}
+		if err := b.own(file); err != nil {
+			return err
+		}
+	}
+
+	target := filepath.Join(parent, id)
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	return os.Rename(staging, target)
+}
+
+// forget removes the secrets of the containers that used a released
+// device, nil-safe.
+func (b *keyBroker) forget(id string) {
+	if b != nil {
+		forgetContainers(b.dir, id)
+	}
+}
@@ -0,0 +1,241 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the release of secrets gated on the enclave measurement testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// fakeKeyBroker releases its secrets to the allowed measurements.
+type fakeKeyBroker struct {
+	mu       sync.Mutex
+	allowed  map[string]bool
+	secrets  map[string][]byte
+	requests []keyBrokerRequest
+}
+
+func (b *fakeKeyBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req keyBrokerRequest
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = append(b.requests, req)
+	if !b.allowed[req.Measurement] || len(req.Report) == 0 || !strings.Contains(req.CertChain, "CERTIFICATE") {
+		http.Error(w, "measurement not in policy", http.StatusForbidden)
+		return
+	}
+	json.NewEncoder(w).Encode(keyBrokerResponse{Secrets: b.secrets})
+}
+
+func (b *fakeKeyBroker) received() []keyBrokerRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]keyBrokerRequest(nil), b.requests...)
+}
+
+func newTestKeyBrokerPlugin(t *testing.T, kbs *fakeKeyBroker) (*QtEnclavesDevicePlugin, string) {
+	srv := httptest.NewServer(kbs)
+	t.Cleanup(srv.Close)
+
+	qtedp := newTestAttestationPlugin(t, &simAttestation{}, attestationFail)
+	broker, err := newKeyBroker(srv.URL+"/release", "", t.TempDir(), defaultSecretContainerPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qtedp.keyBroker = broker
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	qtedp.audit, _ = newAuditLog(auditFile, testAuditKey, defaultAuditMaxSize, 1)
+	t.Cleanup(func() { qtedp.audit.Close() })
+	return qtedp, auditFile
+}
+
+func startWithSecrets(qtedp *QtEnclavesDevicePlugin, ids []string) (*pluginapi.AllocateResponse, error) {
+	resp, err := qtedp.Allocate(context.Background(), allocateRequest(ids))
+	if err != nil {
+		return nil, err
+	}
+	_, err = qtedp.PreStartContainer(context.Background(), &pluginapi.PreStartContainerRequest{DevicesIDs: ids})
+	return resp, err
+}
+
+func TestSecretRelease(t *testing.T) {
+	measurement := hex.EncodeToString(simMeasurement[:])
+	kbs := &fakeKeyBroker{
+		allowed: map[string]bool{measurement: true},
+		secrets: map[string][]byte{"db-password": []byte("s3cret")},
+	}
+	qtedp, auditFile := newTestKeyBrokerPlugin(t, kbs)
+	qtedp.keyBroker.owner = &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+
+	resp, err := startWithSecrets(qtedp, []string{"qtbox_service0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounts := resp.ContainerResponses[0].Mounts
+	if len(mounts) != 2 || mounts[1].ContainerPath != defaultSecretContainerPath || !mounts[1].ReadOnly {
+		t.Fatalf("Unexpected mounts %v", mounts)
+	}
+	secret := filepath.Join(mounts[1].HostPath, "qtbox_service0", "db-password")
+	data, err := os.ReadFile(secret)
+	if err != nil || string(data) != "s3cret" {
+		t.Fatalf("Secret not released: %q %v", data, err)
+	}
+	for path, mode := range map[string]os.FileMode{mounts[1].HostPath: 0700 | os.ModeDir,
+		filepath.Dir(secret): 0700 | os.ModeDir, secret: 0600} {
+		info, err := os.Stat(path)
+		if err != nil || info.Mode() != mode || info.Sys().(*syscall.Stat_t).Uid != uint32(os.Getuid()) {
+			t.Fatalf("%s should be private to the owner, got %v: %v", path, info.Mode(), err)
+		}
+	}
+
+	// A container started again gets its secrets again.
+	if _, err := qtedp.PreStartContainer(context.Background(), &pluginapi.PreStartContainerRequest{DevicesIDs: []string{"qtbox_service0"}}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(secret); err != nil || string(data) != "s3cret" {
+		t.Fatalf("Secret not released again: %q %v", data, err)
+	}
+	if reqs := kbs.received(); len(reqs) != 2 || reqs[0].Device != "qtbox_service0" ||
+		reqs[0].Measurement != measurement || reqs[0].Resource != resourceName {
+		t.Fatalf("Unexpected key broker requests %+v", reqs)
+	}
+
+	qtedp.keyBroker.forget("qtbox_service0")
+	if _, err := os.Stat(mounts[1].HostPath); !os.IsNotExist(err) {
+		t.Fatalf("Secrets should be removed with the device, got %v", err)
+	}
+	audit, _ := os.ReadFile(auditFile)
+	if !strings.Contains(string(audit), `"event":"secret-release","resource":"huawei.com/qt_enclaves","devices":["qtbox_service0"],"action":"release"`) {
+		t.Fatalf("Secret release not audited: %s", audit)
+	}
+}
+
+func TestSecretReleaseToOtherOwner(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("handing files to another user needs root")
+	}
+	measurement := hex.EncodeToString(simMeasurement[:])
+	kbs := &fakeKeyBroker{
+		allowed: map[string]bool{measurement: true},
+		secrets: map[string][]byte{"db-password": []byte("s3cret")},
+	}
+	qtedp, _ := newTestKeyBrokerPlugin(t, kbs)
+	owner := &syscall.Credential{Uid: 4242, Gid: 4243}
+	qtedp.keyBroker.owner = owner
+	dir, err := filepath.EvalSymlinks(qtedp.keyBroker.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qtedp.keyBroker.dir = dir
+	qtedp.keyBroker.helper = startTestHelper(t, &helperConfig{secretDir: dir, secretOwner: owner})
+
+	resp, err := startWithSecrets(qtedp, []string{"qtbox_service0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The daemon keeps the directories, so it replaces the secrets of a
+	// container started again without privileges.
+	if _, err := qtedp.PreStartContainer(context.Background(), &pluginapi.PreStartContainerRequest{DevicesIDs: []string{"qtbox_service0"}}); err != nil {
+		t.Fatal(err)
+	}
+	hostPath := resp.ContainerResponses[0].Mounts[1].HostPath
+	secret := filepath.Join(hostPath, "qtbox_service0", "db-password")
+	for path, want := range map[string]struct {
+		mode os.FileMode
+		uid  uint32
+	}{
+		hostPath:             {0755 | os.ModeDir, 0},
+		filepath.Dir(secret): {0755 | os.ModeDir, 0},
+		secret:               {0600, owner.Uid},
+	} {
+		info, err := os.Stat(path)
+		if err != nil || info.Mode() != want.mode || info.Sys().(*syscall.Stat_t).Uid != want.uid {
+			t.Fatalf("%s should have mode %v and uid %d, got %v: %v", path, want.mode, want.uid, info, err)
+		}
+	}
+}
+
+func TestSecretReleaseDenied(t *testing.T) {
+	kbs := &fakeKeyBroker{secrets: map[string][]byte{"key": []byte("k")}}
+	qtedp, auditFile := newTestKeyBrokerPlugin(t, kbs)
+
+	_, err := startWithSecrets(qtedp, []string{"qtbox_service0"})
+	if status.Code(err) != codes.FailedPrecondition || !strings.Contains(err.Error(), "measurement not in policy") {
+		t.Fatalf("Mismatching measurement should fail the container start, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(qtedp.keyBroker.dir, "qtbox_service0")); len(entries) != 0 {
+		t.Fatalf("No secret should be released: %v", entries)
+	}
+
+	// The local allowlist denies without asking the broker.
+	qtedp.keyBroker.allowlist = map[string]bool{strings.Repeat("00", 32): true}
+	if _, err := startWithSecrets(qtedp, []string{"qtbox_service1"}); err == nil {
+		t.Fatal("Measurement outside of the allowlist should fail the container start")
+	}
+	if n := len(kbs.received()); n != 1 {
+		t.Fatalf("Key broker should not be asked for a measurement outside of the allowlist, got %d requests", n)
+	}
+
+	audit, _ := os.ReadFile(auditFile)
+	if n := strings.Count(string(audit), `"action":"deny"`); n != 2 {
+		t.Fatalf("Expected 2 audited denials, got %d: %s", n, audit)
+	}
+	if _, err := verifyAuditLog([]string{auditFile}, testAuditKey); err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+}
+
+func TestSecretReleaseRejectsInvalidNames(t *testing.T) {
+	kbs := &fakeKeyBroker{
+		allowed: map[string]bool{hex.EncodeToString(simMeasurement[:]): true},
+		secrets: map[string][]byte{"../escape": []byte("x")},
+	}
+	qtedp, _ := newTestKeyBrokerPlugin(t, kbs)
+	if _, err := startWithSecrets(qtedp, []string{"qtbox_service0"}); err == nil || !strings.Contains(err.Error(), "invalid secret name") {
+		t.Fatalf("Secret names with a path should be rejected, got %v", err)
+	}
+}
+
+func TestMeasurementAllowlist(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "allowlist")
+	writeTestFile(t, file, "# production image\nABCDEF01\n\n00ff\n")
+	allowlist, err := readMeasurementAllowlist(file)
+	if err != nil || len(allowlist) != 2 || !allowlist["abcdef01"] {
+		t.Fatalf("Unexpected allowlist %v: %v", allowlist, err)
+	}
+	writeTestFile(t, file, "not-hex\n")
+	if _, err := readMeasurementAllowlist(file); err == nil {
+		t.Fatal("Invalid measurement should be rejected")
+	}
+}
@@ -0,0 +1,126 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide a client of the Kubernetes pod and node API
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultKubeTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultKubeCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// kubeClient calls the pod and node API of the API server with a service
+// account token. client-go reads the token file again when kubelet rotates
+// it.
+type kubeClient struct {
+	client kubernetes.Interface
+}
+
+// newKubeClient returns a client of server, or of the API server of the
+// cluster the daemon runs in if server is empty, trusting the CA in caFile
+// or the system roots.
+func newKubeClient(server, tokenFile, caFile string) (*kubeClient, error) {
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("no API server configured and not running in a cluster")
+		}
+		server = "https://" + net.JoinHostPort(host, port)
+	}
+	if !strings.HasPrefix(server, "http://") && !strings.HasPrefix(server, "https://") {
+		return nil, fmt.Errorf("invalid API server %q, expected an http:// or https:// URL", server)
+	}
+	client, err := kubernetes.NewForConfig(&rest.Config{
+		Host:            server,
+		BearerTokenFile: tokenFile,
+		TLSClientConfig: rest.TLSClientConfig{CAFile: caFile},
+		UserAgent:       "qt-enclave-device-plugin",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &kubeClient{client: client}, nil
+}
+
+func (c *kubeClient) annotatePod(ctx context.Context, namespace, name string, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.client.CoreV1().Pods(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// evictPod evicts through the Eviction API, which honours the disruption
+// budgets of the pod.
+func (c *kubeClient) evictPod(ctx context.Context, namespace, name string) error {
+	err := c.client.PolicyV1().Evictions(namespace).Evict(ctx, &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+	})
+	if apierrors.IsTooManyRequests(err) {
+		return fmt.Errorf("eviction refused, a disruption budget may be exhausted: %v", err)
+	}
+	return err
+}
+
+func (c *kubeClient) deletePod(ctx context.Context, namespace, name string) error {
+	return c.client.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// nodeCondition returns the condition of the node status of the given type,
+// nil if the node has none.
+func (c *kubeClient) nodeCondition(ctx context.Context, node string, conditionType corev1.NodeConditionType) (*corev1.NodeCondition, error) {
+	n, err := c.client.CoreV1().Nodes().Get(ctx, node, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range n.Status.Conditions {
+		if n.Status.Conditions[i].Type == conditionType {
+			return &n.Status.Conditions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// setNodeCondition adds or replaces a condition of the node status. The
+// strategic merge patch merges the conditions by type.
+func (c *kubeClient) setNodeCondition(ctx context.Context, node string, condition corev1.NodeCondition) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{"conditions": []corev1.NodeCondition{condition}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.client.CoreV1().Nodes().PatchStatus(ctx, node, patch)
+	return err
+}
@@ -0,0 +1,94 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide kubelet paths and plugin watcher registration
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	registerapi "k8s.io/kubelet/pkg/apis/pluginregistration/v1"
+)
+
+const (
+	defaultKubeletRootDir  = "/var/lib/kubelet"
+	devicePluginDirName    = "device-plugins"
+	pluginsRegistryDirName = "plugins_registry"
+	podResourcesDirName    = "pod-resources"
+	kubeletSocketName      = "kubelet.sock"
+	cpuManagerStateName    = "cpu_manager_state"
+
+	// registrationModeDevicePlugin registers by calling Register on the kubelet socket.
+	registrationModeDevicePlugin = "device-plugin"
+	// registrationModePluginWatcher lets the kubelet plugin watcher discover the plugin
+	// through a socket in the plugins_registry directory.
+	registrationModePluginWatcher = "plugin-watcher"
+)
+
+// kubeletPaths describes where the kubelet expects to find and serve plugin sockets.
+type kubeletPaths struct {
+	RootDir            string
+	DevicePluginDir    string
+	KubeletSocket      string
+	PluginsRegistryDir string
+	PodResourcesSocket string
+	CPUManagerState    string
+}
+
+// newKubeletPaths derives the plugin socket locations from the kubelet root directory.
+func newKubeletPaths(rootDir string) kubeletPaths {
+	devicePluginDir := filepath.Join(rootDir, devicePluginDirName)
+	return kubeletPaths{
+		RootDir:            rootDir,
+		DevicePluginDir:    devicePluginDir,
+		KubeletSocket:      filepath.Join(devicePluginDir, kubeletSocketName),
+		PluginsRegistryDir: filepath.Join(rootDir, pluginsRegistryDirName),
+		PodResourcesSocket: filepath.Join(rootDir, podResourcesDirName, kubeletSocketName),
+		CPUManagerState:    filepath.Join(rootDir, cpuManagerStateName),
+	}
+}
+
+func validateRegistrationMode(mode string) error {
+	switch mode {
+	case registrationModeDevicePlugin, registrationModePluginWatcher:
+		return nil
+	default:
+		return fmt.Errorf("unknown registration mode: %s", mode)
+	}
+}
+
+// GetInfo is called by the kubelet plugin watcher to identify the plugin
+func (qtedp *QtEnclavesDevicePlugin) GetInfo(ctx context.Context, req *registerapi.InfoRequest) (*registerapi.PluginInfo, error) {
+	return &registerapi.PluginInfo{
+		Type:              registerapi.DevicePlugin,
+		Name:              qtedp.provider.resourceName(),
+		Endpoint:          qtedp.socket,
+		SupportedVersions: []string{pluginapi.Version},
+	}, nil
+}
+
+// NotifyRegistrationStatus is called by the kubelet plugin watcher with the registration result
+func (qtedp *QtEnclavesDevicePlugin) NotifyRegistrationStatus(ctx context.Context, status *registerapi.RegistrationStatus) (*registerapi.RegistrationStatusResponse, error) {
+	if status.PluginRegistered {
+		glog.V(0).Info("Registered device plugin through plugin watcher: ", qtedp.provider.resourceName())
+	} else {
+		glog.Errorf("Plugin watcher failed to register device plugin! (Reason: %s)", status.Error)
+	}
+
+	return &registerapi.RegistrationStatusResponse{}, nil
+}
@@ -0,0 +1,100 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide kubelet paths and plugin watcher testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	registerapi "k8s.io/kubelet/pkg/apis/pluginregistration/v1"
+)
+
+func TestKubeletPathsFollowRootDir(t *testing.T) {
+	paths := newKubeletPaths("/var/lib/rancher/k3s/agent/kubelet")
+
+	if paths.KubeletSocket != "/var/lib/rancher/k3s/agent/kubelet/device-plugins/kubelet.sock" {
+		t.Fatalf("Unexpected kubelet socket: %s", paths.KubeletSocket)
+	}
+	if paths.PluginsRegistryDir != "/var/lib/rancher/k3s/agent/kubelet/plugins_registry" {
+		t.Fatalf("Unexpected plugins registry dir: %s", paths.PluginsRegistryDir)
+	}
+}
+
+// A fake plugin watcher discovers the plugin through its registration
+// socket, the same way kubelet does.
+func TestPluginWatcherRegistration(t *testing.T) {
+	root := t.TempDir()
+	paths := newKubeletPaths(root)
+	for _, dir := range []string{paths.DevicePluginDir, paths.PluginsRegistryDir} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+
+	qtedp := newTestDevicePlugin(paths, registrationModePluginWatcher)
+	if err := qtedp.Start(); err != nil {
+		t.Fatalf("Failed to start device plugin: %v", err)
+	}
+	defer qtedp.Stop()
+
+	conn, err := dial(filepath.Join(paths.PluginsRegistryDir, registrationSocketName(socketName)), devicePluginServerReadyTimeout)
+	if err != nil {
+		t.Fatalf("Failed to dial registration socket: %v", err)
+	}
+	defer conn.Close()
+
+	client := registerapi.NewRegistrationClient(conn)
+	info, err := client.GetInfo(context.Background(), &registerapi.InfoRequest{})
+	if err != nil {
+		t.Fatalf("GetInfo failed: %v", err)
+	}
+	if info.Type != registerapi.DevicePlugin || info.Name != resourceName {
+		t.Fatalf("Unexpected plugin info: %+v", info)
+	}
+	if info.Endpoint != filepath.Join(paths.DevicePluginDir, socketName) {
+		t.Fatalf("Unexpected endpoint: %s", info.Endpoint)
+	}
+	if len(info.SupportedVersions) != 1 || info.SupportedVersions[0] != pluginapi.Version {
+		t.Fatalf("Unexpected supported versions: %v", info.SupportedVersions)
+	}
+
+	// The advertised endpoint must serve the device plugin API.
+	dpConn, err := dial(info.Endpoint, devicePluginServerReadyTimeout)
+	if err != nil {
+		t.Fatalf("Failed to dial endpoint: %v", err)
+	}
+	defer dpConn.Close()
+	if _, err := pluginapi.NewDevicePluginClient(dpConn).GetDevicePluginOptions(context.Background(), &pluginapi.Empty{}); err != nil {
+		t.Fatalf("GetDevicePluginOptions failed: %v", err)
+	}
+
+	_, err = client.NotifyRegistrationStatus(context.Background(), &registerapi.RegistrationStatus{PluginRegistered: true})
+	if err != nil {
+		t.Fatalf("NotifyRegistrationStatus failed: %v", err)
+	}
+
+	if err := qtedp.Stop(); err != nil {
+		t.Fatalf("Failed to stop device plugin: %v", err)
+	}
+	for _, sock := range []string{qtedp.socket, qtedp.regSocket} {
+		if _, err := os.Stat(sock); !os.IsNotExist(err) {
+			t.Fatalf("Socket %s still exists after stop", sock)
+		}
+	}
+}
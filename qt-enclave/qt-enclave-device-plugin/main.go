@@ -17,22 +17,728 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/golang/glog"
 )
 
+var (
+	providers          = flag.String("providers", providerQingTian, "comma separated enclave providers to serve: qingtian, sgx, nitro")
+	kubeletRootDir     = flag.String("kubelet-root-dir", defaultKubeletRootDir, "kubelet root directory, as passed to kubelet --root-dir")
+	devicePluginDir    = flag.String("device-plugin-dir", "", "kubelet device plugin directory (default <kubelet-root-dir>/device-plugins)")
+	kubeletSocket      = flag.String("kubelet-socket", "", "kubelet registration socket (default <device-plugin-dir>/kubelet.sock)")
+	pluginsRegistryDir = flag.String("plugins-registry-dir", "", "kubelet plugin watcher directory (default <kubelet-root-dir>/plugins_registry)")
+	podResourcesSocket = flag.String("pod-resources-socket", "", "kubelet pod resources socket (default <kubelet-root-dir>/pod-resources/kubelet.sock)")
+	cpuManagerState    = flag.String("cpu-manager-state", "", "kubelet CPU manager checkpoint (default <kubelet-root-dir>/cpu_manager_state)")
+	registrationMode   = flag.String("registration-mode", registrationModeDevicePlugin, "how to register with kubelet: device-plugin or plugin-watcher")
+	pluginSocketMode   = flag.String("socket-mode", "0600", "file mode of the plugin sockets")
+	allowedUIDs        = flag.String("allowed-uids", defaultAllowedUIDs, "comma separated UIDs allowed to call the plugin sockets, empty allows everybody")
+	cordonFile         = flag.String("cordon-file", "", "file listing cordoned device IDs, one per line")
+	doubleBooking      = flag.String("double-booking-check", doubleBookingFailOpen, "check of the devices allocated again through the pod resources API: off, fail-open or fail-closed")
+	containerPathMode  = flag.String("container-path-mode", containerPathHost, "device path inside the container: host, stable or template")
+	containerPathTmpl  = flag.String("container-path-template", "", "container device path for template mode, may use {index} and {id}")
+	devicePerms        = flag.String("device-permissions", "", "device permissions per resource, as <resource>=<perms>,... (default rw)")
+	releaseDetection   = flag.Bool("release-detection", false, "detect released devices through the kubelet pod resources API")
+	releasePoll        = flag.Duration("release-poll-interval", defaultReleasePollInterval, "interval between two pod resources snapshots")
+	releaseCleanupCmd  = flag.String("release-cleanup-command", "", "command run with the device path as last argument to reset a released device")
+	releaseCleanupTime = flag.Duration("release-cleanup-timeout", defaultReleaseCleanupTimeout, "timeout of the release cleanup command")
+	hookDiscoveryCmd   = flag.String("hook-discovery", "", "command run when the devices are advertised")
+	hookAllocateCmd    = flag.String("hook-allocate", "", "command run on Allocate, a failure rejects the allocation")
+	hookPreStartCmd    = flag.String("hook-pre-start", "", "command run on PreStartContainer, a failure prevents the container start")
+	hookReleaseCmd     = flag.String("hook-release", "", "command run after a released device is cleaned up, a failure keeps it unhealthy")
+	hookHealthCmd      = flag.String("hook-health-change", "", "command run when the health of a device changes")
+	hookTimeout        = flag.Duration("hook-timeout", defaultHookTimeout, "timeout of a hook command")
+	auditLogFile       = flag.String("audit-log", "", "append-only JSON Lines audit log of allocations and device state changes")
+	auditLogMaxSize    = flag.Int64("audit-log-max-size", defaultAuditMaxSize, "size in bytes at which the audit log is rotated")
+	auditLogMaxFiles   = flag.Int("audit-log-max-files", defaultAuditMaxFiles, "number of rotated audit log files kept")
+	auditKeyFile       = flag.String("audit-key-file", "", "file of the HMAC key, at least 32 bytes, chaining the audit records, kept outside the audit log directory")
+	vsockCIDPool       = flag.String("vsock-cid-pool", "", "vsock CIDs assigned to allocated devices, as ranges such as 100-199,300 (default disabled)")
+	vsockDevice        = flag.String("vsock-device", defaultVsockDevice, "vsock device used to detect the CID of this node")
+	enclaveMemoryMB    = flag.Int("enclave-memory-mb", 0, "enclave memory in MiB advertised as "+memoryResourceName)
+	enclaveMemoryHP    = flag.Bool("enclave-memory-hugepages", false, "advertise the host hugepage pool as "+memoryResourceName)
+	enclaveMemoryUnit  = flag.Int("enclave-memory-unit-mb", defaultMemoryUnitMB, "MiB of enclave memory per "+memoryResourceName+" unit")
+	hugepagesDir       = flag.String("hugepages-dir", defaultHugepagesDir, "sysfs directory of the hugepage pools")
+	enclaveCPUs        = flag.Int("enclave-cpus", 0, "enclave CPUs advertised as "+cpuResourceName)
+	enclaveCPUPoolFile = flag.String("enclave-cpu-pool-file", "", "sysfs cpulist file of the enclave CPU pool advertised as "+cpuResourceName)
+	capacityPoll       = flag.Duration("capacity-poll-interval", defaultCapacityPollInterval, "interval between two reads of the enclave memory and CPU capacity")
+	sgxSlots           = flag.Int("sgx-enclaves-per-node", defaultSGXEnclavesPerNode, "number of "+sgxResourceName+" advertised on SGX nodes")
+	sgxProvision       = flag.Bool("sgx-provision", false, "also expose /dev/"+sgxProvisionDevice+" to SGX containers")
+	sgxEPC             = flag.Bool("sgx-epc", false, "advertise the SGX EPC size in MiB as "+sgxEPCResourceName)
+	nitroSlots         = flag.Int("nitro-enclaves-per-node", defaultNitroEnclavesPerNode, "number of "+nitroResourceName+" advertised on nitro enclaves nodes")
+	nitroCPUPoolFile   = flag.String("nitro-cpu-pool-file", defaultNitroCPUPoolFile, "sysfs cpulist file of the nitro enclaves CPU pool")
+	nitroMinCPUs       = flag.Int("nitro-min-cpus", defaultNitroMinCPUs, "CPUs the nitro enclaves CPU pool needs at least")
+	nitroMinMemoryMB   = flag.Int("nitro-min-memory-mb", 0, "MiB of hugepages nitro enclaves need at least")
+	cpuSysfsDir        = flag.String("cpu-sysfs-dir", defaultCPUSysfsDir, "sysfs directory of the CPUs")
+	nodeSysfsDir       = flag.String("node-sysfs-dir", defaultNodeSysfsDir, "sysfs directory of the NUMA nodes")
+	deviceMajors       = flag.String("device-majors", "", "major number of the device nodes per provider, as <provider>=<major>,... (default sgx=10,nitro=10)")
+	deviceOwnerUID     = flag.Int("device-owner-uid", defaultDeviceOwnerUID, "uid that must own the device nodes")
+	deviceOwnerGID     = flag.Int("device-owner-gid", anyGID, "gid that must own the device nodes (default any)")
+	rootPrefix         = flag.String("root-prefix", "", "prefix of all host paths, such as /dev and the kubelet directories")
+	simulate           = flag.Bool("simulate", false, "simulate the enclave devices instead of using QingTian hardware")
+	simDir             = flag.String("sim-dir", "", "directory of the simulated device nodes (default a new temporary directory)")
+	simDevices         = flag.Int("sim-devices", enclavesPerInstance, "number of simulated devices created at startup")
+	simNodeType        = flag.String("sim-node-type", simNodeFile, "type of the simulated device nodes: file or fifo")
+	handoverMode       = flag.Bool("handover", false, "take over from the running daemon on upgrades without withdrawing the resources")
+	handoverTimeout    = flag.Duration("handover-timeout", defaultHandoverTimeout, "time the daemons wait for each other during a handover")
+	metricsAddress     = flag.String("metrics-address", "", "address serving the RPC metrics on "+metricsPath+", such as :9410 (default disabled)")
+	otlpEndpoint       = flag.String("otlp-endpoint", "", "OTLP/HTTP collector receiving the RPC spans, such as http://localhost:4318 (default disabled)")
+	otlpServiceName    = flag.String("otlp-service-name", defaultOTLPServiceName, "service name of the exported spans")
+	runAsUser          = flag.String("run-as-user", "", "unprivileged user the daemon drops to after setup, hooks and cleanup stay privileged (default disabled)")
+	runAsGroup         = flag.String("run-as-group", "", "group the daemon drops to (default the primary group of -run-as-user)")
+	keepCapabilities   = flag.String("keep-capabilities", defaultKeepCapabilities, "comma separated capabilities kept after dropping privileges")
+	remediationPolicy  = flag.String("remediation-policy", remediationNone, "action on the pods of devices failed for longer than the grace period: none, annotate, evict or delete")
+	remediationGrace   = flag.Duration("remediation-grace-period", defaultRemediationGracePeriod, "time a device must stay failed before its pods are remediated")
+	remediationRate    = flag.Float64("remediation-rate", defaultRemediationRate, "pods remediated per minute at most, over all resources")
+	remediationBurst   = flag.Int("remediation-burst", defaultRemediationBurst, "pods remediated at once at most")
+	remediationDryRun  = flag.Bool("remediation-dry-run", false, "log and audit the remediation actions without taking them")
+	attestationKind    = flag.String("attestation-provider", "", "provider of the attestation evidence placed in the containers: command or sim (default disabled)")
+	attestationCmd     = flag.String("attestation-command", "", "command run with the device path and an output directory as last arguments to write the evidence of the command provider")
+	attestationDir     = flag.String("attestation-dir", defaultAttestationDir, "host directory of the attestation evidence of the containers")
+	attestationPath    = flag.String("attestation-container-path", defaultAttestationContainerPath, "directory of the attestation evidence inside the containers")
+	attestationPolicy  = flag.String("attestation-failure-policy", attestationFail, "what to do when the evidence cannot be collected: fail the container start or ignore")
+	attestationRetries = flag.Int("attestation-retries", defaultAttestationRetries, "retries of a failed evidence collection")
+	attestationTimeout = flag.Duration("attestation-timeout", defaultAttestationTimeout, "timeout of an evidence collection")
+	keyBrokerURL       = flag.String("key-broker-url", "", "key broker releasing secrets to the attested enclaves, such as https://kbs:8443/release (default disabled)")
+	keyBrokerCAFile    = flag.String("key-broker-ca-file", "", "CA certificate of the key broker, empty uses the system roots")
+	keyBrokerTimeout   = flag.Duration("key-broker-timeout", defaultKeyBrokerTimeout, "timeout of a key broker request")
+	measurementList    = flag.String("measurement-allowlist", "", "file of the enclave measurements allowed to receive secrets, one per line (default the key broker decides)")
+	secretDir          = flag.String("secret-dir", defaultSecretDir, "host tmpfs directory of the secrets of the containers")
+	secretPath         = flag.String("secret-container-path", defaultSecretContainerPath, "directory of the secrets inside the containers")
+	secretOwner        = flag.String("secret-owner", "", "numeric uid[:gid] of the container user owning the secrets (default the daemon user)")
+	cpuOverlapReport   = flag.String("cpu-overlap-report", defaultCPUOverlapReport, "comma separated ways to report enclave CPUs assigned by the kubelet CPU manager: log, metric, node-condition, device-health")
+	nodeName           = flag.String("node-name", os.Getenv("NODE_NAME"), "name of this node, for the node-condition CPU overlap report")
+	kubeAPIServer      = flag.String("kube-api-server", "", "API server used for remediation (default the in-cluster API server)")
+	kubeTokenFile      = flag.String("kube-token-file", defaultKubeTokenFile, "bearer token file used to call the API server")
+	kubeCAFile         = flag.String("kube-ca-file", defaultKubeCAFile, "CA certificate of the API server, empty uses the system roots")
+	simControlSocket   = flag.String("sim-control-socket", "", "unix socket of the simulation control API (default <sim-dir>/control.sock)")
+)
+
+// prefixed applies the root prefix to a host path.
+func prefixed(path string) string {
+	if *rootPrefix == "" || path == "" {
+		return path
+	}
+	return filepath.Join(*rootPrefix, path)
+}
+
+// pathsFromFlags builds the kubelet paths, letting explicit flags override
+// the locations derived from the kubelet root directory.
+func pathsFromFlags() kubeletPaths {
+	paths := newKubeletPaths(prefixed(*kubeletRootDir))
+	if *devicePluginDir != "" {
+		paths.DevicePluginDir = prefixed(*devicePluginDir)
+		paths.KubeletSocket = filepath.Join(paths.DevicePluginDir, kubeletSocketName)
+	}
+	if *kubeletSocket != "" {
+		paths.KubeletSocket = prefixed(*kubeletSocket)
+	}
+	if *pluginsRegistryDir != "" {
+		paths.PluginsRegistryDir = prefixed(*pluginsRegistryDir)
+	}
+	if *podResourcesSocket != "" {
+		paths.PodResourcesSocket = prefixed(*podResourcesSocket)
+	}
+	if *cpuManagerState != "" {
+		paths.CPUManagerState = prefixed(*cpuManagerState)
+	}
+
+	return paths
+}
+
+// backendFromFlags returns the simulation backend, with its control API
+// running, or the host backend verifying its nodes with nodes.
+func backendFromFlags(nodes *nodeVerifier) (deviceBackend, error) {
+	if !*simulate {
+		b := newHostBackend(prefixed(defaultDevRoot))
+		b.nodes = nodes
+		return b, nil
+	}
+
+	dir := prefixed(*simDir)
+	if dir == "" {
+		var err error
+		if dir, err = os.MkdirTemp("", "qt-enclave-sim"); err != nil {
+			return nil, err
+		}
+	}
+	sim, err := newSimBackend(dir, *simNodeType, *simDevices)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(0).Info("Simulating Qt Enclaves devices in: ", dir)
+
+	socket := *simControlSocket
+	if socket == "" {
+		socket = filepath.Join(dir, simControlSocketName)
+	}
+	if _, err := sim.serveControl(socket); err != nil {
+		return nil, err
+	}
+
+	return sim, nil
+}
+
+// providersFromFlags returns the configured enclave providers.
+func providersFromFlags() ([]EnclaveProvider, error) {
+	names, err := parseProviders(*providers)
+	if err != nil {
+		return nil, err
+	}
+	majors, err := parseDeviceMajors(*deviceMajors)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []EnclaveProvider
+	for _, name := range names {
+		major := deviceMajor(majors, name)
+		// The qtbox driver gets a dynamic major number, there is no default,
+		// the other checks still apply without it.
+		if major == anyMajor && !*simulate {
+			glog.Warningf("No device major number configured for %s, the major number of its device nodes is not checked, set it with -device-majors=%s=<major> (see /proc/devices)", name, name)
+		}
+		nodes := newNodeVerifier(major, *deviceOwnerUID, *deviceOwnerGID)
+
+		switch name {
+		case providerQingTian:
+			backend, err := backendFromFlags(nodes)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, newQingTianProvider(backend))
+		case providerSGX:
+			sgx := newSGXProvider(prefixed(defaultDevRoot), *sgxSlots, *sgxProvision)
+			sgx.nodes = nodes
+			result = append(result, sgx)
+		case providerNitro:
+			nitro := newNitroProvider(prefixed(defaultDevRoot), *nitroSlots)
+			nitro.nodes = nodes
+			nitro.cpuPoolFile = prefixed(*nitroCPUPoolFile)
+			nitro.cpuSysfsDir = prefixed(*cpuSysfsDir)
+			nitro.hugepagesDir = prefixed(*hugepagesDir)
+			nitro.minCPUs = *nitroMinCPUs
+			nitro.minMemoryMB = *nitroMinMemoryMB
+			result = append(result, nitro)
+		}
+	}
+	return result, nil
+}
+
+// capacityPluginsFromFlags returns the plugins advertising the enclave
+// memory and CPU capacity, if configured.
+func capacityPluginsFromFlags(paths kubeletPaths, access *socketAccess, tracer *spanExporter) ([]IBasicDevicePlugin, error) {
+	var plugins []IBasicDevicePlugin
+
+	if *enclaveMemoryMB > 0 || *enclaveMemoryHP {
+		if *enclaveMemoryMB > 0 && *enclaveMemoryHP {
+			return nil, fmt.Errorf("enclave-memory-mb and enclave-memory-hugepages are mutually exclusive")
+		}
+		if *enclaveMemoryUnit <= 0 {
+			return nil, fmt.Errorf("enclave-memory-unit-mb must be positive")
+		}
+		source := staticCapacity(*enclaveMemoryMB / *enclaveMemoryUnit)
+		if *enclaveMemoryHP {
+			source = hugepagesCapacity(prefixed(*hugepagesDir), *enclaveMemoryUnit)
+		}
+		mem := newCapacityPlugin(paths, *registrationMode, memoryResourceName, envEnclaveMemoryMB, *enclaveMemoryUnit, source)
+		mem.pollInterval = *capacityPoll
+		mem.access = access
+		mem.tracer = tracer
+		plugins = append(plugins, mem)
+	}
+
+	if *enclaveCPUs > 0 || *enclaveCPUPoolFile != "" {
+		if *enclaveCPUs > 0 && *enclaveCPUPoolFile != "" {
+			return nil, fmt.Errorf("enclave-cpus and enclave-cpu-pool-file are mutually exclusive")
+		}
+		source := staticCapacity(*enclaveCPUs)
+		if *enclaveCPUPoolFile != "" {
+			source = cpuPoolCapacity(prefixed(*enclaveCPUPoolFile))
+		}
+		cpu := newCapacityPlugin(paths, *registrationMode, cpuResourceName, envEnclaveCPUs, 1, source)
+		cpu.pollInterval = *capacityPoll
+		cpu.access = access
+		cpu.tracer = tracer
+		plugins = append(plugins, cpu)
+	}
+
+	if *sgxEPC {
+		epc := newCapacityPlugin(paths, *registrationMode, sgxEPCResourceName, envSGXEPCMB, 1, sgxEPCCapacity(prefixed(*nodeSysfsDir)))
+		epc.pollInterval = *capacityPoll
+		epc.access = access
+		epc.tracer = tracer
+		plugins = append(plugins, epc)
+	}
+
+	return plugins, nil
+}
+
+// attestationFromFlags returns the provider of the attestation evidence,
+// nil if disabled.
+func attestationFromFlags(helper *privHelper) (*attestation, error) {
+	var provider attestationProvider
+	switch *attestationKind {
+	case "":
+		return nil, nil
+	case attestationCommand:
+		if *attestationCmd == "" {
+			return nil, fmt.Errorf("attestation-command is required by the command attestation provider")
+		}
+		provider = &commandAttestation{command: *attestationCmd, helper: helper, root: prefixed(*attestationDir)}
+	case attestationSim:
+		if !*simulate {
+			return nil, fmt.Errorf("the sim attestation provider needs -simulate")
+		}
+		provider = &simAttestation{}
+	default:
+		return nil, fmt.Errorf("invalid attestation provider %q, expected command or sim", *attestationKind)
+	}
+	if *attestationRetries < 0 {
+		return nil, fmt.Errorf("attestation-retries must not be negative")
+	}
+
+	a, err := newAttestation(provider, prefixed(*attestationDir), *attestationPath, *attestationPolicy)
+	if err != nil {
+		return nil, err
+	}
+	a.retries = *attestationRetries
+	a.timeout = *attestationTimeout
+	return a, nil
+}
+
+// keyBrokerFromFlags returns the key broker releasing secrets to the
+// enclaves attested by attest, nil if disabled.
+func keyBrokerFromFlags(attest *attestation, helper *privHelper) (*keyBroker, error) {
+	if *keyBrokerURL == "" {
+		return nil, nil
+	}
+	if attest == nil {
+		return nil, fmt.Errorf("key-broker-url needs an attestation provider")
+	}
+	if !*releaseDetection {
+		// Secrets are removed when their device is released.
+		return nil, fmt.Errorf("key-broker-url needs -release-detection")
+	}
+	dir := prefixed(*secretDir)
+	if err := checkTmpfs(dir); err != nil {
+		return nil, err
+	}
+
+	broker, err := newKeyBroker(*keyBrokerURL, *keyBrokerCAFile, dir, *secretPath, *keyBrokerTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if *measurementList != "" {
+		if broker.allowlist, err = readMeasurementAllowlist(*measurementList); err != nil {
+			return nil, err
+		}
+	}
+	if *secretOwner != "" {
+		if broker.owner, err = parseSecretOwner(*secretOwner); err != nil {
+			return nil, err
+		}
+	}
+	broker.helper = helper
+	return broker, nil
+}
+
+// cpuPoolFiles returns the cpulist files of the enclave CPU pools.
+func cpuPoolFiles(enclaveProviders []EnclaveProvider) []string {
+	var files []string
+	if *enclaveCPUPoolFile != "" {
+		files = append(files, prefixed(*enclaveCPUPoolFile))
+	}
+	for _, provider := range enclaveProviders {
+		if provider.name() == providerNitro {
+			files = append(files, prefixed(*nitroCPUPoolFile))
+		}
+	}
+	return files
+}
+
+// hookCommandsFromFlags returns the hook command of every event.
+func hookCommandsFromFlags() map[string]string {
+	return map[string]string{
+		hookDiscovery:    *hookDiscoveryCmd,
+		hookAllocate:     *hookAllocateCmd,
+		hookPreStart:     *hookPreStartCmd,
+		hookRelease:      *hookReleaseCmd,
+		hookHealthChange: *hookHealthCmd,
+	}
+}
+
+// dropPrivileges runs the daemon as -run-as-user and stays as its
+// privileged helper.
+func dropPrivileges() int {
+	cred, err := lookupCredential(*runAsUser, *runAsGroup)
+	if err != nil {
+		glog.Error(err)
+		return 1
+	}
+	caps, err := parseCapabilities(*keepCapabilities)
+	if err != nil {
+		glog.Error(err)
+		return 1
+	}
+	paths := pathsFromFlags()
+
+	commands := map[string]helperCommand{
+		helperReleaseCleanup: {command: *releaseCleanupCmd, timeout: *releaseCleanupTime},
+	}
+	for event, command := range hookCommandsFromFlags() {
+		commands[helperHookPrefix+event] = helperCommand{command: command, timeout: *hookTimeout}
+	}
+	devicePaths, err := helperDevicePaths()
+	if err != nil {
+		glog.Error(err)
+		return 1
+	}
+	config := &helperConfig{
+		commands:    commands,
+		devicePaths: devicePaths,
+		socketDirs:  []string{paths.DevicePluginDir, paths.PluginsRegistryDir},
+		dialSockets: []string{paths.KubeletSocket, paths.PodResourcesSocket},
+		cred:        cred,
+	}
+	if *handoverMode {
+		config.handoverSocket = filepath.Join(paths.DevicePluginDir, handoverSocketName)
+		config.handoverState = filepath.Join(paths.DevicePluginDir, handoverStateName)
+	}
+	if *keyBrokerURL != "" && *secretOwner != "" {
+		if config.secretOwner, err = parseSecretOwner(*secretOwner); err != nil {
+			glog.Error(err)
+			return 1
+		}
+		if config.secretDir, err = filepath.EvalSymlinks(prefixed(*secretDir)); err != nil {
+			glog.Error(err)
+			return 1
+		}
+	}
+	if *attestationKind == attestationCommand {
+		commands[helperAttestation] = helperCommand{command: *attestationCmd, timeout: *attestationTimeout}
+		if config.attestationDir, err = filepath.EvalSymlinks(prefixed(*attestationDir)); err != nil {
+			glog.Error(err)
+			return 1
+		}
+	}
+
+	// The daemon creates the directories of the containers below these.
+	var dirs []string
+	if *attestationKind != "" {
+		dirs = append(dirs, prefixed(*attestationDir))
+	}
+	if *keyBrokerURL != "" {
+		dirs = append(dirs, prefixed(*secretDir))
+	}
+	for _, dir := range dirs {
+		if err := os.Chown(dir, int(cred.Uid), int(cred.Gid)); err != nil {
+			glog.Error(err)
+			return 1
+		}
+	}
+
+	return runPrivileged(cred, caps, config)
+}
+
+// helperDevicePaths returns the host path of the devices per resource, for
+// the helper to build the command arguments itself.
+func helperDevicePaths() (map[string]func(string) string, error) {
+	names, err := parseProviders(*providers)
+	if err != nil {
+		return nil, err
+	}
+	devicePaths := map[string]func(string) string{}
+	for _, name := range names {
+		switch name {
+		case providerQingTian:
+			dir := prefixed(defaultDevRoot)
+			if *simulate {
+				// The daemon would make a temporary directory unknown here.
+				if *simDir == "" {
+					return nil, fmt.Errorf("-simulate with -run-as-user needs -sim-dir")
+				}
+				dir = prefixed(*simDir)
+			}
+			devicePaths[resourceName] = func(id string) string { return filepath.Join(dir, id) }
+		case providerSGX:
+			sgx := newSGXProvider(prefixed(defaultDevRoot), *sgxSlots, *sgxProvision)
+			devicePaths[sgx.resourceName()] = sgx.hostPath
+		case providerNitro:
+			nitro := newNitroProvider(prefixed(defaultDevRoot), *nitroSlots)
+			devicePaths[nitro.resourceName()] = nitro.hostPath
+		}
+	}
+	return devicePaths, nil
+}
+
 func main() {
 	flag.Parse()
+
+	if flag.Arg(0) == "verify" {
+		os.Exit(runVerify(*auditKeyFile, flag.Args()[1:]))
+	}
+
 	glog.V(0).Info("Loading K8s Qt Enclaves device plugin...")
 
-	devicePlugin := NewQtEnclavesDevicePlugin()
+	if err := validateRegistrationMode(*registrationMode); err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+	if err := validateDoubleBookingCheck(*doubleBooking); err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+	if err := validateRemediationPolicy(*remediationPolicy); err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+	if *handoverMode && *registrationMode != registrationModeDevicePlugin {
+		glog.Error("handover needs the device-plugin registration mode")
+		os.Exit(1)
+	}
+
+	pathMapper, err := newContainerPathMapper(*containerPathMode, *containerPathTmpl)
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+
+	perms, err := parseDevicePermissions(*devicePerms)
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
 
-	monitor := NewQtEnclavesPluginMonitor(devicePlugin)
+	socketMode, err := parseSocketMode(*pluginSocketMode)
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+	uids, err := parseUIDs(*allowedUIDs)
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+	if uids == nil {
+		glog.Warning("No allowed UIDs configured, any local process can call the plugin sockets")
+	}
+	access := newSocketAccess(socketMode, uids)
+
+	paths := pathsFromFlags()
+	if *rootPrefix != "" {
+		// Nothing else creates the kubelet directories below the prefix.
+		for _, dir := range []string{paths.DevicePluginDir, paths.PluginsRegistryDir} {
+			if err := os.MkdirAll(dir, 0750); err != nil {
+				glog.Error(err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// The evidence and secret directories of the containers are created
+	// below them after dropping privileges.
+	if *attestationKind != "" {
+		if err := os.MkdirAll(prefixed(*attestationDir), 0755); err != nil {
+			glog.Error(err)
+			os.Exit(1)
+		}
+	}
+	if *keyBrokerURL != "" {
+		if err := os.MkdirAll(prefixed(*secretDir), 0700); err != nil {
+			glog.Error(err)
+			os.Exit(1)
+		}
+		if err := os.Chmod(prefixed(*secretDir), 0700); err != nil {
+			glog.Error(err)
+			os.Exit(1)
+		}
+	}
+
+	helper, err := privHelperFromEnv()
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+	if *runAsUser != "" && helper == nil {
+		os.Exit(dropPrivileges())
+	}
+	access.helper = helper
+
+	enclaveProviders, err := providersFromFlags()
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+
+	var cids *cidPool
+	if *vsockCIDPool != "" {
+		cids, err = newCIDPool(*vsockCIDPool, prefixed(*vsockDevice))
+		if err != nil {
+			glog.Error(err)
+			os.Exit(1)
+		}
+	}
+
+	var audit *auditLog
+	if *auditLogFile != "" {
+		if *auditLogMaxFiles < 1 {
+			glog.Error("audit-log-max-files must be at least 1")
+			os.Exit(1)
+		}
+		key, err := readAuditKey(*auditKeyFile, *auditLogFile)
+		if err != nil {
+			glog.Error(err)
+			os.Exit(1)
+		}
+		audit, err = newAuditLog(*auditLogFile, key, *auditLogMaxSize, *auditLogMaxFiles)
+		if err != nil {
+			glog.Error(err)
+			os.Exit(1)
+		}
+		defer audit.Close()
+	}
+
+	if *metricsAddress != "" {
+		go serveMetrics(*metricsAddress)
+	}
+	var tracer *spanExporter
+	if *otlpEndpoint != "" {
+		tracer, err = newSpanExporter(*otlpEndpoint, *otlpServiceName, defaultSpanFlushInterval)
+		if err != nil {
+			glog.Error(err)
+			os.Exit(1)
+		}
+		defer tracer.Close()
+	}
+
+	notifier := newSDNotifier()
+	defer notifier.close()
+
+	overlapReport, err := parseCPUOverlapReport(*cpuOverlapReport)
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+	if overlapReport[cpuOverlapCondition] && *nodeName == "" {
+		glog.Error("node-condition CPU overlap report needs -node-name")
+		os.Exit(1)
+	}
+
+	var kube *kubeClient
+	if *remediationPolicy != remediationNone || overlapReport[cpuOverlapCondition] {
+		kube, err = newKubeClient(*kubeAPIServer, *kubeTokenFile, *kubeCAFile)
+		if err != nil {
+			glog.Error(err)
+			os.Exit(1)
+		}
+	}
+	var remediationLimiter *rateLimiter
+	if *remediationPolicy != remediationNone {
+		remediationLimiter, err = newRateLimiter(*remediationRate, *remediationBurst)
+		if err != nil {
+			glog.Error(err)
+			os.Exit(1)
+		}
+	}
+
+	attest, err := attestationFromFlags(helper)
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+
+	broker, err := keyBrokerFromFlags(attest, helper)
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+
+	var overlap *cpuOverlapChecker
+	if pools := cpuPoolFiles(enclaveProviders); len(pools) > 0 && len(overlapReport) > 0 {
+		overlap = newCPUOverlapChecker(paths.CPUManagerState, pools, overlapReport)
+		overlap.node = *nodeName
+		overlap.api = kube
+		stopOverlap := make(chan struct{})
+		defer close(stopOverlap)
+		go overlap.run(*capacityPoll, stopOverlap)
+	}
+
+	var plugins pluginGroup
+	var devicePlugins []*QtEnclavesDevicePlugin
+	for _, provider := range enclaveProviders {
+		devicePlugin := newQtEnclavesDevicePlugin(paths, *registrationMode, provider)
+		devicePlugin.cordonFile = *cordonFile
+		devicePlugin.doubleBookingCheck = *doubleBooking
+		devicePlugin.pathMapper = pathMapper
+		devicePlugin.permissions = devicePermissions(perms, provider.resourceName())
+		devicePlugin.releaseDetection = *releaseDetection
+		devicePlugin.releasePollInterval = *releasePoll
+		devicePlugin.releaseCleanupCommand = *releaseCleanupCmd
+		devicePlugin.releaseCleanupTimeout = *releaseCleanupTime
+		devicePlugin.hooks = newHookRunner(provider.resourceName(), hookCommandsFromFlags(), *hookTimeout)
+		devicePlugin.hooks.helper = helper
+		devicePlugin.helper = helper
+		devicePlugin.audit = audit
+		devicePlugin.access = access
+		devicePlugin.tracer = tracer
+		devicePlugin.notifier = notifier
+		if remediationLimiter != nil {
+			devicePlugin.remediation = newRemediator(*remediationPolicy, *remediationGrace, *remediationDryRun,
+				kube, remediationLimiter)
+		}
+
+		devicePlugin.attestation = attest
+		devicePlugin.keyBroker = broker
+		// SGX enclaves run on the host CPUs.
+		if overlapReport[cpuOverlapDeviceHealth] && provider.name() != providerSGX {
+			devicePlugin.cpuOverlap = overlap
+		}
+		// SGX enclaves run in the host process and have no vsock CID.
+		if cids != nil && provider.name() != providerSGX {
+			devicePlugin.cids = cids
+			if n := len(devicePlugin.deviceIDs()); cids.size() < uint64(n) {
+				glog.Warningf("vsock CID pool %s is smaller than the %d devices", *vsockCIDPool, n)
+			}
+		}
+		plugins = append(plugins, devicePlugin)
+		devicePlugins = append(devicePlugins, devicePlugin)
+	}
+
+	capacityPlugins, err := capacityPluginsFromFlags(paths, access, tracer)
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+	plugins = append(plugins, capacityPlugins...)
+
+	var sockets []string
+	if *handoverMode {
+		generation := newGeneration()
+		for _, plugin := range plugins {
+			switch p := plugin.(type) {
+			case *QtEnclavesDevicePlugin:
+				p.socket = versionedSocket(p.socket, generation)
+				sockets = append(sockets, p.socket)
+			case *capacityPlugin:
+				p.socket = versionedSocket(p.socket, generation)
+				sockets = append(sockets, p.socket)
+			}
+		}
+	}
+
+	monitor := newPluginMonitor(plugins, paths)
 	if monitor == nil {
 		glog.Error("Error while initializing Qt Enclaves device plugin monitor!")
 		os.Exit(1)
 	}
+	monitor.notifier = notifier
+	if *handoverMode {
+		monitor.handover = newHandover(paths.DevicePluginDir, *handoverTimeout, devicePlugins)
+		monitor.handover.sockets = sockets
+		monitor.handover.access = access
+	}
 
 	monitor.Run()
 }
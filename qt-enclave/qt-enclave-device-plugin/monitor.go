@@ -16,6 +16,7 @@
 package main
 
 import (
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
@@ -23,7 +24,6 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/golang/glog"
-	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
 const (
@@ -35,6 +35,13 @@ type QtEnclavesPluginMonitor struct {
 	fsWatcher    *fsnotify.Watcher
 	sigWatcher   chan os.Signal
 	restart      bool
+	paths        kubeletPaths
+	// handover is set when the daemon hands over to its successor on
+	// upgrades, nil otherwise.
+	handover *handover
+	tookOver bool
+	// notifier reports to systemd, nil when not running under systemd.
+	notifier *sdNotifier
 }
 
 func newFSWatcher(file string) (*fsnotify.Watcher, error) {
@@ -64,10 +71,14 @@ func (qtepm *QtEnclavesPluginMonitor) Init() error {
 
 	var err error
 
+	if qtepm.paths.DevicePluginDir == "" {
+		qtepm.paths = newKubeletPaths(defaultKubeletRootDir)
+	}
+
 	glog.V(0).Info("Starting FS watcher.")
-	qtepm.fsWatcher, err = newFSWatcher(pluginapi.DevicePluginPath)
+	qtepm.fsWatcher, err = newFSWatcher(qtepm.paths.DevicePluginDir)
 	if err != nil {
-		glog.Error("Failed to created FS watcher:", pluginapi.DevicePluginPath)
+		glog.Error("Failed to created FS watcher:", qtepm.paths.DevicePluginDir)
 		return err
 	}
 
@@ -83,22 +94,46 @@ func (qtepm *QtEnclavesPluginMonitor) Init() error {
 func (qtepm *QtEnclavesPluginMonitor) Run() {
 	defer qtepm.fsWatcher.Close()
 
+	var handoverRequests chan net.Conn
+	if qtepm.handover != nil {
+		handoverRequests = qtepm.handover.requests
+		defer qtepm.handover.close()
+	}
+
+	qtepm.notifier.watch("monitor", monitorStallTimeout)
+	defer qtepm.notifier.stopping("Stopping")
+	beat := time.NewTicker(monitorStallTimeout / 4)
+	defer beat.Stop()
+
 L:
 	for {
+		qtepm.notifier.beat("monitor")
 		if qtepm.restart {
 			if err := qtepm.devicePlugin.Start(); err != nil {
 				// Sleep and try again as long as the monitor is running.
 				glog.V(0).Info("Could not contact Kubelet, retrying. Did you enable the device plugin feature gate?")
+				qtepm.notifier.status("Waiting for kubelet")
 				time.Sleep(pluginStartRetryTimeout)
 				continue
 			} else {
 				qtepm.restart = false
+				qtepm.notifier.status("Registered with kubelet")
+			}
+
+			// Only withdraw the previous daemon once registered ourselves.
+			if qtepm.handover != nil && !qtepm.tookOver {
+				qtepm.tookOver = true
+				if err := qtepm.handover.takeOver(); err != nil {
+					glog.Errorf("Handover failed: %v", err)
+				}
 			}
 		}
 
 		select {
+		case <-beat.C:
+
 		case event := <-qtepm.fsWatcher.Events:
-			if event.Name == pluginapi.KubeletSocket && event.Op&fsnotify.Create == fsnotify.Create {
+			if event.Name == qtepm.paths.KubeletSocket && event.Op&fsnotify.Create == fsnotify.Create {
 				glog.V(0).Infof("Kubelet sock has been re/created. The plugin needs a restart.")
 				qtepm.devicePlugin.Stop()
 				qtepm.restart = true
@@ -109,6 +144,15 @@ L:
 			qtepm.devicePlugin.Stop()
 			break L
 
+		case conn := <-handoverRequests:
+			if err := qtepm.handover.withdraw(conn); err != nil {
+				glog.Errorf("Handover failed, keep serving: %v", err)
+				continue
+			}
+			glog.V(0).Infof("Terminating plugin monitor... (Reason: handed over)")
+			qtepm.devicePlugin.Stop()
+			break L
+
 		case sig := <-qtepm.sigWatcher:
 			switch sig {
 			case syscall.SIGHUP:
@@ -117,6 +161,9 @@ L:
 				qtepm.restart = true
 			case syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT:
 				glog.V(0).Infof("Terminating plugin monitor... (Reason: \"%v\")", sig)
+				if sig == syscall.SIGTERM {
+					qtepm.awaitSuccessor()
+				}
 				qtepm.devicePlugin.Stop()
 				break L
 			}
@@ -124,10 +171,36 @@ L:
 	}
 }
 
+// awaitSuccessor keeps serving for the handover timeout after SIGTERM, so
+// that a successor started alongside, as with a DaemonSet surge update,
+// can register before the resources are withdrawn.
+func (qtepm *QtEnclavesPluginMonitor) awaitSuccessor() {
+	if qtepm.handover == nil || qtepm.handover.listener == nil {
+		return
+	}
+
+	glog.V(0).Infof("Waiting up to %s for a successor", qtepm.handover.timeout)
+	select {
+	case conn := <-qtepm.handover.requests:
+		if err := qtepm.handover.withdraw(conn); err != nil {
+			glog.Errorf("Handover failed: %v", err)
+		}
+	case <-time.After(qtepm.handover.timeout):
+		glog.V(0).Info("No successor, withdrawing")
+	}
+}
+
 // Create a new plugin monitor.
 func NewQtEnclavesPluginMonitor(qtedp *QtEnclavesDevicePlugin) *QtEnclavesPluginMonitor {
+	return newPluginMonitor(qtedp, qtedp.paths)
+}
+
+// newPluginMonitor creates a plugin monitor restarting plugin whenever the
+// kubelet under paths restarts.
+func newPluginMonitor(plugin IBasicDevicePlugin, paths kubeletPaths) *QtEnclavesPluginMonitor {
 	qtepm := &QtEnclavesPluginMonitor{
-		devicePlugin: qtedp,
+		devicePlugin: plugin,
+		paths:        paths,
 	}
 
 	if qtepm.Init() != nil {
@@ -19,8 +19,6 @@ import (
 	"os"
 	"testing"
 	"time"
-
-	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
 type DummyDevicePlugin struct {
@@ -39,11 +37,13 @@ func (*DummyDevicePlugin) Stop() error {
 // Whenever the Kubelet socket is recreated, the plugin
 // needs a restart.
 func TestIntegrationValidatePluginNeedsARestart(t *testing.T) {
-	dp := pluginapi.DevicePluginPath
-	ksn := pluginapi.KubeletSocket
+	paths := newKubeletPaths(t.TempDir())
+	dp := paths.DevicePluginDir
+	ksn := paths.KubeletSocket
 
 	qtepm := &QtEnclavesPluginMonitor{
 		devicePlugin: &DummyDevicePlugin{startError: errors.New("Some failure")},
+		paths:        paths,
 	}
 
 	// Check k8s socket file and create a tmp one
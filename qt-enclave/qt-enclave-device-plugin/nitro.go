@@ -0,0 +1,196 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide nitro enclaves style provider
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	nitroResourceName = "huawei.com/nitro_enclaves"
+	nitroDevice       = "nitro_enclaves"
+
+	defaultNitroEnclavesPerNode = 4
+	defaultNitroCPUPoolFile     = "/sys/module/nitro_enclaves/parameters/ne_cpus"
+	defaultNitroMinCPUs         = 2
+	defaultCPUSysfsDir          = "/sys/devices/system/cpu"
+)
+
+// Reasons of a failed nitro enclaves preflight check.
+const (
+	reasonDeviceMissing         = "DeviceMissing"
+	reasonDeviceRejected        = "DeviceRejected"
+	reasonCPUPoolUnset          = "CPUPoolUnset"
+	reasonCPUPoolInvalid        = "CPUPoolInvalid"
+	reasonCPUPoolTooSmall       = "CPUPoolTooSmall"
+	reasonCPUPoolNotIsolated    = "CPUPoolNotIsolated"
+	reasonHugepagesUnreserved   = "HugepagesUnreserved"
+	reasonHugepagesInsufficient = "HugepagesInsufficient"
+)
+
+// preflightError is a failed precondition of the nitro enclaves device.
+type preflightError struct {
+	reason string
+	detail string
+}
+
+func (e *preflightError) Error() string {
+	return e.reason + ": " + e.detail
+}
+
+func preflightErrorf(reason, format string, args ...interface{}) error {
+	return &preflightError{reason: reason, detail: fmt.Sprintf(format, args...)}
+}
+
+// nitroProvider serves a /dev/nitro_enclaves style device. The device is
+// only usable with an isolated CPU pool and reserved hugepages, which the
+// health check verifies in sysfs.
+type nitroProvider struct {
+	devRoot      string
+	slots        int
+	cpuPoolFile  string
+	cpuSysfsDir  string
+	hugepagesDir string
+	minCPUs      int
+	minMemoryMB  int
+	// nodes verifies the device node, nil only checks that it exists.
+	nodes *nodeVerifier
+}
+
+func newNitroProvider(devRoot string, slots int) *nitroProvider {
+	return &nitroProvider{
+		devRoot:      devRoot,
+		slots:        slots,
+		cpuPoolFile:  defaultNitroCPUPoolFile,
+		cpuSysfsDir:  defaultCPUSysfsDir,
+		hugepagesDir: defaultHugepagesDir,
+		minCPUs:      defaultNitroMinCPUs,
+	}
+}
+
+func (p *nitroProvider) name() string {
+	return providerNitro
+}
+
+func (p *nitroProvider) resourceName() string {
+	return nitroResourceName
+}
+
+func (p *nitroProvider) socketName() string {
+	return nitroDevice + ".sock"
+}
+
+// discover advertises the slots when the device exists. Slots whose
+// preconditions fail are advertised unhealthy by the health check.
+func (p *nitroProvider) discover() ([]string, error) {
+	if err := p.nodes.verify(p.hostPath("")); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if isNodeRejected(err) {
+			glog.Errorf("Not advertising device: %v", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ids := make([]string, 0, p.slots)
+	for i := 0; i < p.slots; i++ {
+		ids = append(ids, nitroDevice+strconv.Itoa(i))
+	}
+	return ids, nil
+}
+
+// check runs the preflight checks, returning a *preflightError for the
+// first failed precondition.
+func (p *nitroProvider) check(id string) error {
+	if err := p.nodes.verify(p.hostPath(id)); err != nil {
+		if isNodeRejected(err) {
+			return preflightErrorf(reasonDeviceRejected, "%v", err)
+		}
+		return preflightErrorf(reasonDeviceMissing, "%v", err)
+	}
+	if err := p.checkCPUPool(); err != nil {
+		return err
+	}
+	return p.checkHugepages()
+}
+
+func (p *nitroProvider) checkCPUPool() error {
+	data, err := os.ReadFile(p.cpuPoolFile)
+	if err != nil {
+		return preflightErrorf(reasonCPUPoolUnset, "cannot read %s: %v", p.cpuPoolFile, err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return preflightErrorf(reasonCPUPoolUnset, "%s is empty", p.cpuPoolFile)
+	}
+	cpus, err := parseCPUList(string(data))
+	if err != nil {
+		return preflightErrorf(reasonCPUPoolInvalid, "%s: %v", p.cpuPoolFile, err)
+	}
+	if len(cpus) < p.minCPUs {
+		return preflightErrorf(reasonCPUPoolTooSmall, "%d CPUs in the pool, at least %d needed", len(cpus), p.minCPUs)
+	}
+
+	// The driver takes the pool CPUs offline for the host.
+	for _, cpu := range cpus {
+		data, err := os.ReadFile(filepath.Join(p.cpuSysfsDir, "cpu"+strconv.Itoa(cpu), "online"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return preflightErrorf(reasonCPUPoolNotIsolated, "cpu%d: %v", cpu, err)
+		}
+		if strings.TrimSpace(string(data)) != "0" {
+			return preflightErrorf(reasonCPUPoolNotIsolated, "cpu%d of the pool is online on the host", cpu)
+		}
+	}
+	return nil
+}
+
+func (p *nitroProvider) checkHugepages() error {
+	mb, err := hugepagesCapacity(p.hugepagesDir, 1)()
+	if err != nil {
+		return preflightErrorf(reasonHugepagesUnreserved, "%v", err)
+	}
+	if mb == 0 {
+		return preflightErrorf(reasonHugepagesUnreserved, "no hugepages reserved in %s", p.hugepagesDir)
+	}
+	if mb < p.minMemoryMB {
+		return preflightErrorf(reasonHugepagesInsufficient, "%d MiB of hugepages reserved, at least %d MiB needed", mb, p.minMemoryMB)
+	}
+	return nil
+}
+
+// hostPath returns the shared device for every slot.
+func (p *nitroProvider) hostPath(id string) string {
+	return filepath.Join(p.devRoot, nitroDevice)
+}
+
+func (p *nitroProvider) deviceSpecs(id, containerPath string) []*pluginapi.DeviceSpec {
+	return []*pluginapi.DeviceSpec{{ContainerPath: devicePath(nitroDevice), HostPath: p.hostPath(id)}}
+}
+
+func (p *nitroProvider) preStart(ids []string) error {
+	return nil
+}
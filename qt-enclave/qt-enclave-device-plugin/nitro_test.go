@@ -0,0 +1,100 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide nitro enclaves style provider testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestNitroProvider lays out a usable nitro enclaves node: the device,
+// CPUs 2-3 offline in the pool and 1 GiB of 2M hugepages.
+func newTestNitroProvider(t *testing.T) *nitroProvider {
+	root := t.TempDir()
+	p := newNitroProvider(filepath.Join(root, "dev"), 2)
+	p.cpuPoolFile = filepath.Join(root, "ne_cpus")
+	p.cpuSysfsDir = filepath.Join(root, "cpu")
+	p.hugepagesDir = filepath.Join(root, "hugepages")
+	p.minMemoryMB = 512
+
+	writeTestFile(t, p.hostPath(""), "")
+	writeTestFile(t, p.cpuPoolFile, "2-3\n")
+	for _, cpu := range []string{"cpu2", "cpu3"} {
+		writeTestFile(t, filepath.Join(p.cpuSysfsDir, cpu, "online"), "0\n")
+	}
+	writeHugepages(t, p.hugepagesDir, "2048kB", "512")
+	return p
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNitroPreflight(t *testing.T) {
+	tests := []struct {
+		reason string
+		breakf func(p *nitroProvider)
+	}{
+		{"", func(p *nitroProvider) {}},
+		{reasonDeviceMissing, func(p *nitroProvider) { os.Remove(p.hostPath("")) }},
+		{reasonCPUPoolUnset, func(p *nitroProvider) { writeTestFile(t, p.cpuPoolFile, "\n") }},
+		{reasonCPUPoolInvalid, func(p *nitroProvider) { writeTestFile(t, p.cpuPoolFile, "3-2\n") }},
+		{reasonCPUPoolTooSmall, func(p *nitroProvider) { writeTestFile(t, p.cpuPoolFile, "2\n") }},
+		{reasonCPUPoolNotIsolated, func(p *nitroProvider) { writeTestFile(t, filepath.Join(p.cpuSysfsDir, "cpu3", "online"), "1\n") }},
+		{reasonHugepagesUnreserved, func(p *nitroProvider) { writeHugepages(t, p.hugepagesDir, "2048kB", "0") }},
+		{reasonHugepagesInsufficient, func(p *nitroProvider) { writeHugepages(t, p.hugepagesDir, "2048kB", "128") }},
+	}
+
+	for _, tt := range tests {
+		p := newTestNitroProvider(t)
+		tt.breakf(p)
+
+		err := p.check("nitro_enclaves0")
+		var perr *preflightError
+		switch {
+		case tt.reason == "" && err != nil:
+			t.Fatalf("Usable node should pass the preflight checks: %v", err)
+		case tt.reason != "" && (!errors.As(err, &perr) || perr.reason != tt.reason):
+			t.Fatalf("Expected preflight failure %s but got %v", tt.reason, err)
+		}
+	}
+}
+
+func TestNitroDiscovery(t *testing.T) {
+	p := newTestNitroProvider(t)
+	ids, err := p.discover()
+	if err != nil || len(ids) != 2 || ids[1] != "nitro_enclaves1" {
+		t.Fatalf("Unexpected nitro enclaves slots %v: %v", ids, err)
+	}
+
+	specs := p.deviceSpecs("nitro_enclaves1", "/dev/ignored")
+	if len(specs) != 1 || specs[0].ContainerPath != "/dev/nitro_enclaves" || specs[0].HostPath != p.hostPath("") {
+		t.Fatalf("Unexpected device specs: %v", specs)
+	}
+
+	os.Remove(p.hostPath(""))
+	if ids, err := p.discover(); err != nil || len(ids) != 0 {
+		t.Fatalf("Node without the device should advertise nothing, got %v: %v", ids, err)
+	}
+}
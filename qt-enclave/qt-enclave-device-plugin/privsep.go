@@ -0,0 +1,733 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide privilege separation
+ *********************************************************************************/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// helperFDEnv tells the unprivileged daemon which inherited file
+	// descriptor talks to the privileged helper.
+	helperFDEnv = "QT_ENCLAVE_HELPER_FD"
+
+	helperReleaseCleanup = "release-cleanup"
+	helperHookPrefix     = "hook-"
+
+	// The daemon has the helper create, remove and connect to the sockets
+	// in the kubelet directories.
+	helperListen = "listen"
+	helperRemove = "remove"
+	helperDial   = "dial"
+
+	// The daemon has the helper write and remove the handover state in
+	// the device plugin directory.
+	helperWriteState  = "write-state"
+	helperRemoveState = "remove-state"
+
+	// The daemon has the helper hand the secret files to their owner.
+	helperOwnSecret = "own-secret"
+
+	helperMaxMessage  = 64 * 1024
+	helperDialTimeout = 10 * time.Second
+
+	defaultKeepCapabilities = ""
+)
+
+// helperDeviceIDPattern matches the device IDs the helper builds paths for.
+var helperDeviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// capabilityNames maps the capabilities that may be kept to their numbers.
+var capabilityNames = map[string]uintptr{
+	"CAP_CHOWN":            unix.CAP_CHOWN,
+	"CAP_DAC_OVERRIDE":     unix.CAP_DAC_OVERRIDE,
+	"CAP_DAC_READ_SEARCH":  unix.CAP_DAC_READ_SEARCH,
+	"CAP_FOWNER":           unix.CAP_FOWNER,
+	"CAP_NET_BIND_SERVICE": unix.CAP_NET_BIND_SERVICE,
+	"CAP_SYS_RESOURCE":     unix.CAP_SYS_RESOURCE,
+}
+
+// parseCapabilities parses a comma separated list of capability names.
+func parseCapabilities(list string) ([]uintptr, error) {
+	var caps []uintptr
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !strings.HasPrefix(name, "CAP_") {
+			name = "CAP_" + name
+		}
+		c, ok := capabilityNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported capability %s", name)
+		}
+		caps = append(caps, c)
+	}
+	return caps, nil
+}
+
+// lookupCredential resolves the user and group, by name or number, the
+// daemon runs as. Without a group the primary group of the user is used.
+func lookupCredential(userName, groupName string) (*syscall.Credential, error) {
+	u, err := user.Lookup(userName)
+	if err != nil {
+		if u, err = user.LookupId(userName); err != nil {
+			return nil, fmt.Errorf("unknown user %s", userName)
+		}
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			if g, err = user.LookupGroupId(groupName); err != nil {
+				return nil, fmt.Errorf("unknown group %s", groupName)
+			}
+		}
+		if gid, err = strconv.ParseUint(g.Gid, 10, 32); err != nil {
+			return nil, err
+		}
+	}
+	if uid == 0 {
+		return nil, fmt.Errorf("user %s is root, nothing to drop", userName)
+	}
+
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}, nil
+}
+
+// helperCommand is a command the privileged helper runs on request.
+type helperCommand struct {
+	command string
+	timeout time.Duration
+}
+
+// helperConfig is what the privileged helper does for the daemon, all taken
+// from its own command line.
+type helperConfig struct {
+	commands map[string]helperCommand
+	// devicePaths returns the host path of a device, per resource.
+	devicePaths map[string]func(id string) string
+	// attestationDir holds the output directories of the attestation
+	// command.
+	attestationDir string
+	// socketDirs are the directories the daemon serves its sockets in.
+	socketDirs []string
+	// dialSockets are the kubelet sockets the daemon connects to.
+	dialSockets []string
+	// handoverSocket and handoverState are the handover socket and state
+	// file, empty without handover.
+	handoverSocket string
+	handoverState  string
+	// secretDir holds the secret files handed to secretOwner, both empty
+	// when the secrets stay with the daemon user.
+	secretDir   string
+	secretOwner *syscall.Credential
+	// cred owns the sockets created for the daemon.
+	cred *syscall.Credential
+}
+
+// helperRequest names what the daemon wants done. The helper builds the
+// command arguments itself from Resource, Device and Dir.
+type helperRequest struct {
+	ID       uint64      `json:"id"`
+	Name     string      `json:"name"`
+	Resource string      `json:"resource,omitempty"`
+	Device   string      `json:"device,omitempty"`
+	Dir      string      `json:"dir,omitempty"`
+	Socket   string      `json:"socket,omitempty"`
+	Mode     os.FileMode `json:"mode,omitempty"`
+	Stdin    []byte      `json:"stdin,omitempty"`
+	Data     []byte      `json:"data,omitempty"`
+}
+
+type helperResponse struct {
+	ID    uint64 `json:"id"`
+	Error string `json:"error,omitempty"`
+	// file is the socket passed along with the response.
+	file *os.File
+}
+
+// runPrivileged re-executes the daemon as the unprivileged user, keeping
+// only caps, and serves its helper requests until it exits. It returns the
+// exit code of the daemon.
+func runPrivileged(cred *syscall.Credential, caps []uintptr, config *helperConfig) int {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		glog.Errorf("Failed to create helper socket: %v", err)
+		return 1
+	}
+	helperEnd := os.NewFile(uintptr(fds[0]), "helper")
+	daemonEnd := os.NewFile(uintptr(fds[1]), "helper-daemon")
+
+	exe, err := os.Executable()
+	if err != nil {
+		glog.Errorf("Failed to find the executable: %v", err)
+		return 1
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{daemonEnd}
+	cmd.Env = append(os.Environ(), helperFDEnv+"=3")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential:  cred,
+		AmbientCaps: caps,
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	if err := cmd.Start(); err != nil {
+		glog.Errorf("Failed to start the unprivileged daemon: %v", err)
+		return 1
+	}
+	daemonEnd.Close()
+	glog.V(0).Infof("Started unprivileged daemon (pid %d, uid %d, gid %d)", cmd.Process.Pid, cred.Uid, cred.Gid)
+
+	conn, err := net.FileConn(helperEnd)
+	helperEnd.Close()
+	if err != nil {
+		glog.Errorf("Failed to open helper socket: %v", err)
+		cmd.Process.Kill()
+		return 1
+	}
+	go serveHelper(conn.(*net.UnixConn), config)
+
+	sigs := newOSWatcher(syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer signal.Stop(sigs)
+	go func() {
+		for sig := range sigs {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		glog.Errorf("Unprivileged daemon failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// serveHelper serves the requests of the daemon. Only commands configured
+// at startup can run, with arguments built by the helper.
+func serveHelper(conn *net.UnixConn, config *helperConfig) {
+	var writeMu sync.Mutex
+	buf := make([]byte, helperMaxMessage)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			glog.V(0).Infof("Helper socket closed: %v", err)
+			return
+		}
+		var req helperRequest
+		if err := json.Unmarshal(buf[:n], &req); err != nil {
+			glog.Errorf("Invalid helper request: %v", err)
+			continue
+		}
+
+		go func() {
+			resp := helperResponse{ID: req.ID}
+			file, err := config.handle(req)
+			if err != nil {
+				resp.Error = err.Error()
+			}
+			var oob []byte
+			if file != nil {
+				defer file.Close()
+				oob = unix.UnixRights(int(file.Fd()))
+			}
+			data, _ := json.Marshal(resp)
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if _, _, err := conn.WriteMsgUnix(data, oob, nil); err != nil {
+				glog.Errorf("Failed to answer helper request %s: %v", req.Name, err)
+			}
+		}()
+	}
+}
+
+// handle serves one request, returning the socket of the socket requests.
+func (c *helperConfig) handle(req helperRequest) (*os.File, error) {
+	var file *os.File
+	var err error
+	switch req.Name {
+	case helperListen:
+		file, err = c.listen(req.Socket, req.Mode)
+	case helperRemove:
+		err = c.remove(req.Socket)
+	case helperDial:
+		file, err = c.dial(req.Socket)
+	case helperWriteState:
+		err = c.writeState(req.Data)
+	case helperRemoveState:
+		err = c.removeState()
+	case helperOwnSecret:
+		err = c.ownSecret(req.Dir)
+	default:
+		err = c.runCommand(req)
+	}
+	return file, err
+}
+
+// runCommand runs a configured command. The release cleanup and the
+// attestation get the host path of the device, and the attestation its
+// output directory, as arguments; the hooks only get their payload.
+func (c *helperConfig) runCommand(req helperRequest) error {
+	command, ok := c.commands[req.Name]
+	if !ok || command.command == "" {
+		glog.Errorf("Rejected helper request for unknown command %q", req.Name)
+		return fmt.Errorf("command %q is not configured", req.Name)
+	}
+
+	var args, env []string
+	if req.Name == helperReleaseCleanup || req.Name == helperAttestation {
+		path, err := c.devicePath(req.Resource, req.Device)
+		if err != nil {
+			glog.Errorf("Rejected helper request %s: %v", req.Name, err)
+			return err
+		}
+		args = []string{path}
+		env = []string{"QT_ENCLAVE_DEVICE_ID=" + req.Device}
+	}
+	if req.Name == helperAttestation {
+		dir, err := c.attestationOutput(req.Dir)
+		if err != nil {
+			glog.Errorf("Rejected helper request %s: %v", req.Name, err)
+			return err
+		}
+		args = append(args, dir)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), command.timeout)
+	defer cancel()
+	return runCommand(ctx, command.command, args, env, bytes.NewReader(req.Stdin))
+}
+
+// devicePath returns the host path of a device of resource.
+func (c *helperConfig) devicePath(resource, id string) (string, error) {
+	hostPath, ok := c.devicePaths[resource]
+	if !ok {
+		return "", fmt.Errorf("unknown resource %q", resource)
+	}
+	if !helperDeviceIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid device ID %q", id)
+	}
+	return hostPath(id), nil
+}
+
+// relativePath tells whether path is relative and stays below its base.
+func relativePath(path string) bool {
+	return path != "" && !filepath.IsAbs(path) && filepath.Clean(path) == path &&
+		path != ".." && !strings.HasPrefix(path, "../")
+}
+
+// attestationOutput returns the output directory dir, relative to the
+// attestation directory, refusing anything leading out of it.
+func (c *helperConfig) attestationOutput(dir string) (string, error) {
+	if c.attestationDir == "" || !relativePath(dir) {
+		return "", fmt.Errorf("invalid attestation output directory %q", dir)
+	}
+	path := filepath.Join(c.attestationDir, dir)
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved != path {
+		return "", fmt.Errorf("attestation output directory %q goes through a symlink", dir)
+	}
+	return path, nil
+}
+
+// ownSecret hands the secret file at rel, relative to the secret directory,
+// to the secret owner. The file is checked once opened, so that nothing
+// but a regular file with a single link below the secret directory is
+// handed over.
+func (c *helperConfig) ownSecret(rel string) error {
+	if c.secretDir == "" || c.secretOwner == nil || !relativePath(rel) {
+		glog.Errorf("Rejected helper request to own secret %q", rel)
+		return fmt.Errorf("invalid secret file %q", rel)
+	}
+	f, err := os.OpenFile(filepath.Join(c.secretDir, rel), os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	resolved, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", f.Fd()))
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !strings.HasPrefix(resolved, c.secretDir+"/") || !info.Mode().IsRegular() || !ok || st.Nlink != 1 {
+		glog.Errorf("Rejected helper request to own secret %q, resolved to %s", rel, resolved)
+		return fmt.Errorf("%q is not a secret file", rel)
+	}
+	return f.Chown(int(c.secretOwner.Uid), int(c.secretOwner.Gid))
+}
+
+// checkSocket refuses the sockets outside of the socket directories, but
+// the handover socket.
+func (c *helperConfig) checkSocket(path string) error {
+	if c.handoverSocket != "" && path == c.handoverSocket {
+		return nil
+	}
+	if filepath.Clean(path) == path && strings.HasSuffix(path, ".sock") {
+		for _, dir := range c.socketDirs {
+			if filepath.Dir(path) == dir {
+				return nil
+			}
+		}
+	}
+	glog.Errorf("Rejected helper request for socket %q", path)
+	return fmt.Errorf("socket %q is not allowed", path)
+}
+
+// remove removes a stale socket, and nothing but a socket.
+func (c *helperConfig) remove(path string) error {
+	if err := c.checkSocket(path); err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
+// listen creates the socket with mode, owned by the daemon, and returns its
+// listening file.
+func (c *helperConfig) listen(path string, mode os.FileMode) (*os.File, error) {
+	if err := c.remove(path); err != nil {
+		return nil, err
+	}
+	lis, err := bindUnix(path, mode, c.cred)
+	if err != nil {
+		return nil, err
+	}
+	// The daemon serves the socket from now on.
+	defer lis.Close()
+	return lis.File()
+}
+
+// writeState replaces the handover state file with data, owned by the
+// daemon so that its successor can read it.
+func (c *helperConfig) writeState(data []byte) error {
+	if c.handoverState == "" {
+		glog.Error("Rejected helper request to write the handover state")
+		return fmt.Errorf("handover is not configured")
+	}
+	tmp := c.handoverState + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil && c.cred != nil {
+		err = f.Chown(int(c.cred.Uid), int(c.cred.Gid))
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, c.handoverState)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// removeState removes the handover state file once restored.
+func (c *helperConfig) removeState() error {
+	if c.handoverState == "" {
+		glog.Error("Rejected helper request to remove the handover state")
+		return fmt.Errorf("handover is not configured")
+	}
+	if err := os.Remove(c.handoverState); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// dial connects to a kubelet socket and returns the connection file.
+func (c *helperConfig) dial(path string) (*os.File, error) {
+	allowed := c.handoverSocket != "" && path == c.handoverSocket
+	for _, socket := range c.dialSockets {
+		allowed = allowed || socket == path
+	}
+	if !allowed {
+		glog.Errorf("Rejected helper request to connect to %q", path)
+		return nil, fmt.Errorf("socket %q is not allowed", path)
+	}
+	conn, err := net.DialTimeout("unix", path, helperDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.(*net.UnixConn).File()
+}
+
+// privHelper is the unprivileged side of the helper socket.
+type privHelper struct {
+	conn *net.UnixConn
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan helperResponse
+	err     error
+}
+
+func newPrivHelper(conn *net.UnixConn) *privHelper {
+	h := &privHelper{conn: conn, pending: map[uint64]chan helperResponse{}}
+	go h.readResponses()
+	return h
+}
+
+// privHelperFromEnv connects to the helper socket inherited from the
+// privileged process, if any.
+func privHelperFromEnv() (*privHelper, error) {
+	value := os.Getenv(helperFDEnv)
+	if value == "" {
+		return nil, nil
+	}
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %q", helperFDEnv, value)
+	}
+	f := os.NewFile(uintptr(fd), "helper")
+	defer f.Close()
+	conn, err := net.FileConn(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open helper socket: %v", err)
+	}
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("helper socket is not a unix socket")
+	}
+	return newPrivHelper(uc), nil
+}
+
+func (h *privHelper) readResponses() {
+	buf := make([]byte, helperMaxMessage)
+	oob := make([]byte, unix.CmsgSpace(4))
+	for {
+		n, oobn, _, _, err := h.conn.ReadMsgUnix(buf, oob)
+		if err != nil {
+			h.mu.Lock()
+			h.err = fmt.Errorf("privileged helper is gone: %v", err)
+			for id, ch := range h.pending {
+				ch <- helperResponse{ID: id, Error: h.err.Error()}
+				delete(h.pending, id)
+			}
+			h.mu.Unlock()
+			return
+		}
+		file, err := receivedFile(oob[:oobn])
+		if err != nil {
+			glog.Errorf("Invalid helper response: %v", err)
+			continue
+		}
+		var resp helperResponse
+		if err := json.Unmarshal(buf[:n], &resp); err != nil {
+			glog.Errorf("Invalid helper response: %v", err)
+			if file != nil {
+				file.Close()
+			}
+			continue
+		}
+		resp.file = file
+		h.mu.Lock()
+		if ch, ok := h.pending[resp.ID]; ok {
+			ch <- resp
+			delete(h.pending, resp.ID)
+		} else if file != nil {
+			file.Close()
+		}
+		h.mu.Unlock()
+	}
+}
+
+// receivedFile returns the file descriptor passed along with a message, if
+// any.
+func receivedFile(oob []byte) (*os.File, error) {
+	if len(oob) == 0 {
+		return nil, nil
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+	var fds []int
+	for i := range msgs {
+		rights, err := unix.ParseUnixRights(&msgs[i])
+		if err != nil {
+			return nil, err
+		}
+		fds = append(fds, rights...)
+	}
+	if len(fds) == 0 {
+		return nil, nil
+	}
+	for _, fd := range fds[1:] {
+		unix.Close(fd)
+	}
+	return os.NewFile(uintptr(fds[0]), "helper-socket"), nil
+}
+
+// run asks the helper to run a configured command.
+func (h *privHelper) run(ctx context.Context, req helperRequest) error {
+	file, err := h.call(ctx, req)
+	if file != nil {
+		file.Close()
+	}
+	return err
+}
+
+// listen has the helper create the socket at path with mode.
+func (h *privHelper) listen(path string, mode os.FileMode) (net.Listener, error) {
+	file, err := h.call(context.Background(), helperRequest{Name: helperListen, Socket: path, Mode: mode})
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("helper passed no socket for %s", path)
+	}
+	defer file.Close()
+	return net.FileListener(file)
+}
+
+// remove has the helper remove a stale socket.
+func (h *privHelper) remove(path string) error {
+	return h.run(context.Background(), helperRequest{Name: helperRemove, Socket: path})
+}
+
+// writeState has the helper replace the handover state file with data.
+func (h *privHelper) writeState(data []byte) error {
+	return h.run(context.Background(), helperRequest{Name: helperWriteState, Data: data})
+}
+
+// removeState has the helper remove the handover state file.
+func (h *privHelper) removeState() error {
+	return h.run(context.Background(), helperRequest{Name: helperRemoveState})
+}
+
+// dial has the helper connect to a kubelet socket.
+func (h *privHelper) dial(ctx context.Context, path string) (net.Conn, error) {
+	file, err := h.call(ctx, helperRequest{Name: helperDial, Socket: path})
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("helper passed no connection to %s", path)
+	}
+	defer file.Close()
+	return net.FileConn(file)
+}
+
+// call sends a request and returns the socket passed along with its
+// response.
+func (h *privHelper) call(ctx context.Context, req helperRequest) (*os.File, error) {
+	h.mu.Lock()
+	if h.err != nil {
+		h.mu.Unlock()
+		return nil, h.err
+	}
+	h.nextID++
+	req.ID = h.nextID
+	ch := make(chan helperResponse, 1)
+	h.pending[req.ID] = ch
+	h.mu.Unlock()
+
+	data, err := json.Marshal(req)
+	if err == nil && len(data) > helperMaxMessage {
+		err = fmt.Errorf("helper request %s too large", req.Name)
+	}
+	if err == nil {
+		_, err = h.conn.Write(data)
+	}
+	if err != nil {
+		h.mu.Lock()
+		delete(h.pending, req.ID)
+		h.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != "" {
+			if resp.file != nil {
+				resp.file.Close()
+			}
+			return nil, errors.New(resp.Error)
+		}
+		return resp.file, nil
+	case <-ctx.Done():
+		h.mu.Lock()
+		delete(h.pending, req.ID)
+		h.mu.Unlock()
+		// The response may have arrived meanwhile.
+		select {
+		case resp := <-ch:
+			if resp.file != nil {
+				resp.file.Close()
+			}
+		default:
+		}
+		return nil, ctx.Err()
+	}
+}
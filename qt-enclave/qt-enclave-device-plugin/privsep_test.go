@@ -0,0 +1,319 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide privilege separation testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/sys/unix"
+)
+
+// startTestHelper serves config on one end of a socket pair and returns a
+// client on the other end.
+func startTestHelper(t *testing.T, config *helperConfig) *privHelper {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "helper")
+		conn, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[i] = conn.(*net.UnixConn)
+		t.Cleanup(func() { conn.Close() })
+	}
+
+	go serveHelper(conns[0], config)
+	return newPrivHelper(conns[1])
+}
+
+func TestPrivHelperRunsConfiguredCommands(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	cleanup := writeHookScript(t, `echo "$1 $QT_ENCLAVE_DEVICE_ID" > `+out)
+	hook := writeHookScript(t, "cat >> "+out)
+	helper := startTestHelper(t, &helperConfig{
+		commands: map[string]helperCommand{
+			helperReleaseCleanup:           {command: cleanup, timeout: time.Second},
+			helperHookPrefix + hookRelease: {command: hook, timeout: time.Second},
+		},
+		devicePaths: map[string]func(string) string{resourceName: devicePath},
+	})
+
+	err := helper.run(context.Background(), helperRequest{Name: helperReleaseCleanup, Resource: resourceName, Device: "qtbox_service0"})
+	if err != nil {
+		t.Fatalf("Cleanup through the helper failed: %v", err)
+	}
+
+	hooks := newHookRunner(resourceName, map[string]string{hookRelease: "unused"}, time.Second)
+	hooks.helper = helper
+	if err := hooks.run(hookRelease, []string{"qtbox_service0"}, nil, ""); err != nil {
+		t.Fatalf("Hook through the helper failed: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "/dev/qtbox_service0 qtbox_service0\n") ||
+		!strings.Contains(string(data), `"event":"release"`) {
+		t.Fatalf("Unexpected helper output %q", data)
+	}
+}
+
+func TestPrivHelperRejectsRequests(t *testing.T) {
+	attestationDir := t.TempDir()
+	if err := os.Symlink("/etc", filepath.Join(attestationDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	helper := startTestHelper(t, &helperConfig{
+		commands: map[string]helperCommand{
+			helperReleaseCleanup: {command: writeHookScript(t, "exit 3"), timeout: time.Second},
+			helperAttestation:    {command: writeHookScript(t, "exit 0"), timeout: time.Second},
+		},
+		devicePaths:    map[string]func(string) string{resourceName: devicePath},
+		attestationDir: attestationDir,
+	})
+
+	tests := []struct {
+		name string
+		req  helperRequest
+		err  string
+	}{
+		{"unknown command", helperRequest{Name: "sh -c id"}, "not configured"},
+		{"unknown resource", helperRequest{Name: helperReleaseCleanup, Resource: "example.com/other", Device: "qtbox_service0"}, "unknown resource"},
+		{"device path", helperRequest{Name: helperReleaseCleanup, Resource: resourceName, Device: "../etc/passwd"}, "invalid device ID"},
+		{"output outside", helperRequest{Name: helperAttestation, Resource: resourceName, Device: "qtbox_service0", Dir: "../x"}, "invalid attestation output"},
+		{"output symlink", helperRequest{Name: helperAttestation, Resource: resourceName, Device: "qtbox_service0", Dir: "link"}, "symlink"},
+		{"command failure", helperRequest{Name: helperReleaseCleanup, Resource: resourceName, Device: "qtbox_service0"}, "exit status 3"},
+	}
+	for _, tt := range tests {
+		if err := helper.run(context.Background(), tt.req); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Fatalf("%s: expected %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestPrivHelperSockets(t *testing.T) {
+	dir := t.TempDir()
+	kubelet := filepath.Join(t.TempDir(), "kubelet.sock")
+	kubeletLis, err := net.Listen("unix", kubelet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kubeletLis.Close()
+	helper := startTestHelper(t, &helperConfig{socketDirs: []string{dir}, dialSockets: []string{kubelet}})
+
+	socket := filepath.Join(dir, "qtbox_service.sock")
+	lis, err := helper.listen(socket, 0600)
+	if err != nil {
+		t.Fatalf("Listening through the helper failed: %v", err)
+	}
+	defer lis.Close()
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Unexpected socket %v: %v", info, err)
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("Socket made by the helper does not accept: %v", err)
+	}
+	conn.Close()
+
+	conn, err = helper.dial(context.Background(), kubelet)
+	if err != nil {
+		t.Fatalf("Connecting through the helper failed: %v", err)
+	}
+	conn.Close()
+
+	notSocket := filepath.Join(dir, "state.sock")
+	if err := os.WriteFile(notSocket, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for name, err := range map[string]error{
+		"listen outside": func() error { _, err := helper.listen(filepath.Join(t.TempDir(), "x.sock"), 0600); return err }(),
+		"listen file":    func() error { _, err := helper.listen(filepath.Join(dir, "x"), 0600); return err }(),
+		"remove file":    helper.remove(notSocket),
+		"dial other":     func() error { _, err := helper.dial(context.Background(), socket); return err }(),
+	} {
+		if err == nil {
+			t.Fatalf("%s should be rejected", name)
+		}
+	}
+	if err := helper.remove(socket); err != nil {
+		t.Fatalf("Removing the socket failed: %v", err)
+	}
+}
+
+func TestPrivHelperHandover(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, handoverSocketName)
+	state := filepath.Join(dir, handoverStateName)
+	helper := startTestHelper(t, &helperConfig{handoverSocket: socket, handoverState: state})
+
+	lis, err := helper.listen(socket, 0600)
+	if err != nil {
+		t.Fatalf("Listening on the handover socket through the helper failed: %v", err)
+	}
+	defer lis.Close()
+	conn, err := helper.dial(context.Background(), socket)
+	if err != nil {
+		t.Fatalf("Connecting to the handover socket through the helper failed: %v", err)
+	}
+	conn.Close()
+
+	if err := helper.writeState([]byte(`{"version":1}`)); err != nil {
+		t.Fatalf("Writing the handover state through the helper failed: %v", err)
+	}
+	if data, err := os.ReadFile(state); err != nil || string(data) != `{"version":1}` {
+		t.Fatalf("Unexpected handover state %q: %v", data, err)
+	}
+	if err := helper.removeState(); err != nil {
+		t.Fatalf("Removing the handover state through the helper failed: %v", err)
+	}
+	if _, err := os.Stat(state); !os.IsNotExist(err) {
+		t.Fatalf("Handover state should be removed: %v", err)
+	}
+
+	// Without handover the helper touches neither.
+	helper = startTestHelper(t, &helperConfig{socketDirs: []string{dir}})
+	for name, err := range map[string]error{
+		"listen":       func() error { _, err := helper.listen(socket, 0600); return err }(),
+		"write state":  helper.writeState([]byte(`{}`)),
+		"remove state": helper.removeState(),
+	} {
+		if err == nil {
+			t.Fatalf("%s should be rejected", name)
+		}
+	}
+}
+
+func TestPrivHelperOwnsSecrets(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("handing files to another user needs root")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := &syscall.Credential{Uid: 4242, Gid: 4243}
+	helper := startTestHelper(t, &helperConfig{secretDir: dir, secretOwner: owner})
+
+	secret := filepath.Join(dir, "qtbox_service0", "key")
+	if err := os.MkdirAll(filepath.Dir(secret), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secret, []byte("s3cret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := helper.run(context.Background(), helperRequest{Name: helperOwnSecret, Dir: "qtbox_service0/key"}); err != nil {
+		t.Fatalf("Owning the secret through the helper failed: %v", err)
+	}
+	info, err := os.Stat(secret)
+	if err != nil || info.Sys().(*syscall.Stat_t).Uid != owner.Uid || info.Sys().(*syscall.Stat_t).Gid != owner.Gid {
+		t.Fatalf("Secret should be owned by %d:%d: %v", owner.Uid, owner.Gid, err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "passwd")
+	if err := os.WriteFile(outside, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Dir(outside), filepath.Join(dir, "dirlink")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(secret, filepath.Join(dir, "hardlink")); err != nil {
+		t.Fatal(err)
+	}
+	for name, rel := range map[string]string{
+		"outside":   "../passwd",
+		"absolute":  outside,
+		"symlink":   "link",
+		"directory": "qtbox_service0",
+		"through":   "dirlink/passwd",
+		"hardlink":  "hardlink",
+	} {
+		if err := helper.run(context.Background(), helperRequest{Name: helperOwnSecret, Dir: rel}); err == nil {
+			t.Fatalf("%s should be rejected", name)
+		}
+	}
+	if info, _ := os.Stat(outside); info.Sys().(*syscall.Stat_t).Uid != 0 {
+		t.Fatal("File outside of the secret directory was handed over")
+	}
+
+	// Without secret owner the helper hands nothing over.
+	helper = startTestHelper(t, &helperConfig{})
+	if err := helper.run(context.Background(), helperRequest{Name: helperOwnSecret, Dir: "qtbox_service0/key"}); err == nil {
+		t.Fatal("Owning secrets without secret owner should be rejected")
+	}
+}
+
+func TestPrivHelperGone(t *testing.T) {
+	helper := startTestHelper(t, &helperConfig{})
+	helper.conn.CloseRead()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := helper.run(context.Background(), helperRequest{Name: helperReleaseCleanup})
+		if err != nil && strings.Contains(err.Error(), "gone") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the helper to be gone, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	caps, err := parseCapabilities("cap_dac_override, CHOWN")
+	if err != nil || len(caps) != 2 || caps[0] != unix.CAP_DAC_OVERRIDE || caps[1] != unix.CAP_CHOWN {
+		t.Fatalf("Unexpected capabilities %v: %v", caps, err)
+	}
+	if caps, err := parseCapabilities(""); err != nil || len(caps) != 0 {
+		t.Fatalf("Empty list should keep no capability, got %v: %v", caps, err)
+	}
+	if _, err := parseCapabilities("CAP_SYS_ADMIN"); err == nil {
+		t.Fatal("CAP_SYS_ADMIN should not be kept")
+	}
+}
+
+func TestLookupCredential(t *testing.T) {
+	if _, err := lookupCredential("0", ""); err == nil {
+		t.Fatal("Dropping to root should be rejected")
+	}
+	if _, err := lookupCredential("no-such-user-qt", ""); err == nil {
+		t.Fatal("Unknown user should be rejected")
+	}
+	cred, err := lookupCredential("65534", "0")
+	if err != nil {
+		t.Skipf("No nobody user: %v", err)
+	}
+	if cred.Uid != 65534 || cred.Gid != 0 || len(cred.Groups) != 0 {
+		t.Fatalf("Unexpected credential %+v", cred)
+	}
+}
@@ -0,0 +1,107 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide enclave providers
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	providerQingTian = "qingtian"
+	providerSGX      = "sgx"
+	providerNitro    = "nitro"
+)
+
+// EnclaveProvider is an enclave technology served by a device plugin
+// instance. Its backend discovers and checks the devices; the provider
+// tells how they are advertised and exposed to containers.
+type EnclaveProvider interface {
+	deviceBackend
+	// name identifies the provider in the configuration.
+	name() string
+	// resourceName is the extended resource the devices are advertised as.
+	resourceName() string
+	// socketName is the device plugin socket in the kubelet directory.
+	socketName() string
+	// deviceSpecs returns the device nodes making the device available in a
+	// container. containerPath is where the plugin configuration puts it.
+	deviceSpecs(id, containerPath string) []*pluginapi.DeviceSpec
+	// preStart runs before a container using the devices starts.
+	preStart(ids []string) error
+}
+
+// qingTianProvider serves QingTian enclaves from the host or simulated
+// device nodes.
+type qingTianProvider struct {
+	deviceBackend
+}
+
+func newQingTianProvider(backend deviceBackend) *qingTianProvider {
+	return &qingTianProvider{deviceBackend: backend}
+}
+
+func (p *qingTianProvider) name() string {
+	return providerQingTian
+}
+
+func (p *qingTianProvider) resourceName() string {
+	return resourceName
+}
+
+func (p *qingTianProvider) socketName() string {
+	return socketName
+}
+
+func (p *qingTianProvider) deviceSpecs(id, containerPath string) []*pluginapi.DeviceSpec {
+	return []*pluginapi.DeviceSpec{{ContainerPath: containerPath, HostPath: p.hostPath(id)}}
+}
+
+func (p *qingTianProvider) preStart(ids []string) error {
+	return nil
+}
+
+// registrationSocketName is the plugin watcher socket of a plugin socket.
+func registrationSocketName(socket string) string {
+	return strings.TrimSuffix(socket, ".sock") + "-reg.sock"
+}
+
+// parseProviders parses the comma separated provider names.
+func parseProviders(list string) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case providerQingTian, providerSGX, providerNitro:
+		default:
+			return nil, fmt.Errorf("unknown enclave provider: %s", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("enclave provider %s configured twice", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no enclave provider configured")
+	}
+	return names, nil
+}
@@ -0,0 +1,91 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide enclave providers testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestParseProviders(t *testing.T) {
+	names, err := parseProviders("qingtian, sgx")
+	if err != nil || len(names) != 2 || names[1] != providerSGX {
+		t.Fatalf("Unexpected providers %v: %v", names, err)
+	}
+	for _, list := range []string{"", "tdx", "sgx,sgx"} {
+		if _, err := parseProviders(list); err == nil {
+			t.Fatalf("Provider list %q should be rejected", list)
+		}
+	}
+}
+
+func TestSGXProvider(t *testing.T) {
+	devRoot := t.TempDir()
+	sgx := newSGXProvider(devRoot, 3, true)
+
+	if ids, err := sgx.discover(); err != nil || len(ids) != 0 {
+		t.Fatalf("Node without SGX should advertise nothing, got %v: %v", ids, err)
+	}
+
+	for _, node := range []string{sgxEnclaveDevice, sgxProvisionDevice} {
+		if err := os.WriteFile(filepath.Join(devRoot, node), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	qtedp := newQtEnclavesDevicePlugin(newKubeletPaths(t.TempDir()), registrationModeDevicePlugin, sgx)
+	if len(qtedp.devs) != 3 {
+		t.Fatalf("Expected 3 SGX slots but got %d", len(qtedp.devs))
+	}
+	if filepath.Base(qtedp.socket) != "sgx_enclave.sock" || filepath.Base(qtedp.regSocket) != "sgx_enclave-reg.sock" {
+		t.Fatalf("Unexpected SGX plugin sockets %s, %s", qtedp.socket, qtedp.regSocket)
+	}
+
+	// Two slots in one container share the device nodes.
+	resp, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"sgx_enclave0", "sgx_enclave1"}))
+	if err != nil {
+		t.Fatalf("Allocation failed: %v", err)
+	}
+	specs := resp.ContainerResponses[0].Devices
+	if len(specs) != 2 || specs[0].ContainerPath != "/dev/sgx_enclave" || specs[1].ContainerPath != "/dev/sgx_provision" {
+		t.Fatalf("Unexpected SGX device specs: %v", specs)
+	}
+
+	if err := os.Remove(filepath.Join(devRoot, sgxProvisionDevice)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sgx.check("sgx_enclave0"); err == nil {
+		t.Fatal("Missing provisioning device should fail the health check")
+	}
+}
+
+func TestSGXEPCCapacity(t *testing.T) {
+	dir := t.TempDir()
+	for node, size := range map[string]string{"node0": "67108864\n", "node1": "33554432\n"} {
+		if err := os.MkdirAll(filepath.Join(dir, node, "x86"), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, node, "x86", "sgx_total_bytes"), []byte(size), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if mb, err := sgxEPCCapacity(dir)(); err != nil || mb != 96 {
+		t.Fatalf("Expected 96 MiB of EPC but got %d: %v", mb, err)
+	}
+}
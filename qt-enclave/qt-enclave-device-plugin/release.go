@@ -0,0 +1,208 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide release detection and device cleanup
+ *********************************************************************************/
+
+package main
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+const (
+	defaultReleasePollInterval   = 10 * time.Second
+	defaultReleaseCleanupTimeout = 30 * time.Second
+	podResourcesTimeout          = 10 * time.Second
+	// allocationGracePeriod is how long an allocation may stay invisible in
+	// PodResources before it is treated as released. It covers pods that
+	// failed admission as well as pods that finished between two polls.
+	allocationGracePeriod = time.Minute
+)
+
+// podRef identifies the container a device was assigned to.
+type podRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Container string `json:"container"`
+}
+
+// ref returns the pod as a pointer, nil when the pod is not known.
+func (p podRef) ref() *podRef {
+	if p.Name == "" {
+		return nil
+	}
+	return &p
+}
+
+func (p podRef) String() string {
+	if p.Name == "" {
+		return "unknown pod"
+	}
+	return p.Namespace + "/" + p.Name + "/" + p.Container
+}
+
+// listDevicesInUse returns the devices of our resource that kubelet
+// reports as assigned, with the container they are assigned to.
+func listDevicesInUse(client podresourcesapi.PodResourcesListerClient, resource string) (map[string]podRef, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), podResourcesTimeout)
+	defer cancel()
+
+	resp, err := client.List(ctx, &podresourcesapi.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	inUse := map[string]podRef{}
+	for _, pod := range resp.PodResources {
+		for _, c := range pod.Containers {
+			for _, d := range c.Devices {
+				if d.ResourceName != resource {
+					continue
+				}
+				for _, id := range d.DeviceIds {
+					inUse[id] = podRef{Namespace: pod.Namespace, Name: pod.Name, Container: c.Name}
+				}
+			}
+		}
+	}
+
+	return inUse, nil
+}
+
+// watchReleases polls PodResources until the plugin stops.
+func (qtedp *QtEnclavesDevicePlugin) watchReleases() {
+	for {
+		select {
+		case <-qtedp.stop:
+			return
+		case <-time.After(qtedp.releasePollInterval):
+		}
+
+		conn, err := qtedp.access.dial(qtedp.paths.PodResourcesSocket, podResourcesTimeout)
+		if err != nil {
+			glog.Errorf("Failed to connect to pod resources socket %s: %v", qtedp.paths.PodResourcesSocket, err)
+			continue
+		}
+		qtedp.pollReleases(podresourcesapi.NewPodResourcesListerClient(conn))
+		conn.Close()
+
+		qtedp.cleanupReleased()
+	}
+}
+
+// pollReleases compares the current PodResources snapshot with the previous
+// one and with the known allocations. Devices that are gone are released:
+// they leave the allocations and stay unhealthy until cleaned up.
+func (qtedp *QtEnclavesDevicePlugin) pollReleases(client podresourcesapi.PodResourcesListerClient) {
+	inUse, err := listDevicesInUse(client, qtedp.provider.resourceName())
+	if err != nil {
+		glog.Errorf("Failed to list pod resources: %v", err)
+		return
+	}
+
+	var changed []*pluginapi.Device
+	now := time.Now()
+
+	qtedp.mu.Lock()
+	if !qtedp.releaseTracked {
+		// First snapshot after startup: whatever kubelet reports is allocated.
+		for id := range inUse {
+			qtedp.allocations[id] = now
+		}
+		qtedp.releaseTracked = true
+	}
+
+	released := map[string]podRef{}
+	for id, pod := range qtedp.inUse {
+		if _, ok := inUse[id]; !ok {
+			released[id] = pod
+		}
+	}
+	for id, allocatedAt := range qtedp.allocations {
+		if _, ok := inUse[id]; ok {
+			continue
+		}
+		if _, ok := released[id]; !ok && now.Sub(allocatedAt) > allocationGracePeriod {
+			released[id] = podRef{}
+		}
+	}
+
+	for id, pod := range released {
+		glog.V(0).Infof("Device %s released by %s", id, pod)
+		qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditRelease, Devices: []string{id}, Pod: pod.ref()}, nil)
+		delete(qtedp.allocations, id)
+		qtedp.releasing[id] = pod
+		if dev := qtedp.findDevice(id); dev != nil && dev.Health != pluginapi.Unhealthy {
+			dev.Health = pluginapi.Unhealthy
+			changed = append(changed, dev)
+		}
+	}
+	qtedp.inUse = inUse
+	qtedp.mu.Unlock()
+
+	for _, dev := range changed {
+		if !qtedp.notify(dev) {
+			return
+		}
+	}
+}
+
+// cleanupReleased runs the cleanup action of every released device. A
+// device becomes healthy again on the next health check after its cleanup
+// succeeded; failed cleanups are retried on the next poll.
+func (qtedp *QtEnclavesDevicePlugin) cleanupReleased() {
+	qtedp.mu.Lock()
+	pending := map[string]podRef{}
+	for id, pod := range qtedp.releasing {
+		pending[id] = pod
+	}
+	qtedp.mu.Unlock()
+
+	for id, pod := range pending {
+		if err := qtedp.runReleaseCleanup(id); err != nil {
+			glog.Errorf("Failed to clean up device %s released by %s: %v", id, pod, err)
+			continue
+		}
+		if err := qtedp.hooks.run(hookRelease, []string{id}, pod.ref(), ""); err != nil {
+			glog.Errorf("Failed to clean up device %s released by %s: %v", id, pod, err)
+			continue
+		}
+
+		glog.V(0).Infof("Device %s cleaned up after release", id)
+		qtedp.cids.release(id)
+		qtedp.attestation.forget(id)
+		qtedp.keyBroker.forget(id)
+		qtedp.mu.Lock()
+		delete(qtedp.releasing, id)
+		qtedp.mu.Unlock()
+	}
+}
+
+// runReleaseCleanup runs the configured cleanup command with the device
+// path as last argument.
+func (qtedp *QtEnclavesDevicePlugin) runReleaseCleanup(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), qtedp.releaseCleanupTimeout)
+	defer cancel()
+
+	args := []string{qtedp.provider.hostPath(id)}
+	env := []string{"QT_ENCLAVE_DEVICE_ID=" + id}
+	if qtedp.helper != nil && qtedp.releaseCleanupCommand != "" {
+		return qtedp.helper.run(ctx, helperRequest{Name: helperReleaseCleanup, Resource: qtedp.provider.resourceName(), Device: id})
+	}
+	return runCommand(ctx, qtedp.releaseCleanupCommand, args, env, nil)
+}
@@ -0,0 +1,139 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide release detection testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+// fakePodResources serves a settable PodResources snapshot, like kubelet does
+// on <kubelet-root-dir>/pod-resources/kubelet.sock.
+type fakePodResources struct {
+	podresourcesapi.UnimplementedPodResourcesListerServer
+	mu     sync.Mutex
+	pods   []*podresourcesapi.PodResources
+	socket string
+}
+
+func (f *fakePodResources) List(context.Context, *podresourcesapi.ListPodResourcesRequest) (*podresourcesapi.ListPodResourcesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &podresourcesapi.ListPodResourcesResponse{PodResources: f.pods}, nil
+}
+
+func (f *fakePodResources) setPods(pods map[string][]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pods = nil
+	for name, ids := range pods {
+		f.pods = append(f.pods, &podresourcesapi.PodResources{
+			Name:      name,
+			Namespace: "default",
+			Containers: []*podresourcesapi.ContainerResources{{
+				Name:    "enclave",
+				Devices: []*podresourcesapi.ContainerDevices{{ResourceName: resourceName, DeviceIds: ids}},
+			}},
+		})
+	}
+}
+
+func startFakePodResources(t *testing.T) (*fakePodResources, podresourcesapi.PodResourcesListerClient) {
+	sock := filepath.Join(t.TempDir(), "kubelet.sock")
+	lis, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", sock, err)
+	}
+
+	fake := &fakePodResources{socket: sock}
+	server := grpc.NewServer()
+	podresourcesapi.RegisterPodResourcesListerServer(server, fake)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := dial(sock, devicePluginServerReadyTimeout)
+	if err != nil {
+		t.Fatalf("Failed to dial %s: %v", sock, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return fake, podresourcesapi.NewPodResourcesListerClient(conn)
+}
+
+func TestReleaseDetection(t *testing.T) {
+	fake, client := startFakePodResources(t)
+
+	qtedp := newTestAllocationPlugin()
+	qtedp.paths.PodResourcesSocket = fake.socket
+	qtedp.stop = make(chan interface{})
+	qtedp.health = make(chan *pluginapi.Device, len(qtedp.devs))
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "cleaned")
+	script := filepath.Join(dir, "cleanup.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ntest -f "+marker+"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	qtedp.releaseCleanupCommand = script
+	qtedp.cids, _ = newCIDPool("10", filepath.Join(dir, "vsock"))
+	qtedp.cids.assign([]string{"qtbox_service0"})
+
+	// A device allocated before the plugin started is picked up from the
+	// first snapshot.
+	fake.setPods(map[string][]string{"pod-a": {"qtbox_service0"}})
+	qtedp.pollReleases(client)
+	if !qtedp.releaseTracked {
+		t.Fatal("Release tracking should be enabled after the first snapshot")
+	}
+	if _, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"})); err == nil {
+		t.Fatal("Device in use by pod-a should not be allocatable")
+	}
+
+	fake.setPods(map[string][]string{})
+	qtedp.pollReleases(client)
+	if _, ok := qtedp.releasing["qtbox_service0"]; !ok {
+		t.Fatal("Device should be released after pod-a is gone")
+	}
+	if qtedp.devs[0].Health != pluginapi.Unhealthy {
+		t.Fatal("Released device should be unhealthy until cleaned up")
+	}
+	if d := <-qtedp.health; d.ID != "qtbox_service0" {
+		t.Fatalf("Unexpected health notification for %s", d.ID)
+	}
+
+	// The cleanup command fails until the marker exists.
+	qtedp.cleanupReleased()
+	if _, ok := qtedp.releasing["qtbox_service0"]; !ok {
+		t.Fatal("Device should stay released while its cleanup fails")
+	}
+	if err := os.WriteFile(marker, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	qtedp.cleanupReleased()
+	if _, ok := qtedp.releasing["qtbox_service0"]; ok {
+		t.Fatal("Device should leave the released set after cleanup")
+	}
+	if _, _, err := qtedp.cids.assign([]string{"qtbox_service1"}); err != nil {
+		t.Fatalf("CID of the cleaned up device should be reclaimed: %v", err)
+	}
+}
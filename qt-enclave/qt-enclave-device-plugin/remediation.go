@@ -0,0 +1,286 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide remediation of the pods using failed devices
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+const (
+	remediationNone     = "none"
+	remediationAnnotate = "annotate"
+	remediationEvict    = "evict"
+	remediationDelete   = "delete"
+
+	defaultRemediationGracePeriod = 5 * time.Minute
+	defaultRemediationRate        = 1.0
+	defaultRemediationBurst       = 1
+	remediationPollInterval       = 10 * time.Second
+	remediationTimeout            = 30 * time.Second
+
+	// failedDevicesAnnotation lists the failed devices of an annotated pod.
+	failedDevicesAnnotation = "qt-enclave.huawei.com/failed-devices"
+	failedSinceAnnotation   = "qt-enclave.huawei.com/failed-since"
+)
+
+// podAPI is the part of the Kubernetes API remediation acts through.
+type podAPI interface {
+	annotatePod(ctx context.Context, namespace, name string, annotations map[string]string) error
+	evictPod(ctx context.Context, namespace, name string) error
+	deletePod(ctx context.Context, namespace, name string) error
+}
+
+func validateRemediationPolicy(policy string) error {
+	switch policy {
+	case remediationNone, remediationAnnotate, remediationEvict, remediationDelete:
+		return nil
+	}
+	return fmt.Errorf("invalid remediation policy %q, expected none, annotate, evict or delete", policy)
+}
+
+// rateLimiter is a token bucket shared by the remediators of all resources,
+// so that a node-wide failure does not empty the node at once.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter allows perMinute actions per minute, burst of them at once.
+func newRateLimiter(perMinute float64, burst int) (*rateLimiter, error) {
+	if perMinute <= 0 || burst < 1 {
+		return nil, fmt.Errorf("invalid remediation rate %v/min with burst %d", perMinute, burst)
+	}
+	return &rateLimiter{rate: perMinute / 60, burst: float64(burst), tokens: float64(burst)}, nil
+}
+
+// allow takes a token if one is available at now.
+func (l *rateLimiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	if now.After(l.last) {
+		l.last = now
+	}
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// remediator acts on the pods of devices that failed their health check for
+// longer than the grace period. Cordoned and released devices are not
+// failures: nothing is wrong with their pods.
+type remediator struct {
+	policy  string
+	grace   time.Duration
+	dryRun  bool
+	api     podAPI
+	limiter *rateLimiter
+
+	mu sync.Mutex
+	// failedSince is when each failing device failed its first check.
+	failedSince map[string]time.Time
+	// remediated holds the pods acted upon, by namespace/name, until they
+	// no longer hold a device.
+	remediated map[string]bool
+}
+
+func newRemediator(policy string, grace time.Duration, dryRun bool, api podAPI, limiter *rateLimiter) *remediator {
+	return &remediator{
+		policy:      policy,
+		grace:       grace,
+		dryRun:      dryRun,
+		api:         api,
+		limiter:     limiter,
+		failedSince: map[string]time.Time{},
+		remediated:  map[string]bool{},
+	}
+}
+
+// observe records the result of the health check of a device, nil-safe.
+func (r *remediator) observe(id string, checkErr error, now time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if checkErr == nil {
+		delete(r.failedSince, id)
+	} else if _, ok := r.failedSince[id]; !ok {
+		r.failedSince[id] = now
+	}
+}
+
+// forget drops the failures of devices that disappeared, nil-safe.
+func (r *remediator) forget(ids []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		delete(r.failedSince, id)
+	}
+}
+
+// remediationTarget is a pod holding failed devices.
+type remediationTarget struct {
+	pod         podRef
+	devices     []string
+	failedSince time.Time
+}
+
+func (t remediationTarget) key() string {
+	return t.pod.Namespace + "/" + t.pod.Name
+}
+
+// due returns the pods holding a device failed for longer than the grace
+// period and not remediated yet, sorted by name.
+func (r *remediator) due(inUse map[string]podRef, now time.Time) []remediationTarget {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	holding := map[string]bool{}
+	targets := map[string]*remediationTarget{}
+	for id, pod := range inUse {
+		t := remediationTarget{pod: pod}
+		holding[t.key()] = true
+		since, ok := r.failedSince[id]
+		if !ok || now.Sub(since) < r.grace || r.remediated[t.key()] {
+			continue
+		}
+		if existing, ok := targets[t.key()]; ok {
+			existing.devices = append(existing.devices, id)
+			if since.Before(existing.failedSince) {
+				existing.failedSince = since
+			}
+			continue
+		}
+		t.devices = []string{id}
+		t.failedSince = since
+		targets[t.key()] = &t
+	}
+	for key := range r.remediated {
+		if !holding[key] {
+			delete(r.remediated, key)
+		}
+	}
+
+	var due []remediationTarget
+	for _, t := range targets {
+		sort.Strings(t.devices)
+		due = append(due, *t)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].key() < due[j].key() })
+	return due
+}
+
+// act applies the policy to a pod.
+func (r *remediator) act(t remediationTarget) error {
+	ctx, cancel := context.WithTimeout(context.Background(), remediationTimeout)
+	defer cancel()
+
+	switch r.policy {
+	case remediationAnnotate:
+		return r.api.annotatePod(ctx, t.pod.Namespace, t.pod.Name, map[string]string{
+			failedDevicesAnnotation: strings.Join(t.devices, ","),
+			failedSinceAnnotation:   t.failedSince.UTC().Format(time.RFC3339),
+		})
+	case remediationEvict:
+		return r.api.evictPod(ctx, t.pod.Namespace, t.pod.Name)
+	case remediationDelete:
+		return r.api.deletePod(ctx, t.pod.Namespace, t.pod.Name)
+	}
+	return nil
+}
+
+// remediate acts on the due pods of inUse as far as the rate limit allows.
+// Failed actions are retried on the next call.
+func (qtedp *QtEnclavesDevicePlugin) remediate(inUse map[string]podRef, now time.Time) {
+	r := qtedp.remediation
+	for _, t := range r.due(inUse, now) {
+		if !r.limiter.allow(now) {
+			glog.V(1).Infof("Remediation of %s postponed by the rate limit", t.key())
+			return
+		}
+
+		action := r.policy
+		var err error
+		if r.dryRun {
+			action = "dry-run " + action
+			glog.V(0).Infof("Would %s pod %s using failed devices %v", r.policy, t.key(), t.devices)
+		} else {
+			err = r.act(t)
+		}
+		qtedp.audit.record(auditRecord{Resource: qtedp.provider.resourceName(), Event: auditRemediate, Action: action, Devices: t.devices, Pod: t.pod.ref()}, err)
+		if err != nil {
+			glog.Errorf("Failed to %s pod %s using failed devices %v: %v", r.policy, t.key(), t.devices, err)
+			continue
+		}
+		if !r.dryRun {
+			glog.V(0).Infof("Remediated pod %s using failed devices %v: %s", t.key(), t.devices, r.policy)
+		}
+
+		r.mu.Lock()
+		r.remediated[t.key()] = true
+		r.mu.Unlock()
+	}
+}
+
+// watchFailures polls PodResources for the pods of failed devices until the
+// plugin stops.
+func (qtedp *QtEnclavesDevicePlugin) watchFailures() {
+	for {
+		select {
+		case <-qtedp.stop:
+			return
+		case <-time.After(remediationPollInterval):
+		}
+
+		conn, err := qtedp.access.dial(qtedp.paths.PodResourcesSocket, podResourcesTimeout)
+		if err != nil {
+			glog.Errorf("Failed to connect to pod resources socket %s: %v", qtedp.paths.PodResourcesSocket, err)
+			continue
+		}
+		inUse, err := listDevicesInUse(podresourcesapi.NewPodResourcesListerClient(conn), qtedp.provider.resourceName())
+		conn.Close()
+		if err != nil {
+			glog.Errorf("Failed to list pod resources: %v", err)
+			continue
+		}
+		qtedp.remediate(inUse, time.Now())
+	}
+}
@@ -0,0 +1,286 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide remediation of the pods using failed devices testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// fakePodAPI records the actions taken instead of calling an API server.
+type fakePodAPI struct {
+	mu      sync.Mutex
+	actions []string
+	err     error
+}
+
+func (f *fakePodAPI) record(action, namespace, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.actions = append(f.actions, action+" "+namespace+"/"+name)
+	return f.err
+}
+
+func (f *fakePodAPI) annotatePod(ctx context.Context, namespace, name string, annotations map[string]string) error {
+	return f.record("annotate", namespace, name)
+}
+
+func (f *fakePodAPI) evictPod(ctx context.Context, namespace, name string) error {
+	return f.record("evict", namespace, name)
+}
+
+func (f *fakePodAPI) deletePod(ctx context.Context, namespace, name string) error {
+	return f.record("delete", namespace, name)
+}
+
+func (f *fakePodAPI) taken() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.actions...)
+}
+
+// fakeAPIServer is an API server stand-in keeping the requests it receives.
+type fakeAPIServer struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+	status   int
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("Content-Type")+" "+r.Header.Get("Authorization"))
+	s.bodies = append(s.bodies, string(body))
+	w.Header().Set("Content-Type", "application/json")
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"apiVersion": "v1", "kind": "Status", "status": "Failure",
+			"message": "Cannot evict pod", "code": s.status})
+		return
+	}
+	w.Write([]byte("{}"))
+}
+
+func newTestKubeClient(t *testing.T, api *fakeAPIServer) *kubeClient {
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	token := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(token, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := newKubeClient(srv.URL, token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestRemediation(t *testing.T) {
+	fake, client := startFakePodResources(t)
+	api := &fakeAPIServer{}
+	limiter, _ := newRateLimiter(60, 10)
+
+	qtedp := newTestAllocationPlugin()
+	qtedp.remediation = newRemediator(remediationEvict, time.Minute, false, newTestKubeClient(t, api), limiter)
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	qtedp.audit, _ = newAuditLog(auditFile, testAuditKey, defaultAuditMaxSize, 1)
+	defer qtedp.audit.Close()
+
+	fake.setPods(map[string][]string{"pod-a": {"qtbox_service0"}, "pod-b": {"qtbox_service1"}})
+	failed := time.Now()
+	qtedp.remediation.observe("qtbox_service0", errors.New("device gone"), failed)
+	qtedp.remediation.observe("qtbox_service0", errors.New("device gone"), failed.Add(30*time.Second))
+
+	inUse, err := listDevicesInUse(client, resourceName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qtedp.remediate(inUse, failed.Add(59*time.Second))
+	if len(api.requests) != 0 {
+		t.Fatalf("Pod should not be remediated within the grace period: %v", api.requests)
+	}
+
+	qtedp.remediate(inUse, failed.Add(time.Minute))
+	qtedp.remediate(inUse, failed.Add(2*time.Minute))
+	if len(api.requests) != 1 || api.requests[0] != "POST /api/v1/namespaces/default/pods/pod-a/eviction application/json Bearer secret" {
+		t.Fatalf("Expected one eviction of pod-a, got %v", api.requests)
+	}
+	var eviction struct {
+		Kind     string
+		Metadata struct{ Name, Namespace string }
+	}
+	if err := json.Unmarshal([]byte(api.bodies[0]), &eviction); err != nil || eviction.Kind != "Eviction" ||
+		eviction.Metadata.Name != "pod-a" || eviction.Metadata.Namespace != "default" {
+		t.Fatalf("Unexpected eviction %s: %v", api.bodies[0], err)
+	}
+
+	// A recovered device is not remediated.
+	qtedp.remediation.observe("qtbox_service1", errors.New("device gone"), failed)
+	qtedp.remediation.observe("qtbox_service1", nil, failed.Add(time.Second))
+	qtedp.remediate(inUse, failed.Add(3*time.Minute))
+	if len(api.requests) != 1 {
+		t.Fatalf("Recovered device should not be remediated: %v", api.requests)
+	}
+
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"event":"remediate"`) || !strings.Contains(string(data), `"action":"evict"`) {
+		t.Fatalf("Remediation not audited: %s", data)
+	}
+	if _, err := verifyAuditLog([]string{auditFile}, testAuditKey); err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+}
+
+func TestRemediationRetriesAndRateLimit(t *testing.T) {
+	api := &fakePodAPI{err: errors.New("API server unavailable")}
+	limiter, _ := newRateLimiter(1, 1)
+	qtedp := newTestAllocationPlugin()
+	qtedp.remediation = newRemediator(remediationDelete, 0, false, api, limiter)
+
+	now := time.Now()
+	inUse := map[string]podRef{
+		"qtbox_service0": {Namespace: "default", Name: "pod-a"},
+		"qtbox_service1": {Namespace: "default", Name: "pod-b"},
+	}
+	qtedp.remediation.observe("qtbox_service0", errors.New("device gone"), now)
+	qtedp.remediation.observe("qtbox_service1", errors.New("device gone"), now)
+
+	qtedp.remediate(inUse, now)
+	api.err = nil
+	qtedp.remediate(inUse, now.Add(30*time.Second))
+	if actions := api.taken(); len(actions) != 1 {
+		t.Fatalf("Rate limit should allow one action per minute, got %v", actions)
+	}
+
+	qtedp.remediate(inUse, now.Add(time.Minute))
+	qtedp.remediate(inUse, now.Add(2*time.Minute))
+	qtedp.remediate(inUse, now.Add(3*time.Minute))
+	expected := []string{"delete default/pod-a", "delete default/pod-a", "delete default/pod-b"}
+	if actions := api.taken(); strings.Join(actions, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected the failed deletion to be retried, got %v", actions)
+	}
+
+	// A pod is remediated again if it comes back on a failed device.
+	delete(inUse, "qtbox_service0")
+	qtedp.remediate(inUse, now.Add(4*time.Minute))
+	inUse["qtbox_service0"] = podRef{Namespace: "default", Name: "pod-a"}
+	qtedp.remediate(inUse, now.Add(5*time.Minute))
+	if actions := api.taken(); len(actions) != 4 {
+		t.Fatalf("Expected the returning pod to be remediated, got %v", actions)
+	}
+}
+
+func TestRemediationDryRun(t *testing.T) {
+	api := &fakePodAPI{}
+	limiter, _ := newRateLimiter(60, 1)
+	qtedp := newTestAllocationPlugin()
+	qtedp.remediation = newRemediator(remediationAnnotate, 0, true, api, limiter)
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	qtedp.audit, _ = newAuditLog(auditFile, testAuditKey, defaultAuditMaxSize, 1)
+	defer qtedp.audit.Close()
+
+	now := time.Now()
+	inUse := map[string]podRef{"qtbox_service0": {Namespace: "default", Name: "pod-a"}}
+	qtedp.remediation.observe("qtbox_service0", errors.New("device gone"), now)
+	qtedp.remediate(inUse, now)
+	qtedp.remediate(inUse, now.Add(time.Minute))
+
+	if actions := api.taken(); len(actions) != 0 {
+		t.Fatalf("Dry run should not act, got %v", actions)
+	}
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), `"action":"dry-run annotate"`); n != 1 {
+		t.Fatalf("Expected one audited dry run, got %d: %s", n, data)
+	}
+}
+
+func TestRemediationForgetsRemovedDevices(t *testing.T) {
+	sim, err := newSimBackend(t.TempDir(), simNodeFile, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qtedp := newTestDevicePlugin(newKubeletPaths(t.TempDir()), registrationModeDevicePlugin)
+	qtedp.provider = newQingTianProvider(sim)
+	qtedp.stop = make(chan interface{})
+	limiter, _ := newRateLimiter(60, 1)
+	qtedp.remediation = newRemediator(remediationDelete, 0, false, &fakePodAPI{}, limiter)
+	qtedp.refreshDevices()
+
+	now := time.Now()
+	qtedp.remediation.observe("qtbox_service0", errors.New("device gone"), now)
+	qtedp.remediation.observe("qtbox_service1", errors.New("device gone"), now)
+	simControl(t, sim, http.MethodDelete, "/devices/qtbox_service0")
+	qtedp.refreshDevices()
+
+	if _, ok := qtedp.remediation.failedSince["qtbox_service0"]; ok {
+		t.Fatal("Failure of a removed device should be forgotten")
+	}
+	if _, ok := qtedp.remediation.failedSince["qtbox_service1"]; !ok {
+		t.Fatal("Failure of a remaining device should be kept")
+	}
+}
+
+func TestKubeClient(t *testing.T) {
+	api := &fakeAPIServer{}
+	client := newTestKubeClient(t, api)
+	ctx := context.Background()
+
+	if err := client.annotatePod(ctx, "ns", "pod-a", map[string]string{failedDevicesAnnotation: "qtbox_service0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.deletePod(ctx, "ns", "pod-a"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"PATCH /api/v1/namespaces/ns/pods/pod-a application/merge-patch+json Bearer secret",
+		"DELETE /api/v1/namespaces/ns/pods/pod-a application/json Bearer secret",
+	}
+	if strings.Join(api.requests, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Unexpected requests %q", api.requests)
+	}
+	if api.bodies[0] != `{"metadata":{"annotations":{"qt-enclave.huawei.com/failed-devices":"qtbox_service0"}}}` {
+		t.Fatalf("Unexpected patch %s", api.bodies[0])
+	}
+
+	api.status = http.StatusTooManyRequests
+	err := client.evictPod(ctx, "ns", "pod-a")
+	if err == nil || !strings.Contains(err.Error(), "disruption budget") || !strings.Contains(err.Error(), "Cannot evict pod") {
+		t.Fatalf("Expected a blocked eviction, got %v", err)
+	}
+
+	if _, err := newKubeClient("api:6443", "", ""); err == nil {
+		t.Fatal("API server without scheme should be rejected")
+	}
+}
@@ -0,0 +1,108 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide gRPC interceptors for recovery, logging, metrics and tracing
+ *********************************************************************************/
+
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const metricsPath = "/metrics"
+
+var (
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "qt_enclave_device_plugin",
+		Name:      "rpc_duration_seconds",
+		Help:      "Duration of the gRPC calls served to kubelet.",
+		Buckets:   []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10, 30},
+	}, []string{"resource", "method", "code"})
+	rpcPanics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "qt_enclave_device_plugin",
+		Name:      "rpc_panics_total",
+		Help:      "gRPC calls that panicked and were turned into Internal errors.",
+	}, []string{"resource", "method"})
+
+	// metricsRegistry holds the metrics of the plugin, served on
+	// -metrics-address.
+	metricsRegistry = prometheus.NewRegistry()
+)
+
+func init() {
+	metricsRegistry.MustRegister(rpcDuration, rpcPanics)
+}
+
+// serveMetrics serves the metrics registry over HTTP.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	glog.V(0).Infof("Serving metrics on %s%s", addr, metricsPath)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		glog.Errorf("Metrics server stopped: %v", err)
+	}
+}
+
+// rpcServerOptions returns the interceptors observing the calls of a
+// plugin. They run before the access checks, so rejected calls are
+// observed too, and recover panics of the handlers.
+func rpcServerOptions(resource string, tracer *spanExporter) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+			defer observeRPC(ctx, resource, info.FullMethod, tracer, time.Now(), &err)
+			defer recoverRPC(resource, info.FullMethod, &err)
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+			defer observeRPC(ss.Context(), resource, info.FullMethod, tracer, time.Now(), &err)
+			defer recoverRPC(resource, info.FullMethod, &err)
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// recoverRPC turns a panic of a handler into an Internal error, so that a
+// bug in one call does not take the daemon down.
+func recoverRPC(resource, method string, err *error) {
+	if r := recover(); r != nil {
+		glog.Errorf("Recovered panic in %s for %s: %v\n%s", method, resource, r, debug.Stack())
+		rpcPanics.WithLabelValues(resource, method).Inc()
+		*err = status.Errorf(codes.Internal, "panic in %s: %v", method, r)
+	}
+}
+
+// observeRPC logs a finished call, records its duration and emits its span.
+func observeRPC(ctx context.Context, resource, method string, tracer *spanExporter, start time.Time, err *error) {
+	end := time.Now()
+	st := status.Convert(*err)
+	elapsed := end.Sub(start)
+
+	if st.Code() == codes.OK {
+		glog.V(1).Infof("%s for %s finished in %s", method, resource, elapsed)
+	} else {
+		glog.V(0).Infof("%s for %s failed in %s: %s: %s", method, resource, elapsed, st.Code(), st.Message())
+	}
+	rpcDuration.WithLabelValues(resource, method, st.Code().String()).Observe(elapsed.Seconds())
+
+	tracer.record(ctx, resource, method, start, end, st)
+}
@@ -0,0 +1,177 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide gRPC interceptors for recovery, logging, metrics and tracing testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+// panickingPlugin panics in Allocate and serves the rest normally.
+type panickingPlugin struct {
+	*QtEnclavesDevicePlugin
+}
+
+func (panickingPlugin) Allocate(ctx context.Context, reqs *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	panic("injected failure")
+}
+
+// fakeCollector is an OTLP/HTTP collector stand-in keeping the spans it
+// receives.
+type fakeCollector struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req coltracepb.ExportTraceServiceRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil || r.URL.Path != otlpTracesPath || r.Header.Get("Content-Type") != "application/x-protobuf" ||
+		proto.Unmarshal(body, &req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func (c *fakeCollector) received() []*tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*tracepb.Span(nil), c.spans...)
+}
+
+func serveObserved(t *testing.T, resource string, tracer *spanExporter) pluginapi.DevicePluginClient {
+	sock := filepath.Join(t.TempDir(), "plugin.sock")
+	lis, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(rpcServerOptions(resource, tracer)...)
+	pluginapi.RegisterDevicePluginServer(server, panickingPlugin{newTestAllocationPlugin()})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := dial(sock, devicePluginServerReadyTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pluginapi.NewDevicePluginClient(conn)
+}
+
+func TestRPCPanicRecovery(t *testing.T) {
+	resource := "test/panic"
+	client := serveObserved(t, resource, nil)
+	allocate := "/v1beta1.DevicePlugin/Allocate"
+	before := testutil.ToFloat64(rpcPanics.WithLabelValues(resource, allocate))
+
+	_, err := client.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"}))
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("Expected Internal for a panicking Allocate but got %v", err)
+	}
+	if _, err := client.GetDevicePluginOptions(context.Background(), &pluginapi.Empty{}); err != nil {
+		t.Fatalf("Server should keep serving after a panic: %v", err)
+	}
+
+	if n := testutil.ToFloat64(rpcPanics.WithLabelValues(resource, allocate)) - before; n != 1 {
+		t.Fatalf("Expected one recorded panic, got %v", n)
+	}
+	if n := testutil.CollectAndCount(rpcDuration, "qt_enclave_device_plugin_rpc_duration_seconds"); n < 2 {
+		t.Fatalf("Expected the latency of both calls, got %d series", n)
+	}
+}
+
+func TestRPCSpansExported(t *testing.T) {
+	collector := &fakeCollector{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	tracer, err := newSpanExporter(srv.URL, defaultOTLPServiceName, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := serveObserved(t, resourceName, tracer)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		"traceparent", "00-"+testTraceID+"-00f067aa0ba902b7-01")
+	if _, err := client.GetPreferredAllocation(ctx, &pluginapi.PreferredAllocationRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	client.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"}))
+	tracer.Close()
+
+	spans := collector.received()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %+v", spans)
+	}
+	if hex.EncodeToString(spans[0].TraceId) != testTraceID || hex.EncodeToString(spans[0].ParentSpanId) != "00f067aa0ba902b7" ||
+		spans[0].Name != "v1beta1.DevicePlugin/GetPreferredAllocation" ||
+		spans[0].Kind != tracepb.Span_SPAN_KIND_SERVER || spans[0].Status.Code != tracepb.Status_STATUS_CODE_OK {
+		t.Fatalf("Unexpected span of the traced call %+v", spans[0])
+	}
+	if hex.EncodeToString(spans[1].TraceId) == testTraceID || len(spans[1].TraceId) != 16 || len(spans[1].ParentSpanId) != 0 ||
+		spans[1].Status.Code != tracepb.Status_STATUS_CODE_ERROR {
+		t.Fatalf("Unexpected span of the failed call %+v", spans[1])
+	}
+}
+
+func TestSpanExporterEndpoint(t *testing.T) {
+	if _, err := newSpanExporter("localhost:4318", defaultOTLPServiceName, time.Second); err == nil {
+		t.Fatal("Endpoint without scheme should be rejected")
+	}
+
+	// The path of the endpoint prefixes the traces path.
+	collector := &fakeCollector{}
+	mux := http.NewServeMux()
+	mux.Handle("/otlp/", http.StripPrefix("/otlp", collector))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	tracer, err := newSpanExporter(srv.URL+"/otlp/", defaultOTLPServiceName, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer.record(context.Background(), resourceName, "/v1beta1.DevicePlugin/Allocate", time.Now(), time.Now(), status.New(codes.OK, ""))
+	tracer.Close()
+	if spans := collector.received(); len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %+v", spans)
+	}
+}
@@ -0,0 +1,209 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide systemd notifications and watchdog
+ *********************************************************************************/
+
+package main
+
+import (
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	sdReady    = "READY=1"
+	sdStopping = "STOPPING=1"
+	sdWatchdog = "WATCHDOG=1"
+	sdStatus   = "STATUS="
+
+	// monitorStallTimeout is how long the monitor loop may go without
+	// turning, a start attempt included, before the watchdog stops.
+	monitorStallTimeout = time.Minute
+	// healthCheckStallSlack is added to the health check interval for the
+	// checks themselves and for kubelet to read the updates.
+	healthCheckStallSlack = 30 * time.Second
+)
+
+// sdNotifier sends notifications to systemd and, when the unit has a
+// watchdog, pings it as long as every watched loop makes progress.
+type sdNotifier struct {
+	socket   string
+	watchdog time.Duration
+	// stuck lists the loops last reported as stalled, to log them once.
+	stuck string
+
+	mu    sync.Mutex
+	loops map[string]*watchedLoop
+	done  chan struct{}
+}
+
+type watchedLoop struct {
+	stallAfter time.Duration
+	last       time.Time
+}
+
+// newSDNotifier returns a notifier for the systemd service the daemon runs
+// as, nil when it does not run under systemd.
+func newSDNotifier() *sdNotifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	n := &sdNotifier{socket: socket, watchdog: watchdogTimeout(), loops: map[string]*watchedLoop{},
+		done: make(chan struct{})}
+	if n.watchdog > 0 {
+		glog.V(0).Infof("systemd watchdog enabled, timeout %s", n.watchdog)
+		go n.runWatchdog()
+	}
+	return n
+}
+
+// watchdogTimeout returns the watchdog timeout of the unit, 0 if none. The
+// watchdog may be set for the privileged parent, see -run-as-user.
+func watchdogTimeout() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" {
+		if pid != strconv.Itoa(os.Getpid()) && pid != strconv.Itoa(os.Getppid()) {
+			return 0
+		}
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// notify sends a state to systemd, nil-safe.
+func (n *sdNotifier) notify(states ...string) {
+	if n == nil {
+		return
+	}
+
+	addr := &net.UnixAddr{Name: n.socket, Net: "unixgram"}
+	if strings.HasPrefix(addr.Name, "@") {
+		addr.Name = "\x00" + addr.Name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		glog.Errorf("Failed to notify systemd: %v", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		glog.Errorf("Failed to notify systemd: %v", err)
+	}
+}
+
+// close stops the watchdog pings, nil-safe.
+func (n *sdNotifier) close() {
+	if n != nil {
+		close(n.done)
+	}
+}
+
+// ready tells systemd the daemon started; repeated calls are ignored by
+// systemd.
+func (n *sdNotifier) ready() {
+	n.notify(sdReady)
+}
+
+func (n *sdNotifier) status(status string) {
+	n.notify(sdStatus + status)
+}
+
+func (n *sdNotifier) stopping(status string) {
+	n.notify(sdStopping, sdStatus+status)
+}
+
+// watch adds a loop that must beat at least every stallAfter for the
+// watchdog to be pinged.
+func (n *sdNotifier) watch(name string, stallAfter time.Duration) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.loops[name] = &watchedLoop{stallAfter: stallAfter, last: time.Now()}
+}
+
+// unwatch removes a loop that stopped on purpose.
+func (n *sdNotifier) unwatch(name string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.loops, name)
+}
+
+// beat records the progress of a loop.
+func (n *sdNotifier) beat(name string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if loop, ok := n.loops[name]; ok {
+		loop.last = time.Now()
+	}
+}
+
+// stalled returns the loops that made no progress in time.
+func (n *sdNotifier) stalled(now time.Time) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var names []string
+	for name, loop := range n.loops {
+		if now.Sub(loop.last) > loop.stallAfter {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runWatchdog pings the watchdog twice per timeout while no loop stalls.
+func (n *sdNotifier) runWatchdog() {
+	ticker := time.NewTicker(n.watchdog / 2)
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case now = <-ticker.C:
+		case <-n.done:
+			return
+		}
+
+		stalled := n.stalled(now)
+		if len(stalled) == 0 {
+			n.stuck = ""
+			n.notify(sdWatchdog)
+			continue
+		}
+		if names := strings.Join(stalled, ", "); names != n.stuck {
+			n.stuck = names
+			glog.Errorf("Not pinging the systemd watchdog, stalled: %s", names)
+			n.status("Stalled: " + names)
+		}
+	}
+}
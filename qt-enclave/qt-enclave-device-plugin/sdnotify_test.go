@@ -0,0 +1,165 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide systemd notifications and watchdog testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// listenNotify stands in for systemd, returning the notifications it gets.
+func listenNotify(t *testing.T) chan string {
+	sock := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", sock)
+
+	messages := make(chan string, 100)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			messages <- string(buf[:n])
+		}
+	}()
+	return messages
+}
+
+// waitNotify returns the first notification containing state.
+func waitNotify(messages chan string, state string, timeout time.Duration) (string, bool) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case msg := <-messages:
+			if strings.Contains(msg, state) {
+				return msg, true
+			}
+		case <-deadline:
+			return "", false
+		}
+	}
+}
+
+func TestSDNotifyStates(t *testing.T) {
+	if newSDNotifier() != nil {
+		t.Fatal("Notifier should be disabled outside of systemd")
+	}
+	messages := listenNotify(t)
+	n := newSDNotifier()
+	defer n.close()
+
+	n.ready()
+	if msg, ok := waitNotify(messages, sdReady, time.Second); !ok || msg != "READY=1" {
+		t.Fatalf("Unexpected ready notification %q", msg)
+	}
+	n.status("Registered with kubelet")
+	if msg, ok := waitNotify(messages, sdStatus, time.Second); !ok || msg != "STATUS=Registered with kubelet" {
+		t.Fatalf("Unexpected status notification %q", msg)
+	}
+	n.stopping("Stopping")
+	if _, ok := waitNotify(messages, sdStopping, time.Second); !ok {
+		t.Fatal("Stopping notification not received")
+	}
+}
+
+func TestSDWatchdogFollowsProgress(t *testing.T) {
+	messages := listenNotify(t)
+	t.Setenv("WATCHDOG_USEC", "40000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	n := newSDNotifier()
+	defer n.close()
+
+	n.watch("loop", 100*time.Millisecond)
+	if _, ok := waitNotify(messages, sdWatchdog, time.Second); !ok {
+		t.Fatal("Watchdog not pinged while the loop makes progress")
+	}
+
+	// Without beats the loop stalls and the pings stop.
+	if msg, ok := waitNotify(messages, "Stalled", time.Second); !ok || msg != "STATUS=Stalled: loop" {
+		t.Fatalf("Stalled loop not reported, got %q", msg)
+	}
+	if msg, ok := waitNotify(messages, sdWatchdog, 100*time.Millisecond); ok {
+		t.Fatalf("Watchdog pinged for a stalled loop: %q", msg)
+	}
+
+	n.beat("loop")
+	if _, ok := waitNotify(messages, sdWatchdog, time.Second); !ok {
+		t.Fatal("Watchdog not pinged again after progress")
+	}
+	n.unwatch("loop")
+}
+
+func TestWatchdogTimeout(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	if d := watchdogTimeout(); d != 0 {
+		t.Fatalf("Watchdog should be disabled, got %s", d)
+	}
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getppid()))
+	if d := watchdogTimeout(); d != 30*time.Second {
+		t.Fatalf("Watchdog of the privileged parent should apply, got %s", d)
+	}
+	t.Setenv("WATCHDOG_PID", "1")
+	if d := watchdogTimeout(); d != 0 {
+		t.Fatalf("Watchdog of another process should not apply, got %s", d)
+	}
+}
+
+func TestHealthCheckProgressWithoutListAndWatch(t *testing.T) {
+	sim, err := newSimBackend(t.TempDir(), simNodeFile, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qtedp := newTestDevicePlugin(newKubeletPaths(t.TempDir()), registrationModeDevicePlugin)
+	qtedp.provider = newQingTianProvider(sim)
+	qtedp.healthCheckInterval = 10 * time.Millisecond
+	qtedp.stop = make(chan interface{})
+	go qtedp.healthcheck()
+	defer close(qtedp.stop)
+
+	// No ListAndWatch client reads the updates: the health check must
+	// keep going rather than block on them.
+	for i := 0; i < 3; i++ {
+		for _, action := range []string{"break", "repair"} {
+			simControl(t, sim, http.MethodPost, "/devices/qtbox_service0/"+action)
+			want := pluginapi.Unhealthy
+			if action == "repair" {
+				want = pluginapi.Healthy
+			}
+			deadline := time.Now().Add(time.Second)
+			for healthOf(qtedp.listDevices(), "qtbox_service0") != want {
+				if time.Now().After(deadline) {
+					t.Fatalf("Health check stalled after %s #%d", action, i)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+	}
+}
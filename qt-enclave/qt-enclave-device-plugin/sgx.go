@@ -0,0 +1,152 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide Intel SGX enclave provider
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	sgxResourceName    = "huawei.com/sgx_enclave"
+	sgxEPCResourceName = "huawei.com/sgx_epc"
+	sgxEnclaveDevice   = "sgx_enclave"
+	sgxProvisionDevice = "sgx_provision"
+
+	defaultSGXEnclavesPerNode = 20
+	defaultNodeSysfsDir       = "/sys/devices/system/node"
+
+	// envSGXEPCMB is set by Allocate with the EPC granted to the container.
+	envSGXEPCMB = "SGX_EPC_MB"
+)
+
+// sgxProvider serves Intel SGX enclaves. All enclaves of the node share
+// /dev/sgx_enclave, so the provider advertises a fixed number of slots that
+// each give access to the device nodes.
+type sgxProvider struct {
+	devRoot string
+	slots   int
+	// provision also exposes /dev/sgx_provision, needed by quoting enclaves.
+	provision bool
+	// nodes verifies the device nodes, nil only checks that they exist.
+	nodes *nodeVerifier
+}
+
+func newSGXProvider(devRoot string, slots int, provision bool) *sgxProvider {
+	return &sgxProvider{devRoot: devRoot, slots: slots, provision: provision}
+}
+
+func (p *sgxProvider) name() string {
+	return providerSGX
+}
+
+func (p *sgxProvider) resourceName() string {
+	return sgxResourceName
+}
+
+func (p *sgxProvider) socketName() string {
+	return sgxEnclaveDevice + ".sock"
+}
+
+// discover advertises the slots when the node supports SGX and none
+// otherwise.
+func (p *sgxProvider) discover() ([]string, error) {
+	if err := p.nodes.verify(p.hostPath("")); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if isNodeRejected(err) {
+			glog.Errorf("Not advertising device: %v", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ids := make([]string, 0, p.slots)
+	for i := 0; i < p.slots; i++ {
+		ids = append(ids, sgxEnclaveDevice+strconv.Itoa(i))
+	}
+	return ids, nil
+}
+
+func (p *sgxProvider) check(id string) error {
+	nodes := []string{p.hostPath(id)}
+	if p.provision {
+		nodes = append(nodes, filepath.Join(p.devRoot, sgxProvisionDevice))
+	}
+	for _, node := range nodes {
+		if err := p.nodes.verify(node); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("device is not exist: %s", node)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// hostPath returns the shared enclave device for every slot.
+func (p *sgxProvider) hostPath(id string) string {
+	return filepath.Join(p.devRoot, sgxEnclaveDevice)
+}
+
+// deviceSpecs ignores the configured container path: the SGX runtime
+// expects the device nodes at their standard location.
+func (p *sgxProvider) deviceSpecs(id, containerPath string) []*pluginapi.DeviceSpec {
+	specs := []*pluginapi.DeviceSpec{{ContainerPath: devicePath(sgxEnclaveDevice), HostPath: p.hostPath(id)}}
+	if p.provision {
+		specs = append(specs, &pluginapi.DeviceSpec{
+			ContainerPath: devicePath(sgxProvisionDevice),
+			HostPath:      filepath.Join(p.devRoot, sgxProvisionDevice),
+		})
+	}
+	return specs
+}
+
+func (p *sgxProvider) preStart(ids []string) error {
+	return nil
+}
+
+// sgxEPCCapacity counts the EPC of all NUMA nodes in MiB.
+func sgxEPCCapacity(nodeDir string) capacitySource {
+	return func() (int, error) {
+		files, err := filepath.Glob(filepath.Join(nodeDir, "node*", "x86", "sgx_total_bytes"))
+		if err != nil {
+			return 0, err
+		}
+
+		var total uint64
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return 0, err
+			}
+			bytes, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid EPC size in %s: %v", file, err)
+			}
+			total += bytes
+		}
+
+		return int(total >> 20), nil
+	}
+}
@@ -0,0 +1,243 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide simulation backend without QingTian hardware
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/golang/glog"
+)
+
+const (
+	simNodeFile = "file"
+	simNodeFIFO = "fifo"
+
+	simControlSocketName = "control.sock"
+)
+
+var simDeviceIDPattern = regexp.MustCompile("^" + deviceName + `[0-9]+$`)
+
+// simDevice is the state of a simulated device reported by the control API.
+type simDevice struct {
+	ID     string `json:"id"`
+	Path   string `json:"path"`
+	Broken bool   `json:"broken"`
+}
+
+// simBackend fakes the enclave device nodes with regular files or FIFOs in
+// a directory. Devices can be added, removed and broken at runtime through
+// the control API.
+type simBackend struct {
+	mu       sync.Mutex
+	dir      string
+	nodeType string
+	broken   map[string]bool
+}
+
+func newSimBackend(dir, nodeType string, count int) (*simBackend, error) {
+	if nodeType != simNodeFile && nodeType != simNodeFIFO {
+		return nil, fmt.Errorf("unknown simulated node type: %s", nodeType)
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	b := &simBackend{dir: dir, nodeType: nodeType, broken: map[string]bool{}}
+	for i := 0; i < count; i++ {
+		id := deviceName + strconv.Itoa(i)
+		if _, err := os.Lstat(b.hostPath(id)); err == nil {
+			continue
+		}
+		if err := b.addDevice(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+func (b *simBackend) discover() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if simDeviceIDPattern.MatchString(e.Name()) {
+			ids = append(ids, e.Name())
+		}
+	}
+	return ids, nil
+}
+
+func (b *simBackend) check(id string) error {
+	b.mu.Lock()
+	broken := b.broken[id]
+	b.mu.Unlock()
+
+	if _, err := os.Lstat(b.hostPath(id)); err != nil {
+		return err
+	}
+	if broken {
+		return fmt.Errorf("device %s is broken by simulation", id)
+	}
+	return nil
+}
+
+func (b *simBackend) hostPath(id string) string {
+	return filepath.Join(b.dir, id)
+}
+
+func (b *simBackend) addDevice(id string) error {
+	if !simDeviceIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid device ID %q, expected %s<n>", id, deviceName)
+	}
+
+	path := b.hostPath(id)
+	if b.nodeType == simNodeFIFO {
+		return syscall.Mkfifo(path, 0660)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0660)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (b *simBackend) removeDevice(id string) error {
+	if !simDeviceIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid device ID %q, expected %s<n>", id, deviceName)
+	}
+	if err := os.Remove(b.hostPath(id)); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	delete(b.broken, id)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *simBackend) setBroken(id string, broken bool) error {
+	if _, err := os.Lstat(b.hostPath(id)); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if broken {
+		b.broken[id] = true
+	} else {
+		delete(b.broken, id)
+	}
+	return nil
+}
+
+func (b *simBackend) devices() ([]simDevice, error) {
+	ids, err := b.discover()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	devs := []simDevice{}
+	for _, id := range ids {
+		devs = append(devs, simDevice{ID: id, Path: b.hostPath(id), Broken: b.broken[id]})
+	}
+	return devs, nil
+}
+
+// ServeHTTP implements the control API:
+//
+//	GET    /devices             list the simulated devices
+//	POST   /devices/<id>        add a device
+//	DELETE /devices/<id>        remove a device
+//	POST   /devices/<id>/break  make the health check fail
+//	POST   /devices/<id>/repair make the health check pass again
+func (b *simBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "devices" || len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var err error
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		var devs []simDevice
+		if devs, err = b.devices(); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(devs)
+			return
+		}
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		err = b.addDevice(parts[1])
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		err = b.removeDevice(parts[1])
+	case len(parts) == 3 && r.Method == http.MethodPost && parts[2] == "break":
+		err = b.setBroken(parts[1], true)
+	case len(parts) == 3 && r.Method == http.MethodPost && parts[2] == "repair":
+		err = b.setBroken(parts[1], false)
+	default:
+		http.Error(w, "unsupported request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		glog.Errorf("Simulation control %s %s failed: %v", r.Method, r.URL.Path, err)
+		code := http.StatusBadRequest
+		if os.IsNotExist(err) {
+			code = http.StatusNotFound
+		} else if os.IsExist(err) {
+			code = http.StatusConflict
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	glog.V(0).Infof("Simulation control %s %s", r.Method, r.URL.Path)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveControl serves the control API on a unix socket.
+func (b *simBackend) serveControl(socket string) (*http.Server, error) {
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{Handler: b}
+	go server.Serve(lis)
+	glog.V(0).Info("Simulation control API listening on: ", socket)
+
+	return server, nil
+}
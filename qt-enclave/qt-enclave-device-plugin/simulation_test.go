@@ -0,0 +1,105 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide simulation backend testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func simControl(t *testing.T, sim *simBackend, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	sim.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestSimulationBackendNodes(t *testing.T) {
+	dir := t.TempDir()
+	sim, err := newSimBackend(dir, simNodeFIFO, 2)
+	if err != nil {
+		t.Fatalf("Failed to create simulation backend: %v", err)
+	}
+
+	ids, err := sim.discover()
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("Expected 2 simulated devices but got %v, %v", ids, err)
+	}
+	info, err := os.Lstat(sim.hostPath("qtbox_service1"))
+	if err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("Expected a FIFO for qtbox_service1, got %v, %v", info, err)
+	}
+
+	if _, err := newSimBackend(dir, "chardev", 1); err == nil {
+		t.Fatal("Unknown node type should be rejected")
+	}
+}
+
+func TestSimulationControlAPI(t *testing.T) {
+	sim, err := newSimBackend(t.TempDir(), simNodeFile, 1)
+	if err != nil {
+		t.Fatalf("Failed to create simulation backend: %v", err)
+	}
+	qtedp := newTestDevicePlugin(newKubeletPaths(t.TempDir()), registrationModeDevicePlugin)
+	qtedp.provider = newQingTianProvider(sim)
+	qtedp.stop = make(chan interface{})
+	qtedp.health = make(chan *pluginapi.Device, 10)
+
+	if w := simControl(t, sim, http.MethodPost, "/devices/qtbox_service3"); w.Code != http.StatusNoContent {
+		t.Fatalf("Adding a device failed: %d %s", w.Code, w.Body)
+	}
+	if w := simControl(t, sim, http.MethodPost, "/devices/qtbox_service3"); w.Code != http.StatusConflict {
+		t.Fatalf("Adding a device twice should conflict, got %d", w.Code)
+	}
+	if w := simControl(t, sim, http.MethodPost, "/devices/other"); w.Code != http.StatusBadRequest {
+		t.Fatalf("Adding an invalid device should fail, got %d", w.Code)
+	}
+
+	qtedp.refreshDevices()
+	if ids := qtedp.deviceIDs(); len(ids) != 2 {
+		t.Fatalf("Expected the added device to be advertised, got %v", ids)
+	}
+
+	if w := simControl(t, sim, http.MethodPost, "/devices/qtbox_service3/break"); w.Code != http.StatusNoContent {
+		t.Fatalf("Breaking a device failed: %d %s", w.Code, w.Body)
+	}
+	if err := sim.check("qtbox_service3"); err == nil {
+		t.Fatal("Broken device should fail the health check")
+	}
+
+	w := simControl(t, sim, http.MethodGet, "/devices")
+	var devs []simDevice
+	if err := json.Unmarshal(w.Body.Bytes(), &devs); err != nil || len(devs) != 2 || !devs[1].Broken {
+		t.Fatalf("Unexpected device list %s: %v", w.Body, err)
+	}
+
+	simControl(t, sim, http.MethodPost, "/devices/qtbox_service3/repair")
+	if err := sim.check("qtbox_service3"); err != nil {
+		t.Fatalf("Repaired device should pass the health check: %v", err)
+	}
+
+	if w := simControl(t, sim, http.MethodDelete, "/devices/qtbox_service0"); w.Code != http.StatusNoContent {
+		t.Fatalf("Removing a device failed: %d %s", w.Code, w.Body)
+	}
+	qtedp.refreshDevices()
+	if ids := qtedp.deviceIDs(); len(ids) != 1 || ids[0] != "qtbox_service3" {
+		t.Fatalf("Expected only qtbox_service3 to be advertised, got %v", ids)
+	}
+}
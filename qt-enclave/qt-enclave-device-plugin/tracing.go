@@ -0,0 +1,155 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide OpenTelemetry span export over OTLP/HTTP
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	otelresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	otlpTracesPath = "/v1/traces"
+
+	defaultOTLPServiceName   = "qt-enclave-device-plugin"
+	defaultSpanFlushInterval = 5 * time.Second
+	spanBatchSize            = 64
+	// maxQueuedSpans bounds the memory used while the collector is down.
+	maxQueuedSpans = 2048
+	otlpTimeout    = 10 * time.Second
+)
+
+// metadataCarrier reads the W3C trace context sent by the caller from the
+// gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// spanExporter sends a server span per gRPC call to an OTLP/HTTP collector
+// through the OpenTelemetry SDK. Spans are batched, and dropped, not
+// retried, when the collector fails.
+type spanExporter struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// newSpanExporter starts exporting to the collector at endpoint, such as
+// http://localhost:4318.
+func newSpanExporter(endpoint, service string, flushInterval time.Duration) (*spanExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, expected an http:// or https:// URL", endpoint)
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + otlpTracesPath),
+		otlptracehttp.WithTimeout(otlpTimeout),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+	}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithBatchTimeout(flushInterval),
+			sdktrace.WithMaxExportBatchSize(spanBatchSize),
+			sdktrace.WithMaxQueueSize(maxQueuedSpans)),
+		sdktrace.WithResource(otelresource.NewSchemaless(attribute.String("service.name", service))),
+	)
+	return &spanExporter{provider: provider, tracer: provider.Tracer(defaultOTLPServiceName)}, nil
+}
+
+// record emits the server span of a finished call, nil-safe. A W3C
+// traceparent sent by the caller is the parent of the span, without one the
+// span starts a new trace.
+func (e *spanExporter) record(ctx context.Context, resource, method string, start, end time.Time, st *status.Status) {
+	if e == nil {
+		return
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = propagation.TraceContext{}.Extract(ctx, metadataCarrier(md))
+	service, name := "", strings.TrimPrefix(method, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		service, name = name[:i], name[i+1:]
+	}
+
+	_, span := e.tracer.Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", name),
+			attribute.Int64("rpc.grpc.status_code", int64(st.Code())),
+			attribute.String("qt_enclave.resource", resource),
+		))
+	if st.Code() == codes.OK {
+		span.SetStatus(otelcodes.Ok, "")
+	} else {
+		span.SetStatus(otelcodes.Error, st.Message())
+	}
+	span.End(trace.WithTimestamp(end))
+}
+
+// Close flushes the queued spans and stops the exporter, nil-safe.
+func (e *spanExporter) Close() {
+	if e == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), otlpTimeout)
+	defer cancel()
+	if err := e.provider.Shutdown(ctx); err != nil {
+		glog.Errorf("Failed to export spans: %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide vsock context ID assignment
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultVsockDevice = "/dev/vsock"
+
+	// Environment variables set by Allocate. QT_ENCLAVE_CIDS lists the CIDs
+	// of all devices of the container in allocation order.
+	envEnclaveCID  = "QT_ENCLAVE_CID"
+	envEnclaveCIDs = "QT_ENCLAVE_CIDS"
+)
+
+// reservedCIDs cannot be handed to an enclave: hypervisor, local loopback,
+// host and VMADDR_CID_ANY.
+var reservedCIDs = map[uint32]string{
+	0:              "hypervisor",
+	1:              "local",
+	2:              "host",
+	math.MaxUint32: "any",
+}
+
+// cidRange is an inclusive range of vsock context IDs.
+type cidRange struct {
+	first, last uint32
+}
+
+func (r cidRange) String() string {
+	if r.first == r.last {
+		return strconv.FormatUint(uint64(r.first), 10)
+	}
+	return fmt.Sprintf("%d-%d", r.first, r.last)
+}
+
+// parseCIDRanges parses "100-199,300" into sorted ranges. Overlapping
+// ranges and reserved CIDs are rejected.
+func parseCIDRanges(spec string) ([]cidRange, error) {
+	var ranges []cidRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			last = first
+		}
+		lo, err := strconv.ParseUint(strings.TrimSpace(first), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vsock CID range %q: %v", part, err)
+		}
+		hi, err := strconv.ParseUint(strings.TrimSpace(last), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vsock CID range %q: %v", part, err)
+		}
+		if lo > hi {
+			return nil, fmt.Errorf("invalid vsock CID range %q: start is after end", part)
+		}
+		ranges = append(ranges, cidRange{first: uint32(lo), last: uint32(hi)})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("empty vsock CID pool: %q", spec)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].first < ranges[j].first })
+	for i := 1; i < len(ranges); i++ {
+		if ranges[i].first <= ranges[i-1].last {
+			return nil, fmt.Errorf("vsock CID ranges %s and %s overlap", ranges[i-1], ranges[i])
+		}
+	}
+	for cid, name := range reservedCIDs {
+		for _, r := range ranges {
+			if cid >= r.first && cid <= r.last {
+				return nil, fmt.Errorf("vsock CID range %s contains reserved CID %d (%s)", r, cid, name)
+			}
+		}
+	}
+
+	return ranges, nil
+}
+
+// localCID returns the CID of this node, or false when the node has no
+// vsock device.
+func localCID(vsockDevice string) (uint32, bool, error) {
+	f, err := os.Open(vsockDevice)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	defer f.Close()
+
+	cid, err := unix.IoctlGetUint32(int(f.Fd()), unix.IOCTL_VM_SOCKETS_GET_LOCAL_CID)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get local vsock CID from %s: %v", vsockDevice, err)
+	}
+	return cid, true, nil
+}
+
+// cidPool hands out a unique vsock CID per allocated device. A device keeps
+// its CID until it is reclaimed on release, so a device allocated again
+// without release detection gets the same CID.
+type cidPool struct {
+	mu       sync.Mutex
+	ranges   []cidRange
+	assigned map[string]uint32
+	used     map[uint32]string
+}
+
+// newCIDPool parses the pool and checks that it does not contain the CID of
+// the node itself, as reported by vsockDevice.
+func newCIDPool(spec, vsockDevice string) (*cidPool, error) {
+	ranges, err := parseCIDRanges(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	cid, ok, err := localCID(vsockDevice)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		for _, r := range ranges {
+			if cid >= r.first && cid <= r.last {
+				return nil, fmt.Errorf("vsock CID range %s contains the local CID %d of this node", r, cid)
+			}
+		}
+	}
+
+	return &cidPool{ranges: ranges, assigned: map[string]uint32{}, used: map[uint32]string{}}, nil
+}
+
+// size returns the number of CIDs in the pool.
+func (p *cidPool) size() uint64 {
+	var n uint64
+	for _, r := range p.ranges {
+		n += uint64(r.last-r.first) + 1
+	}
+	return n
+}
+
+// assign returns the CIDs of the devices, taking the lowest free CIDs for
+// devices that have none yet, and the devices it took a CID for. Nothing is
+// assigned when the pool runs out.
+func (p *cidPool) assign(ids []string) ([]uint32, []string, error) {
+	if p == nil {
+		return nil, nil, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cids := make([]uint32, 0, len(ids))
+	var taken []string
+	for _, id := range ids {
+		if cid, ok := p.assigned[id]; ok {
+			cids = append(cids, cid)
+			continue
+		}
+		cid, ok := p.nextFree()
+		if !ok {
+			for _, id := range taken {
+				delete(p.used, p.assigned[id])
+				delete(p.assigned, id)
+			}
+			return nil, nil, fmt.Errorf("vsock CID pool exhausted")
+		}
+		p.assigned[id] = cid
+		p.used[cid] = id
+		taken = append(taken, id)
+		cids = append(cids, cid)
+	}
+	return cids, taken, nil
+}
+
+// nextFree must be called with p.mu held.
+func (p *cidPool) nextFree() (uint32, bool) {
+	for _, r := range p.ranges {
+		for cid := uint64(r.first); cid <= uint64(r.last); cid++ {
+			if _, ok := p.used[uint32(cid)]; !ok {
+				return uint32(cid), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// release returns the CID of the device to the pool.
+func (p *cidPool) release(id string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if cid, ok := p.assigned[id]; ok {
+		delete(p.used, cid)
+		delete(p.assigned, id)
+	}
+}
+
+// snapshot returns the CID of every device that has one.
+func (p *cidPool) snapshot() map[string]uint32 {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cids := make(map[string]uint32, len(p.assigned))
+	for id, cid := range p.assigned {
+		cids[id] = cid
+	}
+	return cids
+}
+
+// restore assigns a known CID to the device, as before a handover.
+func (p *cidPool) restore(id string, cid uint32) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	inPool := false
+	for _, r := range p.ranges {
+		if cid >= r.first && cid <= r.last {
+			inPool = true
+			break
+		}
+	}
+	if !inPool {
+		return fmt.Errorf("CID %d is not in the pool", cid)
+	}
+	if owner, ok := p.used[cid]; ok && owner != id {
+		return fmt.Errorf("CID %d is already assigned to %s", cid, owner)
+	}
+	if old, ok := p.assigned[id]; ok && old != cid {
+		return fmt.Errorf("device already has CID %d", old)
+	}
+	p.assigned[id] = cid
+	p.used[cid] = id
+	return nil
+}
+
+// cidEnvs returns the environment variables announcing the CIDs to the
+// container.
+func cidEnvs(cids []uint32) map[string]string {
+	if len(cids) == 0 {
+		return nil
+	}
+	list := make([]string, 0, len(cids))
+	for _, cid := range cids {
+		list = append(list, strconv.FormatUint(uint64(cid), 10))
+	}
+	return map[string]string{
+		envEnclaveCID:  list[0],
+		envEnclaveCIDs: strings.Join(list, ","),
+	}
+}
@@ -0,0 +1,96 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide vsock context ID assignment testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseCIDRanges(t *testing.T) {
+	ranges, err := parseCIDRanges("300, 100-199")
+	if err != nil || len(ranges) != 2 || ranges[0] != (cidRange{100, 199}) || ranges[1] != (cidRange{300, 300}) {
+		t.Fatalf("Unexpected ranges %v: %v", ranges, err)
+	}
+
+	for _, spec := range []string{"", "10-5", "abc", "100-199,150-250", "100,100", "1-10", "4294967295", "2"} {
+		if _, err := parseCIDRanges(spec); err == nil {
+			t.Fatalf("CID pool %q should be rejected", spec)
+		}
+	}
+}
+
+func TestCIDPoolAssignment(t *testing.T) {
+	pool, err := newCIDPool("10-11", filepath.Join(t.TempDir(), "vsock"))
+	if err != nil {
+		t.Fatalf("Failed to create CID pool: %v", err)
+	}
+
+	cids, _, err := pool.assign([]string{"qtbox_service0", "qtbox_service1"})
+	if err != nil || len(cids) != 2 || cids[0] != 10 || cids[1] != 11 {
+		t.Fatalf("Unexpected CIDs %v: %v", cids, err)
+	}
+	// A device keeps its CID until released.
+	if cids, _, _ := pool.assign([]string{"qtbox_service1"}); cids[0] != 11 {
+		t.Fatalf("qtbox_service1 should keep CID 11, got %v", cids)
+	}
+	if _, _, err := pool.assign([]string{"qtbox_service2"}); err == nil {
+		t.Fatal("Exhausted pool should fail")
+	}
+
+	pool.release("qtbox_service0")
+	if cids, _, err := pool.assign([]string{"qtbox_service2"}); err != nil || cids[0] != 10 {
+		t.Fatalf("Released CID 10 should be reused, got %v: %v", cids, err)
+	}
+}
+
+func TestAllocateVsockCIDs(t *testing.T) {
+	qtedp := newTestAllocationPlugin()
+	pool, err := newCIDPool("20-21", filepath.Join(t.TempDir(), "vsock"))
+	if err != nil {
+		t.Fatalf("Failed to create CID pool: %v", err)
+	}
+	qtedp.cids = pool
+
+	resp, err := qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service1", "qtbox_service0"}))
+	if err != nil {
+		t.Fatalf("Allocation failed: %v", err)
+	}
+	envs := resp.ContainerResponses[0].Envs
+	if envs[envEnclaveCID] != "20" || envs[envEnclaveCIDs] != "20,21" {
+		t.Fatalf("Unexpected CID envs: %v", envs)
+	}
+
+	// Both devices are released and their CIDs handed to other devices.
+	for _, id := range []string{"qtbox_service0", "qtbox_service1"} {
+		delete(qtedp.allocations, id)
+		pool.release(id)
+	}
+	if _, _, err := pool.assign([]string{"other0", "other1"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = qtedp.Allocate(context.Background(), allocateRequest([]string{"qtbox_service0"}))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted but got %v", err)
+	}
+	if _, ok := qtedp.allocations["qtbox_service0"]; ok {
+		t.Fatal("Rejected request left its allocation behind")
+	}
+}
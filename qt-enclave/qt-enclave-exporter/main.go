@@ -4,12 +4,15 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -23,6 +26,10 @@ import (
 const (
 	period = time.Second
 	re     = `CpuUsage: (\d+\.\d+)%, MemTotal: (\d+) kB, MemFree: (\d+) kB, MemAvailable: (\d+) kB`
+	// watcherStallTimeout is how long the log watcher may go without
+	// watching, reading or ticking on its file before the systemd
+	// watchdog is no longer pinged
+	watcherStallTimeout = 30 * time.Second
 )
 
 var (
@@ -31,8 +38,79 @@ var (
 	logFile  string
 	port     int
 	lastLog  string
+	// qlogUpdate is the unix time of the last qlog read, 0 before the first sample
+	qlogUpdate float64
+	// watcherProgress is the last time the log watcher loop turned, in unix nanoseconds
+	watcherProgress atomic.Int64
 )
 
+// sdNotify sends a state to systemd when running as a notify service
+func sdNotify(states ...string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		logrus.Warnf("failed to notify systemd: %v", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		logrus.Warnf("failed to notify systemd: %v", err)
+	}
+}
+
+// watchdogInterval returns the interval of the systemd watchdog pings, 0 if disabled
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+func markWatcherProgress() {
+	watcherProgress.Store(time.Now().UnixNano())
+}
+
+// runWatchdog pings the systemd watchdog only while the log watcher turns
+// and the metrics server answers
+func runWatchdog() {
+	interval := watchdogInterval()
+	if interval == 0 {
+		return
+	}
+	client := &http.Client{Timeout: interval / 2}
+	url := fmt.Sprintf("http://127.0.0.1:%d/metrics", port)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if since := time.Since(time.Unix(0, watcherProgress.Load())); since > watcherStallTimeout {
+			logrus.Warnf("log watcher stalled for %s, not pinging watchdog", since)
+			continue
+		}
+		resp, err := client.Get(url)
+		if err != nil {
+			logrus.Warnf("metrics server not answering, not pinging watchdog: %v", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			logrus.Warnf("metrics server returned %s, not pinging watchdog", resp.Status)
+			continue
+		}
+		sdNotify("WATCHDOG=1")
+	}
+}
+
 // readLastLine read latest qlog
 func readLastLine(filePath string) (string, error) {
 	file, err := os.Open(filePath)
@@ -104,14 +182,28 @@ func watchResourceLog() {
 	err = watcher.Add(logFile)
 	if err != nil {
 		logrus.Warnf("failed to add file into watcher: %v\n", err)
+		sdNotify("STATUS=Waiting for " + logFile)
 		return
 	}
+	sdNotify("STATUS=Watching " + logFile)
+	markWatcherProgress()
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
 	for {
 		select {
+		case <-ticker.C:
+			// an idle watcher makes progress as long as its file is there
+			if _, err := os.Stat(logFile); err != nil {
+				logrus.Warnf("failed to stat watched file: %v", err)
+				return
+			}
+			markWatcherProgress()
 		case event, ok := <-watcher.Events:
 			if !ok {
-				logrus.Infof("failed to get file event")
-				continue
+				// the watcher is closed, restart fswatch
+				logrus.Warnf("file events closed, restart watch file")
+				return
 			}
 			if event.Op&fsnotify.Write == fsnotify.Write {
 				logLine, err := readLastLine(logFile)
@@ -119,8 +211,13 @@ func watchResourceLog() {
 					logrus.Infof("failed to read resource file, err: %v", err)
 					continue
 				}
+				markWatcherProgress()
 				if logLine == lastLog {
-					logrus.Warn("get the last log, qlog is not update")
+					// the same sample was logged again, it is still current
+					logrus.Debug("get the last log again, qlog is not changed")
+					rwMutex.Lock()
+					qlogUpdate = float64(time.Now().UnixNano()) / 1e9
+					rwMutex.Unlock()
 					continue
 				}
 				// regular matching related data
@@ -138,6 +235,7 @@ func watchResourceLog() {
 					qlogData[1] = memTotal
 					qlogData[2] = memFree
 					qlogData[3] = memAvailable
+					qlogUpdate = float64(time.Now().UnixNano()) / 1e9
 					rwMutex.Unlock()
 					lastLog = logLine
 				} else {
@@ -219,6 +317,20 @@ func registerPrometheusMetrics(errCh chan error) {
 				return qlogData[3]
 			},
 		),
+
+		// define the last update GaugeFunc, consumers skip stale samples with it
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Namespace: "qingtian",
+				Name:      "last_update_timestamp_seconds",
+				Help:      "Unix time of the last qlog read, 0 before the first sample",
+			},
+			func() float64 {
+				rwMutex.RLock()
+				defer rwMutex.RUnlock()
+				return qlogUpdate
+			},
+		),
 	}
 
 	// create a prometheus registry
@@ -232,10 +344,17 @@ func registerPrometheusMetrics(errCh chan error) {
 
 	// start server with port, support metrics interface
 	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
-	logrus.Debug("starting server on %d", port)
-	if err := http.ListenAndServe(fmt.Sprintf("%s%d", ":", port), nil); err != nil {
+	logrus.Debugf("starting server on %d", port)
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s%d", ":", port))
+	if err != nil {
 		logrus.Errorf("failed to start server: %v", err)
 		errCh <- fmt.Errorf("failed to listen port %d: %w", port, err)
+		return
+	}
+	sdNotify("READY=1", fmt.Sprintf("STATUS=Serving metrics on port %d", port))
+	if err := http.Serve(listener, nil); err != nil {
+		logrus.Errorf("failed to serve: %v", err)
+		errCh <- fmt.Errorf("failed to serve port %d: %w", port, err)
 	}
 }
 
@@ -256,9 +375,11 @@ func handleSignals(errCh chan error) {
 	select {
 	case sig = <-signalChannel:
 		logrus.Errorf("interrupted by a %v signal, process exiting", sig)
+		sdNotify("STOPPING=1")
 		return
 	case err := <-errCh:
 		logrus.Errorf("receive error: %v", err)
+		sdNotify("STOPPING=1")
 		return
 	}
 }
@@ -266,8 +387,10 @@ func handleSignals(errCh chan error) {
 func runStart(_ *cobra.Command, _ []string) error {
 	errCh := make(chan error)
 
+	markWatcherProgress()
 	go tryWatchLog()
 	go registerPrometheusMetrics(errCh)
+	go runWatchdog()
 	handleSignals(errCh)
 	return fmt.Errorf("qt enclave export process exit")
 }
@@ -0,0 +1,256 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the filter and prioritize verbs of the scheduler extender
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	extenderv1 "k8s.io/kube-scheduler/extender/v1"
+)
+
+const (
+	defaultResourceName       = "huawei.com/qt_enclaves"
+	defaultMemoryResourceName = "huawei.com/qt_enclave_memory"
+	defaultMemoryUnitMB       = 256
+	defaultMaxCPUUsage        = 90
+	defaultMaxAge             = time.Minute
+	// maxArgsSize bounds the ExtenderArgs bodies, which hold every node
+	// without nodeCacheCapable.
+	maxArgsSize = 64 * 1024 * 1024
+)
+
+// extender filters out the nodes whose enclaves lack the headroom a pod
+// needs and prefers the least loaded ones. Nodes without fresh metrics are
+// kept by the filter and get the lowest score.
+type extender struct {
+	scraper            *scraper
+	resourceName       corev1.ResourceName
+	memoryResourceName corev1.ResourceName
+	memoryUnitMB       int64
+	// maxCPUUsage is the enclave CPU usage percentage above which nodes
+	// are filtered out.
+	maxCPUUsage float64
+	// minMemoryAvailableMB is the enclave memory a node keeps available on
+	// top of the memory the pod requests.
+	minMemoryAvailableMB int64
+	maxAge               time.Duration
+	memoryWeight         float64
+	cpuWeight            float64
+}
+
+// podDemand returns whether the pod uses enclaves and the enclave memory it
+// requests in kB.
+func (e *extender) podDemand(pod *corev1.Pod) (bool, float64) {
+	enclaves := podLimit(pod, e.resourceName)
+	memory := podLimit(pod, e.memoryResourceName)
+	return enclaves > 0 || memory > 0, float64(memory*e.memoryUnitMB) * 1024
+}
+
+// podLimit returns the limit of resource of the pod: the sum over the
+// containers, or the largest init container if more, as init containers run
+// one after the other before the containers.
+func podLimit(pod *corev1.Pod, resource corev1.ResourceName) int64 {
+	var sum, init int64
+	for _, c := range pod.Spec.Containers {
+		if q, ok := c.Resources.Limits[resource]; ok {
+			sum += q.Value()
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		if q, ok := c.Resources.Limits[resource]; ok && q.Value() > init {
+			init = q.Value()
+		}
+	}
+	if init > sum {
+		sum = init
+	}
+	return sum
+}
+
+// nodeTargets returns the nodes of the arguments, with the address of their
+// exporter. With nodeCacheCapable only the names are sent and used as
+// address.
+func nodeTargets(args *extenderv1.ExtenderArgs) []nodeTarget {
+	var targets []nodeTarget
+	if args.Nodes != nil {
+		for _, node := range args.Nodes.Items {
+			targets = append(targets, nodeTarget{Name: node.Name, Address: nodeAddress(&node)})
+		}
+		return targets
+	}
+	if args.NodeNames != nil {
+		for _, name := range *args.NodeNames {
+			targets = append(targets, nodeTarget{Name: name, Address: name})
+		}
+	}
+	return targets
+}
+
+// nodeAddress prefers the internal IP of a node, then its hostname.
+func nodeAddress(node *corev1.Node) string {
+	for _, addrType := range []corev1.NodeAddressType{corev1.NodeInternalIP, corev1.NodeHostName} {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type == addrType && addr.Address != "" {
+				return addr.Address
+			}
+		}
+	}
+	return node.Name
+}
+
+// freshSamples returns the samples of the targets fresh enough to be used.
+func (e *extender) freshSamples(r *http.Request, targets []nodeTarget) map[string]*nodeSample {
+	now := e.scraper.now()
+	samples := e.scraper.collect(r.Context(), targets)
+	for name, sample := range samples {
+		if !sample.fresh(now, e.maxAge) {
+			glog.V(2).Infof("Ignoring stale metrics of node %s", name)
+			delete(samples, name)
+		}
+	}
+	return samples
+}
+
+// rejection returns why a node cannot take the pod, empty if it can.
+func (e *extender) rejection(sample *nodeSample, memoryKB float64) string {
+	if sample.CPUUsage > e.maxCPUUsage {
+		return fmt.Sprintf("enclave CPU usage %.1f%% above %.1f%%", sample.CPUUsage, e.maxCPUUsage)
+	}
+	needed := memoryKB + float64(e.minMemoryAvailableMB*1024)
+	if sample.MemoryAvailableKB < needed {
+		return fmt.Sprintf("enclave memory available %d MiB below the %d MiB needed",
+			int64(sample.MemoryAvailableKB/1024), int64(math.Ceil(needed/1024)))
+	}
+	return ""
+}
+
+// score is the weighted share of free enclave memory and CPU, from 0 to
+// MaxExtenderPriority.
+func (e *extender) score(sample *nodeSample) int64 {
+	memory := math.Max(0, math.Min(1, sample.MemoryAvailableKB/sample.MemoryTotalKB))
+	cpu := math.Max(0, math.Min(1, 1-sample.CPUUsage/100))
+	total := e.memoryWeight + e.cpuWeight
+	if total <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(extenderv1.MaxExtenderPriority) * (e.memoryWeight*memory + e.cpuWeight*cpu) / total))
+}
+
+func (e *extender) filter(r *http.Request, args *extenderv1.ExtenderArgs) *extenderv1.ExtenderFilterResult {
+	// The result holds nodes or names, as the arguments.
+	result := &extenderv1.ExtenderFilterResult{FailedNodes: extenderv1.FailedNodesMap{}}
+	if args.Nodes != nil {
+		result.Nodes = &corev1.NodeList{}
+	} else {
+		result.NodeNames = &[]string{}
+	}
+	keep := func(i int, name string) {
+		if args.Nodes != nil {
+			result.Nodes.Items = append(result.Nodes.Items, args.Nodes.Items[i])
+		} else {
+			*result.NodeNames = append(*result.NodeNames, name)
+		}
+	}
+
+	targets := nodeTargets(args)
+	enclaves, memoryKB := e.podDemand(args.Pod)
+	if !enclaves {
+		for i, t := range targets {
+			keep(i, t.Name)
+		}
+		return result
+	}
+
+	samples := e.freshSamples(r, targets)
+	for i, t := range targets {
+		if sample, ok := samples[t.Name]; ok {
+			if reason := e.rejection(sample, memoryKB); reason != "" {
+				result.FailedNodes[t.Name] = reason
+				continue
+			}
+		}
+		keep(i, t.Name)
+	}
+	if len(result.FailedNodes) > 0 {
+		glog.V(1).Infof("Filtered out %d nodes for pod %s/%s: %v", len(result.FailedNodes),
+			args.Pod.Namespace, args.Pod.Name, result.FailedNodes)
+	}
+	return result
+}
+
+func (e *extender) prioritize(r *http.Request, args *extenderv1.ExtenderArgs) *extenderv1.HostPriorityList {
+	targets := nodeTargets(args)
+	scores := make(extenderv1.HostPriorityList, 0, len(targets))
+	var samples map[string]*nodeSample
+	if enclaves, _ := e.podDemand(args.Pod); enclaves {
+		samples = e.freshSamples(r, targets)
+	}
+	for _, t := range targets {
+		score := int64(0)
+		if sample, ok := samples[t.Name]; ok {
+			score = e.score(sample)
+		}
+		scores = append(scores, extenderv1.HostPriority{Host: t.Name, Score: score})
+	}
+	return &scores
+}
+
+// decodeArgs reads the ExtenderArgs of a request, writing the error if it
+// fails.
+func decodeArgs(w http.ResponseWriter, r *http.Request) (*extenderv1.ExtenderArgs, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	var args extenderv1.ExtenderArgs
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxArgsSize)).Decode(&args); err != nil || args.Pod == nil {
+		http.Error(w, fmt.Sprintf("invalid ExtenderArgs: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	return &args, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		glog.Errorf("Failed to write response: %v", err)
+	}
+}
+
+// newServeMux routes the verbs of the extender.
+func (e *extender) newServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/filter", func(w http.ResponseWriter, r *http.Request) {
+		if args, ok := decodeArgs(w, r); ok {
+			writeJSON(w, e.filter(r, args))
+		}
+	})
+	mux.HandleFunc("/prioritize", func(w http.ResponseWriter, r *http.Request) {
+		if args, ok := decodeArgs(w, r); ok {
+			writeJSON(w, e.prioritize(r, args))
+		}
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	return mux
+}
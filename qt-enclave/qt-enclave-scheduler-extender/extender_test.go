@@ -0,0 +1,289 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the filter and prioritize verbs of the scheduler extender testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	extenderv1 "k8s.io/kube-scheduler/extender/v1"
+)
+
+// fakeExporters serves testdata/<file> on /<node>/metrics.
+type fakeExporters struct {
+	mu    sync.Mutex
+	files map[string]string
+	reads map[string]int
+}
+
+func (f *fakeExporters) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	node := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/metrics")
+	f.mu.Lock()
+	file, ok := f.files[node]
+	f.reads[node]++
+	f.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	data, _ := os.ReadFile("testdata/" + file)
+	w.Write(data)
+}
+
+func (f *fakeExporters) set(node, file string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[node] = file
+}
+
+func (f *fakeExporters) readCount(node string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reads[node]
+}
+
+// newTestExtender returns an extender reading the fake exporters, keyed by
+// node, at testNow.
+func newTestExtender(t *testing.T, files map[string]string) (*extender, *fakeExporters, *time.Time) {
+	exporters := &fakeExporters{files: files, reads: map[string]int{}}
+	srv := httptest.NewServer(exporters)
+	t.Cleanup(srv.Close)
+
+	s, err := newScraper(srv.URL+"/{node}/metrics", "", "", time.Second, defaultCacheTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := testNow
+	s.now = func() time.Time { return now }
+	return &extender{
+		scraper:            s,
+		resourceName:       defaultResourceName,
+		memoryResourceName: defaultMemoryResourceName,
+		memoryUnitMB:       defaultMemoryUnitMB,
+		maxCPUUsage:        defaultMaxCPUUsage,
+		maxAge:             defaultMaxAge,
+		memoryWeight:       1,
+		cpuWeight:          1,
+	}, exporters, &now
+}
+
+// enclavePod requests enclaves and units of enclave memory.
+func enclavePod(enclaves, memoryUnits int64) *corev1.Pod {
+	limits := corev1.ResourceList{defaultResourceName: *resource.NewQuantity(enclaves, resource.DecimalSI)}
+	if memoryUnits > 0 {
+		limits[defaultMemoryResourceName] = *resource.NewQuantity(memoryUnits, resource.DecimalSI)
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:      "app",
+			Resources: corev1.ResourceRequirements{Limits: limits},
+		}}},
+	}
+}
+
+func nodeNamesArgs(pod *corev1.Pod, names ...string) *extenderv1.ExtenderArgs {
+	return &extenderv1.ExtenderArgs{Pod: pod, NodeNames: &names}
+}
+
+func TestFilter(t *testing.T) {
+	e, _, _ := newTestExtender(t, map[string]string{
+		"idle": "idle.txt", "busy": "busy.txt", "hot": "hot.txt", "stale": "stale.txt",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/filter", nil)
+
+	// 12 units of 256 MiB are 3 GiB, more than the 2 GiB available on busy.
+	result := e.filter(req, nodeNamesArgs(enclavePod(1, 12), "idle", "busy", "hot", "stale", "unreachable"))
+	if got := strings.Join(*result.NodeNames, ","); got != "idle,stale,unreachable" {
+		t.Fatalf("Expected the idle node and the nodes without fresh metrics, got %s", got)
+	}
+	if result.FailedNodes["hot"] != "enclave CPU usage 95.2% above 90.0%" ||
+		result.FailedNodes["busy"] != "enclave memory available 2048 MiB below the 3072 MiB needed" {
+		t.Fatalf("Unexpected failed nodes %v", result.FailedNodes)
+	}
+	if result.Nodes != nil {
+		t.Fatal("Result should hold names as the arguments")
+	}
+
+	// The memory floor applies on top of the pod request.
+	e.minMemoryAvailableMB = 3000
+	result = e.filter(req, nodeNamesArgs(enclavePod(1, 0), "busy"))
+	if len(*result.NodeNames) != 0 || !strings.Contains(result.FailedNodes["busy"], "below the 3000 MiB needed") {
+		t.Fatalf("Memory floor should filter busy out, got %+v", result)
+	}
+
+	// Pods without enclaves are not filtered.
+	pod := enclavePod(1, 0)
+	pod.Spec.Containers[0].Resources = corev1.ResourceRequirements{}
+	if result := e.filter(req, nodeNamesArgs(pod, "hot", "busy")); len(*result.NodeNames) != 2 {
+		t.Fatalf("Pod without enclaves should pass every node, got %+v", result)
+	}
+}
+
+func TestPodDemandInitContainers(t *testing.T) {
+	e, _, _ := newTestExtender(t, nil)
+	units := func(n int64) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{Limits: corev1.ResourceList{
+			defaultMemoryResourceName: *resource.NewQuantity(n, resource.DecimalSI),
+		}}
+	}
+	unitKB := float64(defaultMemoryUnitMB * 1024)
+
+	// Init containers run before the containers, the larger demand counts.
+	pod := enclavePod(1, 2)
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "sidecar", Resources: units(1)})
+	pod.Spec.InitContainers = []corev1.Container{{Name: "init", Resources: units(2)}}
+	if uses, memory := e.podDemand(pod); !uses || memory != 3*unitKB {
+		t.Fatalf("Expected the containers demand of 3 units, got %v %v", uses, memory)
+	}
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, corev1.Container{Name: "seed", Resources: units(12)})
+	if uses, memory := e.podDemand(pod); !uses || memory != 12*unitKB {
+		t.Fatalf("Expected the init container demand of 12 units, got %v %v", uses, memory)
+	}
+
+	// A pod using enclaves in its init containers only is filtered too.
+	pod = enclavePod(1, 0)
+	pod.Spec.InitContainers = pod.Spec.Containers
+	pod.Spec.Containers = []corev1.Container{{Name: "app"}}
+	if uses, _ := e.podDemand(pod); !uses {
+		t.Fatal("Pod with enclaves in an init container should use enclaves")
+	}
+}
+
+func TestPrioritize(t *testing.T) {
+	e, _, _ := newTestExtender(t, map[string]string{"idle": "idle.txt", "busy": "busy.txt", "stale": "stale.txt"})
+	req := httptest.NewRequest(http.MethodPost, "/prioritize", nil)
+
+	scores := map[string]int64{}
+	for _, p := range *e.prioritize(req, nodeNamesArgs(enclavePod(1, 0), "idle", "busy", "stale")) {
+		scores[p.Host] = p.Score
+	}
+	// idle: (0.75 + 0.895) / 2, busy: (0.25 + 0.4) / 2, stale is ignored.
+	if scores["idle"] != 8 || scores["busy"] != 3 || scores["stale"] != 0 {
+		t.Fatalf("Unexpected scores %v", scores)
+	}
+
+	e.cpuWeight = 0
+	for _, p := range *e.prioritize(req, nodeNamesArgs(enclavePod(1, 0), "busy")) {
+		if p.Score != 3 {
+			t.Fatalf("Memory only score of busy should be 3, got %d", p.Score)
+		}
+	}
+}
+
+func TestScrapeCache(t *testing.T) {
+	e, exporters, now := newTestExtender(t, map[string]string{"idle": "idle.txt"})
+	req := httptest.NewRequest(http.MethodPost, "/prioritize", nil)
+	args := nodeNamesArgs(enclavePod(1, 0), "idle")
+
+	e.prioritize(req, args)
+	e.prioritize(req, args)
+	if n := exporters.readCount("idle"); n != 1 {
+		t.Fatalf("Metrics should be read once per TTL, got %d reads", n)
+	}
+
+	// The exporter goes away, the cached sample is used until it is stale.
+	exporters.set("idle", "missing.txt")
+	*now = now.Add(defaultCacheTTL)
+	if score := (*e.prioritize(req, args))[0].Score; score != 8 || exporters.readCount("idle") != 2 {
+		t.Fatalf("Cached sample should be used after a failed read, got %d", score)
+	}
+	*now = testNow.Add(defaultMaxAge + time.Second)
+	if score := (*e.prioritize(req, args))[0].Score; score != 0 {
+		t.Fatalf("Stale sample should be ignored, got %d", score)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	var reads int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads++
+		data, _ := os.ReadFile("testdata/aggregate.txt")
+		w.Write(data)
+	}))
+	defer srv.Close()
+	s, err := newScraper("", srv.URL, defaultAggregateNodeLabel, time.Second, defaultCacheTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return testNow }
+	e := &extender{scraper: s, resourceName: defaultResourceName, maxCPUUsage: defaultMaxCPUUsage, maxAge: defaultMaxAge}
+
+	req := httptest.NewRequest(http.MethodPost, "/filter", nil)
+	result := e.filter(req, nodeNamesArgs(enclavePod(1, 0), "node-a", "node-b", "node-c"))
+	if got := strings.Join(*result.NodeNames, ","); got != "node-a,node-c" || reads != 1 {
+		t.Fatalf("Expected node-a and node-c after one read, got %s after %d", got, reads)
+	}
+}
+
+func TestServeExtender(t *testing.T) {
+	e, _, _ := newTestExtender(t, map[string]string{"10.0.0.1": "idle.txt", "10.0.0.2": "hot.txt"})
+	// Node objects are read on their internal IP.
+	e.scraper.urlTemplate = strings.Replace(e.scraper.urlTemplate, "{node}", "{address}", 1)
+	srv := httptest.NewServer(e.newServeMux())
+	defer srv.Close()
+
+	node := func(name, ip string) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: ip}}},
+		}
+	}
+	body, _ := json.Marshal(extenderv1.ExtenderArgs{
+		Pod:   enclavePod(1, 0),
+		Nodes: &corev1.NodeList{Items: []corev1.Node{node("node-a", "10.0.0.1"), node("node-b", "10.0.0.2")}},
+	})
+
+	resp, err := http.Post(srv.URL+"/filter", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result extenderv1.ExtenderFilterResult
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if result.Nodes == nil || len(result.Nodes.Items) != 1 || result.Nodes.Items[0].Name != "node-a" ||
+		result.FailedNodes["node-b"] == "" {
+		t.Fatalf("Unexpected filter result %+v", result)
+	}
+
+	resp, err = http.Post(srv.URL+"/prioritize", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scores extenderv1.HostPriorityList
+	json.NewDecoder(resp.Body).Decode(&scores)
+	resp.Body.Close()
+	if len(scores) != 2 || scores[0].Host != "node-a" || scores[0].Score <= scores[1].Score {
+		t.Fatalf("Unexpected scores %+v", scores)
+	}
+
+	if resp, _ := http.Get(srv.URL + "/filter"); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405, got %s", resp.Status)
+	}
+	if resp, _ := http.Post(srv.URL+"/filter", "application/json", strings.NewReader("{}")); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Arguments without pod should be rejected, got %s", resp.Status)
+	}
+}
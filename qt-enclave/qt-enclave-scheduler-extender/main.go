@@ -0,0 +1,85 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the scheduler extender of qt enclave pods
+ *********************************************************************************/
+
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	listenAddr         = flag.String("listen", ":8888", "address of the extender HTTP server")
+	metricsURLTemplate = flag.String("metrics-url-template", defaultMetricsURLTemplate, "metrics URL of the exporter of a node, may use {address} and {node}")
+	aggregateURL       = flag.String("aggregate-url", "", "metrics URL of an aggregate of every exporter, used instead of the nodes")
+	aggregateNodeLabel = flag.String("aggregate-node-label", defaultAggregateNodeLabel, "label naming the node of the aggregate samples")
+	scrapeTimeout      = flag.Duration("scrape-timeout", defaultScrapeTimeout, "timeout of a metrics read")
+	cacheTTL           = flag.Duration("cache-ttl", defaultCacheTTL, "interval between two reads of the metrics of a node")
+	maxAge             = flag.Duration("max-age", defaultMaxAge, "age above which metrics are stale and ignored")
+	maxCPUUsage        = flag.Float64("max-cpu-usage", defaultMaxCPUUsage, "enclave CPU usage percentage above which nodes are filtered out")
+	minMemoryAvailable = flag.Int64("min-memory-available-mb", 0, "enclave memory in MiB nodes keep available on top of the pod request")
+	memoryUnitMB       = flag.Int64("memory-unit-mb", defaultMemoryUnitMB, "MiB of enclave memory per "+defaultMemoryResourceName+" unit")
+	memoryWeight       = flag.Float64("memory-weight", 1, "weight of the available enclave memory in the node score")
+	cpuWeight          = flag.Float64("cpu-weight", 1, "weight of the idle enclave CPU in the node score")
+	resourceName       = flag.String("resource-name", defaultResourceName, "extended resource of the enclaves")
+	memoryResourceName = flag.String("memory-resource-name", defaultMemoryResourceName, "extended resource of the enclave memory")
+)
+
+func main() {
+	flag.Parse()
+
+	glog.V(0).Info("Loading qt enclave scheduler extender...")
+
+	if *maxAge < *cacheTTL {
+		glog.Errorf("-max-age %s is below -cache-ttl %s, every cached sample would be stale", *maxAge, *cacheTTL)
+		os.Exit(1)
+	}
+	if *memoryWeight < 0 || *cpuWeight < 0 || *memoryUnitMB <= 0 {
+		glog.Error("weights must not be negative and -memory-unit-mb must be positive")
+		os.Exit(1)
+	}
+	s, err := newScraper(*metricsURLTemplate, *aggregateURL, *aggregateNodeLabel, *scrapeTimeout, *cacheTTL)
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+	e := &extender{
+		scraper:              s,
+		resourceName:         corev1.ResourceName(*resourceName),
+		memoryResourceName:   corev1.ResourceName(*memoryResourceName),
+		memoryUnitMB:         *memoryUnitMB,
+		maxCPUUsage:          *maxCPUUsage,
+		minMemoryAvailableMB: *minMemoryAvailable,
+		maxAge:               *maxAge,
+		memoryWeight:         *memoryWeight,
+		cpuWeight:            *cpuWeight,
+	}
+
+	server := &http.Server{
+		Addr:              *listenAddr,
+		Handler:           e.newServeMux(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	glog.V(0).Infof("Serving on %s", *listenAddr)
+	if err := server.ListenAndServe(); err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,137 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the parsing of the qt-enclave-exporter metrics
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Metrics of qt-enclave-exporter, memory is in kB.
+const (
+	metricCPUUsage        = "qingtian_cpu_usage_percent"
+	metricMemoryTotal     = "qingtian_memory_total"
+	metricMemoryAvailable = "qingtian_memory_available"
+	metricLastUpdate      = "qingtian_last_update_timestamp_seconds"
+)
+
+// nodeSample is the enclave load of a node.
+type nodeSample struct {
+	CPUUsage          float64
+	MemoryTotalKB     float64
+	MemoryAvailableKB float64
+	// Updated is the time of the qlog sample, zero for exporters without
+	// metricLastUpdate.
+	Updated time.Time
+	// Scraped is the time the metrics were read.
+	Scraped time.Time
+
+	// seen records the metrics found, a sample needs the CPU and memory.
+	seen map[string]bool
+}
+
+// fresh says if the sample is complete and no older than maxAge.
+func (s *nodeSample) fresh(now time.Time, maxAge time.Duration) bool {
+	if !s.seen[metricCPUUsage] || !s.seen[metricMemoryTotal] || !s.seen[metricMemoryAvailable] || s.MemoryTotalKB <= 0 {
+		return false
+	}
+	if now.Sub(s.Scraped) > maxAge {
+		return false
+	}
+	// The exporter reports 0 until it reads the first qlog sample.
+	if s.seen[metricLastUpdate] && (s.Updated.IsZero() || now.Sub(s.Updated) > maxAge) {
+		return false
+	}
+	return true
+}
+
+// parseExporterMetrics reads the samples of the Prometheus text format in r.
+// With nodeLabel, as in an aggregate of several exporters, the samples are
+// keyed by the value of that label; without, they are keyed by "".
+func parseExporterMetrics(r io.Reader, nodeLabel string, scraped time.Time) (map[string]*nodeSample, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics: %v", err)
+	}
+
+	samples := map[string]*nodeSample{}
+	for _, name := range []string{metricCPUUsage, metricMemoryTotal, metricMemoryAvailable, metricLastUpdate} {
+		family, ok := families[name]
+		if !ok {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			node := ""
+			if nodeLabel != "" {
+				if node = labelValue(m, nodeLabel); node == "" {
+					continue
+				}
+			}
+			value, ok := metricValue(m)
+			if !ok {
+				continue
+			}
+			s, ok := samples[node]
+			if !ok {
+				s = &nodeSample{Scraped: scraped, seen: map[string]bool{}}
+				samples[node] = s
+			}
+			s.seen[name] = true
+			switch name {
+			case metricCPUUsage:
+				s.CPUUsage = value
+			case metricMemoryTotal:
+				s.MemoryTotalKB = value
+			case metricMemoryAvailable:
+				s.MemoryAvailableKB = value
+			case metricLastUpdate:
+				if value > 0 {
+					sec, frac := math.Modf(value)
+					s.Updated = time.Unix(int64(sec), int64(frac*1e9))
+				}
+			}
+		}
+	}
+	return samples, nil
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+func metricValue(m *dto.Metric) (float64, bool) {
+	var v float64
+	switch {
+	case m.Gauge != nil:
+		v = m.GetGauge().GetValue()
+	case m.Untyped != nil:
+		v = m.GetUntyped().GetValue()
+	default:
+		return 0, false
+	}
+	return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+}
@@ -0,0 +1,85 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the parsing of the qt-enclave-exporter metrics testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNow is 10 seconds after the qlog samples of testdata.
+var testNow = time.Unix(1792400010, 0)
+
+func parseTestMetrics(t *testing.T, file, nodeLabel string) map[string]*nodeSample {
+	f, err := os.Open("testdata/" + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	samples, err := parseExporterMetrics(f, nodeLabel, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func TestParseExporterMetrics(t *testing.T) {
+	s := parseTestMetrics(t, "idle.txt", "")[""]
+	if s == nil || s.CPUUsage != 10.5 || s.MemoryTotalKB != 8388608 || s.MemoryAvailableKB != 6291456 ||
+		!s.Updated.Equal(time.Unix(1792400000, 0)) {
+		t.Fatalf("Unexpected sample %+v", s)
+	}
+	if !s.fresh(testNow, time.Minute) {
+		t.Fatal("Sample of 10 seconds should be fresh")
+	}
+	if s.fresh(testNow.Add(time.Minute), time.Minute) {
+		t.Fatal("Sample read a minute ago should be stale")
+	}
+
+	if s := parseTestMetrics(t, "stale.txt", "")[""]; s.fresh(testNow, time.Minute) {
+		t.Fatal("Sample with an old qlog update should be stale")
+	}
+
+	samples := parseTestMetrics(t, "aggregate.txt", "node")
+	if len(samples) != 2 || samples["node-b"].CPUUsage != 95.2 || samples["node-a"].MemoryAvailableKB != 6291456 {
+		t.Fatalf("Unexpected aggregate samples %+v", samples)
+	}
+	// Without the update time, the read time decides.
+	if !samples["node-a"].fresh(testNow, time.Minute) {
+		t.Fatal("Aggregate sample without update time should be fresh")
+	}
+}
+
+func TestParseExporterMetricsIncomplete(t *testing.T) {
+	for _, text := range []string{
+		"qingtian_cpu_usage_percent 10\nqingtian_memory_total 100\n",
+		"qingtian_cpu_usage_percent 10\nqingtian_memory_total 0\nqingtian_memory_available 0\n",
+		"qingtian_cpu_usage_percent 10\nqingtian_memory_total 100\nqingtian_memory_available 50\nqingtian_last_update_timestamp_seconds 0\n",
+	} {
+		samples, err := parseExporterMetrics(strings.NewReader(text), "", testNow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if samples[""].fresh(testNow, time.Minute) {
+			t.Fatalf("Incomplete sample should not be used: %q", text)
+		}
+	}
+	if _, err := parseExporterMetrics(strings.NewReader("qingtian_cpu_usage_percent{ 1\n"), "", testNow); err == nil {
+		t.Fatal("Invalid metrics should fail")
+	}
+}
@@ -0,0 +1,179 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the cached scraping of the node metrics
+ *********************************************************************************/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	defaultMetricsURLTemplate = "http://{address}:9113/metrics"
+	defaultAggregateNodeLabel = "node"
+	defaultScrapeTimeout      = 2 * time.Second
+	defaultCacheTTL           = 10 * time.Second
+	// maxMetricsSize bounds a metrics response, an aggregate of a large
+	// cluster included.
+	maxMetricsSize = 16 * 1024 * 1024
+	// aggregateKey is the fetch time key of the aggregate.
+	aggregateKey = ""
+)
+
+// nodeTarget is a node to score and the address its exporter listens on.
+type nodeTarget struct {
+	Name    string
+	Address string
+}
+
+// scraper reads the exporter metrics of each node, or an aggregate of them
+// when aggregateURL is set, at most once per ttl. The last sample read
+// stays cached when a read fails, until it is stale.
+type scraper struct {
+	client       *http.Client
+	urlTemplate  string
+	aggregateURL string
+	nodeLabel    string
+	ttl          time.Duration
+	now          func() time.Time
+
+	mu      sync.Mutex
+	samples map[string]*nodeSample
+	fetched map[string]time.Time
+}
+
+func newScraper(urlTemplate, aggregateURL, nodeLabel string, timeout, ttl time.Duration) (*scraper, error) {
+	for _, u := range []string{urlTemplate, aggregateURL} {
+		if u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+			return nil, fmt.Errorf("invalid metrics URL %q, expected an http:// or https:// URL", u)
+		}
+	}
+	if aggregateURL == "" && urlTemplate == "" {
+		return nil, fmt.Errorf("no metrics URL configured")
+	}
+	if aggregateURL != "" && nodeLabel == "" {
+		return nil, fmt.Errorf("the aggregate needs the label naming the nodes")
+	}
+	return &scraper{
+		client:       &http.Client{Timeout: timeout},
+		urlTemplate:  urlTemplate,
+		aggregateURL: aggregateURL,
+		nodeLabel:    nodeLabel,
+		ttl:          ttl,
+		now:          time.Now,
+		samples:      map[string]*nodeSample{},
+		fetched:      map[string]time.Time{},
+	}, nil
+}
+
+// nodeURL returns the metrics URL of a node.
+func (s *scraper) nodeURL(t nodeTarget) string {
+	return strings.NewReplacer("{address}", t.Address, "{node}", t.Name).Replace(s.urlTemplate)
+}
+
+// due marks the keys not fetched for ttl as fetched now and returns them, so
+// concurrent calls do not read the same metrics twice.
+func (s *scraper) due(keys []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var due []string
+	for _, key := range keys {
+		if last, ok := s.fetched[key]; !ok || now.Sub(last) >= s.ttl {
+			s.fetched[key] = now
+			due = append(due, key)
+		}
+	}
+	return due
+}
+
+func (s *scraper) store(samples map[string]*nodeSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for node, sample := range samples {
+		s.samples[node] = sample
+	}
+}
+
+// collect returns the cached samples of the targets after refreshing the
+// due ones. Targets without a sample are missing from the result.
+func (s *scraper) collect(ctx context.Context, targets []nodeTarget) map[string]*nodeSample {
+	if s.aggregateURL != "" {
+		if len(s.due([]string{aggregateKey})) > 0 {
+			if samples, err := s.fetch(ctx, s.aggregateURL, s.nodeLabel); err != nil {
+				glog.Warningf("Failed to read the metrics aggregate: %v", err)
+			} else {
+				s.store(samples)
+			}
+		}
+	} else {
+		byName := map[string]nodeTarget{}
+		names := make([]string, 0, len(targets))
+		for _, t := range targets {
+			byName[t.Name] = t
+			names = append(names, t.Name)
+		}
+		var wg sync.WaitGroup
+		for _, name := range s.due(names) {
+			wg.Add(1)
+			go func(t nodeTarget) {
+				defer wg.Done()
+				samples, err := s.fetch(ctx, s.nodeURL(t), "")
+				if err != nil {
+					glog.V(1).Infof("Failed to read the metrics of node %s: %v", t.Name, err)
+					return
+				}
+				if sample, ok := samples[""]; ok {
+					s.store(map[string]*nodeSample{t.Name: sample})
+				}
+			}(byName[name])
+		}
+		wg.Wait()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := map[string]*nodeSample{}
+	for _, t := range targets {
+		if sample, ok := s.samples[t.Name]; ok {
+			result[t.Name] = sample
+		}
+	}
+	return result
+}
+
+func (s *scraper) fetch(ctx context.Context, url, nodeLabel string) (map[string]*nodeSample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return parseExporterMetrics(io.LimitReader(resp.Body, maxMetricsSize), nodeLabel, s.now())
+}
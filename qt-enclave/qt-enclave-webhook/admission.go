@@ -0,0 +1,112 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the AdmissionReview handling of the webhooks
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/golang/glog"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxReviewSize bounds the AdmissionReview bodies, the API server sends
+// at most a few MiB.
+const maxReviewSize = 8 * 1024 * 1024
+
+// admitFunc decides on an admission request.
+type admitFunc func(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// serveAdmission decodes AdmissionReview requests, passes them to admit and
+// encodes its response.
+func serveAdmission(admit admitFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || t != "application/json" {
+			http.Error(w, "expected application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxReviewSize+1))
+		if err != nil || len(body) > maxReviewSize {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		var review admissionv1.AdmissionReview
+		if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+			http.Error(w, fmt.Sprintf("invalid AdmissionReview: %v", err), http.StatusBadRequest)
+			return
+		}
+		if review.APIVersion != admissionv1.SchemeGroupVersion.String() {
+			http.Error(w, "unsupported AdmissionReview version "+review.APIVersion, http.StatusBadRequest)
+			return
+		}
+
+		resp := admit(review.Request)
+		resp.UID = review.Request.UID
+		review.Response = resp
+		review.Request = nil
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			glog.Errorf("Failed to write AdmissionReview response: %v", err)
+		}
+	}
+}
+
+// decodePod returns the pod of a request on pods.
+func decodePod(req *admissionv1.AdmissionRequest) (*corev1.Pod, error) {
+	if req.Kind.Group != "" || req.Kind.Kind != "Pod" {
+		return nil, fmt.Errorf("expected a Pod, got %s", req.Kind.String())
+	}
+	var pod corev1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		return nil, fmt.Errorf("invalid pod: %v", err)
+	}
+	return &pod, nil
+}
+
+// podName names a pod in logs, pods created by a controller have no name
+// yet.
+func podName(req *admissionv1.AdmissionRequest, pod *corev1.Pod) string {
+	name := pod.Name
+	if name == "" {
+		name = pod.GenerateName + "*"
+	}
+	return req.Namespace + "/" + name
+}
+
+func allowed() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func denied(code int32, format string, args ...interface{}) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+		},
+	}
+}
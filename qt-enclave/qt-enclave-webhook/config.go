@@ -0,0 +1,285 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the configuration of the webhooks
+ *********************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultResourceName  = "huawei.com/qt_enclaves"
+	defaultQlogHostPath  = "/var/log/qlog"
+	defaultQlogMountPath = "/var/log/qlog"
+	defaultExporterImage = "qt-enclave-exporter:latest"
+	defaultExporterPort  = 9113
+)
+
+// injectionConfig says how pods are wired up for enclaves. Unset fields
+// of a namespace take the value of the defaults.
+type injectionConfig struct {
+	// Enabled allows injection in the namespace.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Enclaves is the number of enclaves of a pod without the enclaves
+	// annotation.
+	Enclaves *int            `json:"enclaves,omitempty"`
+	Qlog     *qlogConfig     `json:"qlog,omitempty"`
+	Exporter *exporterConfig `json:"exporter,omitempty"`
+}
+
+// qlogConfig is the host directory of the qlog files, mounted in the
+// enclave container and the exporter.
+type qlogConfig struct {
+	Enabled   *bool  `json:"enabled,omitempty"`
+	HostPath  string `json:"hostPath,omitempty"`
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// exporterConfig is the qt-enclave-exporter sidecar.
+type exporterConfig struct {
+	Enabled *bool  `json:"enabled,omitempty"`
+	Image   string `json:"image,omitempty"`
+	Port    int    `json:"port,omitempty"`
+}
+
+// validationConfig holds the rules of the validating webhook, checked on
+// the pods requesting enclaves. Zero values disable a rule.
+type validationConfig struct {
+	// AllowedNamespaces are the namespaces allowed to use enclaves, empty
+	// allows every namespace.
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+	MaxEnclavesPerPod int      `json:"maxEnclavesPerPod,omitempty"`
+	// MaxEnclavesPerNamespace bounds the enclaves of the running pods of a
+	// namespace, NamespaceLimits overrides it for some namespaces. It is a
+	// soft limit: pods created concurrently do not see each other and may
+	// exceed it together. A ResourceQuota on
+	// requests.huawei.com/qt_enclaves is the strict alternative.
+	MaxEnclavesPerNamespace int            `json:"maxEnclavesPerNamespace,omitempty"`
+	NamespaceLimits         map[string]int `json:"namespaceLimits,omitempty"`
+	// RequiredAnnotations maps the annotations pods must have to a regular
+	// expression their whole value must match, empty for any value.
+	RequiredAnnotations map[string]string `json:"requiredAnnotations,omitempty"`
+	AllowPrivileged     bool              `json:"allowPrivileged,omitempty"`
+
+	patterns map[string]*regexp.Regexp
+}
+
+// webhookConfig is the configuration file of the webhook server.
+type webhookConfig struct {
+	ResourceName string                     `json:"resourceName,omitempty"`
+	Defaults     injectionConfig            `json:"defaults,omitempty"`
+	Namespaces   map[string]injectionConfig `json:"namespaces,omitempty"`
+	Validation   validationConfig           `json:"validation,omitempty"`
+}
+
+// effectiveInjection is the injection configuration of a namespace with
+// every field set.
+type effectiveInjection struct {
+	Enabled       bool
+	Enclaves      int
+	Qlog          bool
+	QlogHostPath  string
+	QlogMountPath string
+	Exporter      bool
+	ExporterImage string
+	ExporterPort  int
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// defaultWebhookConfig injects one enclave and the qlog mount, without the
+// exporter, in every namespace.
+func defaultWebhookConfig() *webhookConfig {
+	return &webhookConfig{
+		ResourceName: defaultResourceName,
+		Defaults: injectionConfig{
+			Enabled:  boolPtr(true),
+			Enclaves: intPtr(1),
+			Qlog:     &qlogConfig{Enabled: boolPtr(true), HostPath: defaultQlogHostPath, MountPath: defaultQlogMountPath},
+			Exporter: &exporterConfig{Enabled: boolPtr(false), Image: defaultExporterImage, Port: defaultExporterPort},
+		},
+	}
+}
+
+// loadWebhookConfig reads a YAML or JSON configuration file over the
+// defaults. An empty path returns the defaults.
+func loadWebhookConfig(path string) (*webhookConfig, error) {
+	config := defaultWebhookConfig()
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file webhookConfig
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+	if file.ResourceName != "" {
+		config.ResourceName = file.ResourceName
+	}
+	config.Defaults = mergeInjection(config.Defaults, file.Defaults)
+	config.Namespaces = file.Namespaces
+	config.Validation = file.Validation
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// mergeInjection returns base with the fields set in override.
+func mergeInjection(base, override injectionConfig) injectionConfig {
+	if override.Enabled != nil {
+		base.Enabled = override.Enabled
+	}
+	if override.Enclaves != nil {
+		base.Enclaves = override.Enclaves
+	}
+	if o := override.Qlog; o != nil {
+		q := qlogConfig{}
+		if base.Qlog != nil {
+			q = *base.Qlog
+		}
+		if o.Enabled != nil {
+			q.Enabled = o.Enabled
+		}
+		if o.HostPath != "" {
+			q.HostPath = o.HostPath
+		}
+		if o.MountPath != "" {
+			q.MountPath = o.MountPath
+		}
+		base.Qlog = &q
+	}
+	if o := override.Exporter; o != nil {
+		e := exporterConfig{}
+		if base.Exporter != nil {
+			e = *base.Exporter
+		}
+		if o.Enabled != nil {
+			e.Enabled = o.Enabled
+		}
+		if o.Image != "" {
+			e.Image = o.Image
+		}
+		if o.Port != 0 {
+			e.Port = o.Port
+		}
+		base.Exporter = &e
+	}
+	return base
+}
+
+func (c *webhookConfig) validate() error {
+	for _, ns := range append([]string{""}, namespaceNames(c.Namespaces)...) {
+		i := c.injection(ns)
+		if i.Enclaves < 1 {
+			return fmt.Errorf("namespace %q: enclaves must be at least 1", ns)
+		}
+		if i.Qlog && (!filepath.IsAbs(i.QlogHostPath) || !filepath.IsAbs(i.QlogMountPath)) {
+			return fmt.Errorf("namespace %q: qlog paths must be absolute", ns)
+		}
+		if i.Exporter && (i.ExporterImage == "" || i.ExporterPort < 1 || i.ExporterPort > 65535) {
+			return fmt.Errorf("namespace %q: exporter needs an image and a valid port", ns)
+		}
+	}
+	return c.Validation.compile()
+}
+
+// compile checks the limits and compiles the annotation patterns.
+func (v *validationConfig) compile() error {
+	if v.MaxEnclavesPerPod < 0 || v.MaxEnclavesPerNamespace < 0 {
+		return fmt.Errorf("validation: enclave limits must not be negative")
+	}
+	for ns, limit := range v.NamespaceLimits {
+		if limit < 0 {
+			return fmt.Errorf("validation: enclave limit of namespace %q must not be negative", ns)
+		}
+	}
+	v.patterns = map[string]*regexp.Regexp{}
+	for name, pattern := range v.RequiredAnnotations {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("validation: invalid pattern of annotation %s: %v", name, err)
+		}
+		v.patterns[name] = re
+	}
+	return nil
+}
+
+// namespaceLimit returns the enclave limit of a namespace, 0 for none.
+func (v *validationConfig) namespaceLimit(namespace string) int {
+	if limit, ok := v.NamespaceLimits[namespace]; ok {
+		return limit
+	}
+	return v.MaxEnclavesPerNamespace
+}
+
+// countsNamespaces says if the namespace limits need the pods of the
+// namespaces.
+func (v *validationConfig) countsNamespaces() bool {
+	if v.MaxEnclavesPerNamespace > 0 {
+		return true
+	}
+	for _, limit := range v.NamespaceLimits {
+		if limit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func namespaceNames(namespaces map[string]injectionConfig) []string {
+	names := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		names = append(names, ns)
+	}
+	return names
+}
+
+// injection returns the injection configuration of a namespace.
+func (c *webhookConfig) injection(namespace string) effectiveInjection {
+	merged := c.Defaults
+	if override, ok := c.Namespaces[namespace]; ok {
+		merged = mergeInjection(merged, override)
+	}
+
+	i := effectiveInjection{Enabled: merged.Enabled == nil || *merged.Enabled, Enclaves: 1}
+	if merged.Enclaves != nil {
+		i.Enclaves = *merged.Enclaves
+	}
+	if q := merged.Qlog; q != nil {
+		i.Qlog = q.Enabled == nil || *q.Enabled
+		i.QlogHostPath, i.QlogMountPath = q.HostPath, q.MountPath
+	}
+	if e := merged.Exporter; e != nil {
+		i.Exporter = e.Enabled != nil && *e.Enabled
+		i.ExporterImage, i.ExporterPort = e.Image, e.Port
+	}
+	return i
+}
@@ -0,0 +1,72 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the configuration of the webhooks testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadWebhookConfig(t *testing.T) {
+	config, err := loadWebhookConfig("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := config.injection("monitoring")
+	if !i.Enabled || i.Enclaves != 2 || !i.Qlog || i.QlogHostPath != defaultQlogHostPath ||
+		!i.Exporter || i.ExporterPort != defaultExporterPort {
+		t.Fatalf("Unexpected monitoring injection %+v", i)
+	}
+	if i := config.injection("other"); !i.Enabled || i.Enclaves != 1 || !i.Qlog || i.Exporter {
+		t.Fatalf("Unexpected default injection %+v", i)
+	}
+	if i := config.injection("untrusted"); i.Enabled {
+		t.Fatal("Injection should be disabled in untrusted")
+	}
+
+	if v := config.Validation; !v.countsNamespaces() || v.namespaceLimit("monitoring") != 2 || v.namespaceLimit("other") != 6 {
+		t.Fatalf("Unexpected namespace limits %+v", v)
+	}
+
+	config, err = loadWebhookConfig("")
+	if err != nil || config.ResourceName != defaultResourceName || !config.injection("any").Enabled {
+		t.Fatalf("Unexpected built-in defaults %+v: %v", config, err)
+	}
+}
+
+func TestLoadWebhookConfigInvalid(t *testing.T) {
+	for content, message := range map[string]string{
+		"defaults:\n  enclaves: 0\n":                                                 "enclaves must be at least 1",
+		"namespaces:\n  a:\n    qlog:\n      hostPath: log\n":                        "must be absolute",
+		"namespaces:\n  a:\n    exporter:\n      enabled: true\n      port: 70000\n": "valid port",
+		"defaults:\n  enclave: 2\n":                                                  "unknown field",
+	} {
+		if _, err := loadWebhookConfig(writeTestConfig(t, content)); err == nil || !strings.Contains(err.Error(), message) {
+			t.Fatalf("%q should fail with %q, got %v", content, message, err)
+		}
+	}
+}
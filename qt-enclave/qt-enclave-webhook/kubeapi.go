@@ -0,0 +1,84 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the API server client listing the pods of a namespace
+ *********************************************************************************/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultKubeTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultKubeCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	// kubeTimeout stays below the 10s default timeout of the webhook call.
+	kubeTimeout = 5 * time.Second
+)
+
+// podLister lists the pods of a namespace.
+type podLister interface {
+	listPods(ctx context.Context, namespace string) ([]corev1.Pod, error)
+}
+
+// kubeClient calls the pod API of the API server with a service account
+// token. client-go reads the token file again when kubelet rotates it.
+type kubeClient struct {
+	client kubernetes.Interface
+}
+
+// newKubeClient returns a client of server, or of the API server of the
+// cluster the webhook runs in if server is empty, trusting the CA in caFile
+// or the system roots.
+func newKubeClient(server, tokenFile, caFile string) (*kubeClient, error) {
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("no API server configured and not running in a cluster")
+		}
+		server = "https://" + net.JoinHostPort(host, port)
+	}
+	if !strings.HasPrefix(server, "http://") && !strings.HasPrefix(server, "https://") {
+		return nil, fmt.Errorf("invalid API server %q, expected an http:// or https:// URL", server)
+	}
+
+	client, err := kubernetes.NewForConfig(&rest.Config{
+		Host:            server,
+		BearerTokenFile: tokenFile,
+		TLSClientConfig: rest.TLSClientConfig{CAFile: caFile},
+		Timeout:         kubeTimeout,
+		UserAgent:       "qt-enclave-webhook",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &kubeClient{client: client}, nil
+}
+
+func (c *kubeClient) listPods(ctx context.Context, namespace string) ([]corev1.Pod, error) {
+	list, err := c.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
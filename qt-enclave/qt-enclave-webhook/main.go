@@ -0,0 +1,90 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the admission webhooks of qt enclave pods
+ *********************************************************************************/
+
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+var (
+	listenAddr  = flag.String("listen", ":8443", "address of the HTTPS server")
+	tlsCertFile = flag.String("tls-cert-file", "", "serving certificate, reloaded when it changes")
+	tlsKeyFile  = flag.String("tls-key-file", "", "private key of the serving certificate")
+	configFile  = flag.String("config", "", "YAML configuration of the webhooks (default built-in defaults)")
+	kubeServer  = flag.String("kube-api-server", "", "API server listing the pods for the namespace limits (default the in-cluster API server)")
+	kubeToken   = flag.String("kube-token-file", defaultKubeTokenFile, "bearer token file used to call the API server")
+	kubeCA      = flag.String("kube-ca-file", defaultKubeCAFile, "CA certificate of the API server, empty uses the system roots")
+)
+
+// newServeMux routes the webhooks. pods may be nil without namespace
+// limits.
+func newServeMux(config *webhookConfig, pods podLister) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/mutate", serveAdmission((&mutator{config: config}).admit))
+	mux.Handle("/validate", serveAdmission((&validator{config: config, pods: pods}).admit))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	return mux
+}
+
+func main() {
+	flag.Parse()
+
+	glog.V(0).Info("Loading qt enclave admission webhook...")
+
+	if *tlsCertFile == "" || *tlsKeyFile == "" {
+		glog.Error("-tls-cert-file and -tls-key-file are required, the API server only calls webhooks over HTTPS")
+		os.Exit(1)
+	}
+	config, err := loadWebhookConfig(*configFile)
+	if err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+	var pods podLister
+	if config.Validation.countsNamespaces() {
+		kube, err := newKubeClient(*kubeServer, *kubeToken, *kubeCA)
+		if err != nil {
+			glog.Errorf("Namespace limits need the API server: %v", err)
+			os.Exit(1)
+		}
+		pods = kube
+	}
+	certs, err := newCertReloader(*tlsCertFile, *tlsKeyFile)
+	if err != nil {
+		glog.Errorf("Failed to load the serving certificate: %v", err)
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:              *listenAddr,
+		Handler:           newServeMux(config, pods),
+		TLSConfig:         &tls.Config{GetCertificate: certs.getCertificate, MinVersion: tls.VersionTLS12},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	glog.V(0).Infof("Serving on %s", *listenAddr)
+	if err := server.ListenAndServeTLS("", ""); err != nil {
+		glog.Error(err)
+		os.Exit(1)
+	}
+}
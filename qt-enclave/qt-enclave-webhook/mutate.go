@@ -0,0 +1,261 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the mutating webhook wiring pods up for enclaves
+ *********************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	annotationPrefix = "qt-enclave.huawei.com/"
+	// enabledAnnotation asks for the pod to be wired up for enclaves.
+	enabledAnnotation = annotationPrefix + "enabled"
+	// enclavesAnnotation is the number of enclaves of the pod.
+	enclavesAnnotation = annotationPrefix + "enclaves"
+	// containerAnnotation names the container running the enclaves,
+	// default the first one.
+	containerAnnotation = annotationPrefix + "container"
+	// injectedAnnotation marks the pods already wired up.
+	injectedAnnotation = annotationPrefix + "injected"
+
+	qlogVolumeName        = "qt-enclave-qlog"
+	exporterContainerName = "qt-enclave-exporter"
+	exporterLogFile       = "resource.log"
+)
+
+// patchOperation is a JSON patch operation.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// escapePointer escapes a JSON pointer token.
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// mutator adds the enclave resource, the qlog mount and the exporter
+// sidecar to the pods annotated with enabledAnnotation.
+type mutator struct {
+	config *webhookConfig
+}
+
+func (m *mutator) admit(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation != admissionv1.Create {
+		return allowed()
+	}
+	pod, err := decodePod(req)
+	if err != nil {
+		return denied(http.StatusBadRequest, "%v", err)
+	}
+
+	patch, err := m.mutate(req.Namespace, pod)
+	if err != nil {
+		glog.V(0).Infof("Rejected pod %s: %v", podName(req, pod), err)
+		return denied(http.StatusBadRequest, "%v", err)
+	}
+	if len(patch) == 0 {
+		return allowed()
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return denied(http.StatusInternalServerError, "failed to encode patch: %v", err)
+	}
+	glog.V(0).Infof("Wired up pod %s for enclaves", podName(req, pod))
+
+	patchType := admissionv1.PatchTypeJSONPatch
+	return &admissionv1.AdmissionResponse{Allowed: true, Patch: data, PatchType: &patchType}
+}
+
+// mutate returns the patch wiring pod up, none if the pod does not ask
+// for enclaves or is already wired up.
+func (m *mutator) mutate(namespace string, pod *corev1.Pod) ([]patchOperation, error) {
+	value, ok := pod.Annotations[enabledAnnotation]
+	if !ok || pod.Annotations[injectedAnnotation] == "true" {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation %q, expected true or false", enabledAnnotation, value)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	inj := m.config.injection(namespace)
+	if !inj.Enabled {
+		glog.V(1).Infof("Injection disabled in namespace %s", namespace)
+		return nil, nil
+	}
+
+	enclaves := inj.Enclaves
+	if value, ok := pod.Annotations[enclavesAnnotation]; ok {
+		if enclaves, err = strconv.Atoi(value); err != nil || enclaves < 1 {
+			return nil, fmt.Errorf("invalid %s annotation %q, expected a positive integer", enclavesAnnotation, value)
+		}
+	}
+	if len(pod.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("pod has no container")
+	}
+	target := 0
+	if name, ok := pod.Annotations[containerAnnotation]; ok {
+		target = -1
+		for i, c := range pod.Spec.Containers {
+			if c.Name == name {
+				target = i
+			}
+		}
+		if target < 0 {
+			return nil, fmt.Errorf("container %q of the %s annotation not found", name, containerAnnotation)
+		}
+	}
+
+	var patch []patchOperation
+	containerPath := fmt.Sprintf("/spec/containers/%d", target)
+	container := pod.Spec.Containers[target]
+
+	// Enclaves requested by the user in any container are kept, the
+	// validating webhook checks them. A request alone only gets the limit
+	// extended resources need.
+	resourceName := corev1.ResourceName(m.config.ResourceName)
+	_, limited := container.Resources.Limits[resourceName]
+	request, requested := container.Resources.Requests[resourceName]
+	if requested && !limited && request.Value() != int64(enclaves) {
+		return nil, fmt.Errorf("container %q requests %s %s, not the %d enclaves to inject", container.Name,
+			resourceName, request.String(), enclaves)
+	}
+	if !limited && (requested || !requestsResource(pod, resourceName)) {
+		resources := container.Resources.DeepCopy()
+		if resources.Limits == nil {
+			resources.Limits = corev1.ResourceList{}
+		}
+		resources.Limits[resourceName] = *resource.NewQuantity(int64(enclaves), resource.DecimalSI)
+		patch = append(patch, patchOperation{Op: "add", Path: containerPath + "/resources", Value: resources})
+	}
+
+	if inj.Qlog {
+		patch = append(patch, addVolume(pod, corev1.Volume{
+			Name: qlogVolumeName,
+			VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{
+				Path: inj.QlogHostPath,
+				Type: hostPathType(corev1.HostPathDirectoryOrCreate),
+			}},
+		})...)
+		patch = append(patch, addVolumeMount(container, containerPath, corev1.VolumeMount{
+			Name:      qlogVolumeName,
+			MountPath: inj.QlogMountPath,
+		})...)
+	}
+
+	if inj.Exporter && !hasContainer(pod, exporterContainerName) {
+		patch = append(patch, patchOperation{Op: "add", Path: "/spec/containers/-", Value: exporterContainer(inj)})
+	}
+
+	if pod.Annotations == nil {
+		patch = append(patch, patchOperation{Op: "add", Path: "/metadata/annotations",
+			Value: map[string]string{injectedAnnotation: "true"}})
+	} else {
+		patch = append(patch, patchOperation{Op: "add",
+			Path: "/metadata/annotations/" + escapePointer(injectedAnnotation), Value: "true"})
+	}
+	return patch, nil
+}
+
+func hostPathType(t corev1.HostPathType) *corev1.HostPathType {
+	return &t
+}
+
+func hasContainer(pod *corev1.Pod, name string) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// requestsResource tells whether a container of pod requests or limits name.
+func requestsResource(pod *corev1.Pod, name corev1.ResourceName) bool {
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, c := range containers {
+			_, limited := c.Resources.Limits[name]
+			_, requested := c.Resources.Requests[name]
+			if limited || requested {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addVolume adds a volume to the pod unless it has one of the same name.
+func addVolume(pod *corev1.Pod, volume corev1.Volume) []patchOperation {
+	for _, v := range pod.Spec.Volumes {
+		if v.Name == volume.Name {
+			return nil
+		}
+	}
+	if pod.Spec.Volumes == nil {
+		return []patchOperation{{Op: "add", Path: "/spec/volumes", Value: []corev1.Volume{volume}}}
+	}
+	return []patchOperation{{Op: "add", Path: "/spec/volumes/-", Value: volume}}
+}
+
+// addVolumeMount adds a mount to the container at path unless it mounts the
+// volume already.
+func addVolumeMount(container corev1.Container, path string, mount corev1.VolumeMount) []patchOperation {
+	for _, m := range container.VolumeMounts {
+		if m.Name == mount.Name {
+			return nil
+		}
+	}
+	if container.VolumeMounts == nil {
+		return []patchOperation{{Op: "add", Path: path + "/volumeMounts", Value: []corev1.VolumeMount{mount}}}
+	}
+	return []patchOperation{{Op: "add", Path: path + "/volumeMounts/-", Value: mount}}
+}
+
+// exporterContainer is the qt-enclave-exporter sidecar reading the qlog of
+// the pod.
+func exporterContainer(inj effectiveInjection) corev1.Container {
+	c := corev1.Container{
+		Name:  exporterContainerName,
+		Image: inj.ExporterImage,
+		Args:  []string{"--port", strconv.Itoa(inj.ExporterPort)},
+		Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: int32(inj.ExporterPort), Protocol: corev1.ProtocolTCP}},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("32Mi"),
+			},
+			Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+		},
+	}
+	if inj.Qlog {
+		c.Args = append(c.Args, "--log-file", strings.TrimSuffix(inj.QlogMountPath, "/")+"/"+exporterLogFile)
+		c.VolumeMounts = []corev1.VolumeMount{{Name: qlogVolumeName, MountPath: inj.QlogMountPath, ReadOnly: true}}
+	}
+	return c
+}
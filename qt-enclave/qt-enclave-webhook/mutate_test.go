@@ -0,0 +1,309 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the mutating webhook wiring pods up for enclaves testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func readTestPod(t *testing.T) *corev1.Pod {
+	data, err := os.ReadFile("testdata/pod.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pod corev1.Pod
+	if err := json.Unmarshal(data, &pod); err != nil {
+		t.Fatal(err)
+	}
+	return &pod
+}
+
+func newTestServer(t *testing.T, pods podLister) *httptest.Server {
+	config, err := loadWebhookConfig("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newHTTPTestServer(t, newServeMux(config, pods))
+}
+
+func newHTTPTestServer(t *testing.T, handler http.Handler) *httptest.Server {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// review posts an AdmissionReview creating pod in namespace to path.
+func review(t *testing.T, srv *httptest.Server, path, namespace string, pod *corev1.Pod) *admissionv1.AdmissionResponse {
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("uid-" + namespace),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Namespace: namespace,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	resp, err := http.Post(srv.URL+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out admissionv1.AdmissionReview
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.Response == nil {
+		t.Fatalf("Invalid AdmissionReview response (%s): %v", resp.Status, err)
+	}
+	if out.Response.UID != types.UID("uid-"+namespace) {
+		t.Fatalf("Response UID %q does not match the request", out.Response.UID)
+	}
+	return out.Response
+}
+
+// applyPatch applies the add operations of a JSON patch, the only ones the
+// webhook emits.
+func applyPatch(t *testing.T, pod *corev1.Pod, patch []byte) *corev1.Pod {
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(pod)
+	var doc interface{}
+	json.Unmarshal(data, &doc)
+	for _, op := range ops {
+		if op.Op != "add" {
+			t.Fatalf("Unexpected operation %+v", op)
+		}
+		tokens := strings.Split(op.Path, "/")[1:]
+		doc = addValue(t, doc, tokens, op.Value)
+	}
+	data, _ = json.Marshal(doc)
+	var patched corev1.Pod
+	if err := json.Unmarshal(data, &patched); err != nil {
+		t.Fatal(err)
+	}
+	return &patched
+}
+
+func addValue(t *testing.T, doc interface{}, tokens []string, value interface{}) interface{} {
+	token := strings.ReplaceAll(strings.ReplaceAll(tokens[0], "~1", "/"), "~0", "~")
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if len(tokens) == 1 {
+			node[token] = value
+		} else {
+			child, ok := node[token]
+			if !ok {
+				t.Fatalf("Path %s does not exist", strings.Join(tokens, "/"))
+			}
+			node[token] = addValue(t, child, tokens[1:], value)
+		}
+		return node
+	case []interface{}:
+		if len(tokens) == 1 && token == "-" {
+			return append(node, value)
+		}
+		i, err := strconv.Atoi(token)
+		if err != nil || i >= len(node) || len(tokens) == 1 {
+			t.Fatalf("Unsupported array path %s", strings.Join(tokens, "/"))
+		}
+		node[i] = addValue(t, node[i], tokens[1:], value)
+		return node
+	}
+	t.Fatalf("Path %s does not exist", strings.Join(tokens, "/"))
+	return nil
+}
+
+func mutated(t *testing.T, srv *httptest.Server, namespace string, pod *corev1.Pod) *corev1.Pod {
+	resp := review(t, srv, "/mutate", namespace, pod)
+	if !resp.Allowed || resp.PatchType == nil || *resp.PatchType != admissionv1.PatchTypeJSONPatch {
+		t.Fatalf("Expected an allowed JSON patch, got %+v", resp)
+	}
+	return applyPatch(t, pod, resp.Patch)
+}
+
+func TestMutateDefaults(t *testing.T) {
+	srv := newTestServer(t, nil)
+	pod := mutated(t, srv, "default", readTestPod(t))
+
+	app := pod.Spec.Containers[0]
+	if q := app.Resources.Limits[defaultResourceName]; q.Value() != 1 {
+		t.Fatalf("Expected 1 enclave, got %v", app.Resources.Limits)
+	}
+	if _, ok := app.Resources.Requests[defaultResourceName]; ok || app.Resources.Requests.Cpu().String() != "1" {
+		t.Fatalf("Requests should be kept, got %v", app.Resources.Requests)
+	}
+	if len(pod.Spec.Volumes) != 1 || pod.Spec.Volumes[0].HostPath == nil || pod.Spec.Volumes[0].HostPath.Path != "/var/log/qlog" {
+		t.Fatalf("Unexpected volumes %+v", pod.Spec.Volumes)
+	}
+	if len(app.VolumeMounts) != 1 || app.VolumeMounts[0].Name != qlogVolumeName || app.VolumeMounts[0].ReadOnly {
+		t.Fatalf("Unexpected mounts %+v", app.VolumeMounts)
+	}
+	if len(pod.Spec.Containers) != 1 {
+		t.Fatal("Exporter should not be injected by default")
+	}
+	if pod.Annotations[injectedAnnotation] != "true" || pod.Annotations[enabledAnnotation] != "true" {
+		t.Fatalf("Unexpected annotations %v", pod.Annotations)
+	}
+
+	// The patched pod is left alone, as when the API server reinvokes the
+	// webhook.
+	if resp := review(t, srv, "/mutate", "default", pod); !resp.Allowed || resp.Patch != nil {
+		t.Fatalf("Wired up pod should not be patched again, got %+v", resp)
+	}
+}
+
+func TestMutateNamespaceConfig(t *testing.T) {
+	srv := newTestServer(t, nil)
+	pod := mutated(t, srv, "monitoring", readTestPod(t))
+
+	if q := pod.Spec.Containers[0].Resources.Limits[defaultResourceName]; q.Value() != 2 {
+		t.Fatalf("Expected the 2 enclaves of the namespace, got %v", q.String())
+	}
+	if len(pod.Spec.Containers) != 2 {
+		t.Fatalf("Expected the exporter sidecar, got %d containers", len(pod.Spec.Containers))
+	}
+	exporter := pod.Spec.Containers[1]
+	if exporter.Name != exporterContainerName || exporter.Image != "registry.example.com/qt-enclave-exporter:1.0.0" ||
+		strings.Join(exporter.Args, " ") != "--port 9113 --log-file /var/log/qlog/resource.log" {
+		t.Fatalf("Unexpected exporter %+v", exporter)
+	}
+	if len(exporter.VolumeMounts) != 1 || !exporter.VolumeMounts[0].ReadOnly {
+		t.Fatalf("Exporter should mount the qlog read-only, got %+v", exporter.VolumeMounts)
+	}
+
+	pod = mutated(t, srv, "batch", readTestPod(t))
+	if len(pod.Spec.Volumes) != 0 || len(pod.Spec.Containers[0].VolumeMounts) != 0 {
+		t.Fatal("Qlog should not be mounted when disabled in the namespace")
+	}
+
+	if resp := review(t, srv, "/mutate", "untrusted", readTestPod(t)); !resp.Allowed || resp.Patch != nil {
+		t.Fatalf("Pods of a disabled namespace should not be patched, got %+v", resp)
+	}
+}
+
+func TestMutateAnnotations(t *testing.T) {
+	srv := newTestServer(t, nil)
+	pod := readTestPod(t)
+	pod.Spec.Containers = append([]corev1.Container{{Name: "init-proxy", Image: "proxy"}}, pod.Spec.Containers...)
+	pod.Spec.Volumes = []corev1.Volume{{Name: "data"}}
+	pod.Annotations[enclavesAnnotation] = "3"
+	pod.Annotations[containerAnnotation] = "app"
+
+	pod = mutated(t, srv, "default", pod)
+	if _, ok := pod.Spec.Containers[0].Resources.Limits[defaultResourceName]; ok {
+		t.Fatal("Only the annotated container should get enclaves")
+	}
+	if q := pod.Spec.Containers[1].Resources.Limits[defaultResourceName]; q.Value() != 3 {
+		t.Fatalf("Expected 3 enclaves, got %v", q.String())
+	}
+	if len(pod.Spec.Volumes) != 2 || pod.Spec.Volumes[1].Name != qlogVolumeName {
+		t.Fatalf("Qlog volume should be appended, got %+v", pod.Spec.Volumes)
+	}
+
+	for _, tc := range []struct {
+		annotation, value, message string
+	}{
+		{enclavesAnnotation, "0", "expected a positive integer"},
+		{enclavesAnnotation, "1.5", "expected a positive integer"},
+		{containerAnnotation, "missing", `container "missing"`},
+		{enabledAnnotation, "yes please", "expected true or false"},
+	} {
+		pod := readTestPod(t)
+		pod.Annotations[tc.annotation] = tc.value
+		resp := review(t, srv, "/mutate", "default", pod)
+		if resp.Allowed || resp.Result == nil || !strings.Contains(resp.Result.Message, tc.message) {
+			t.Fatalf("%s=%q should be denied with %q, got %+v", tc.annotation, tc.value, tc.message, resp)
+		}
+	}
+}
+
+func TestMutateKeepsLimit(t *testing.T) {
+	srv := newTestServer(t, nil)
+	pod := readTestPod(t)
+	pod.Spec.Containers[0].Resources.Limits = corev1.ResourceList{defaultResourceName: resource.MustParse("4")}
+	pod = mutated(t, srv, "default", pod)
+	if q := pod.Spec.Containers[0].Resources.Limits[defaultResourceName]; q.Value() != 4 {
+		t.Fatalf("Limit set by the user should be kept, got %v", q.String())
+	}
+
+	// Enclaves of another container are not requested again.
+	pod = readTestPod(t)
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "sidecar", Image: "sidecar",
+		Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{defaultResourceName: resource.MustParse("1")}}})
+	pod = mutated(t, srv, "default", pod)
+	if _, ok := pod.Spec.Containers[0].Resources.Limits[defaultResourceName]; ok {
+		t.Fatalf("Enclaves of the sidecar should not be requested again, got %v", pod.Spec.Containers[0].Resources.Limits)
+	}
+
+	// A request alone gets its limit, unless it conflicts with the injection.
+	pod = readTestPod(t)
+	pod.Spec.Containers[0].Resources.Requests[defaultResourceName] = resource.MustParse("1")
+	pod = mutated(t, srv, "default", pod)
+	if q := pod.Spec.Containers[0].Resources.Limits[defaultResourceName]; q.Value() != 1 {
+		t.Fatalf("Request alone should get its limit, got %v", pod.Spec.Containers[0].Resources.Limits)
+	}
+	pod = readTestPod(t)
+	pod.Spec.Containers[0].Resources.Requests[defaultResourceName] = resource.MustParse("2")
+	if resp := review(t, srv, "/mutate", "default", pod); resp.Allowed || !strings.Contains(resp.Result.Message, "not the 1 enclaves") {
+		t.Fatalf("Conflicting request should be denied, got %+v", resp.Result)
+	}
+
+	pod = readTestPod(t)
+	delete(pod.Annotations, enabledAnnotation)
+	if resp := review(t, srv, "/mutate", "default", pod); !resp.Allowed || resp.Patch != nil {
+		t.Fatalf("Pods without the annotation should not be patched, got %+v", resp)
+	}
+}
+
+func TestServeAdmission(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	resp, _ := http.Get(srv.URL + "/mutate")
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405, got %s", resp.Status)
+	}
+	resp, _ = http.Post(srv.URL+"/mutate", "text/plain", strings.NewReader("{}"))
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("Expected 415, got %s", resp.Status)
+	}
+	body := `{"apiVersion":"admission.k8s.io/v1beta1","kind":"AdmissionReview","request":{"uid":"1"}}`
+	resp, _ = http.Post(srv.URL+"/mutate", "application/json", strings.NewReader(body))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for v1beta1, got %s", resp.Status)
+	}
+	resp, _ = http.Get(srv.URL + "/healthz")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected healthz 200, got %s", resp.Status)
+	}
+}
@@ -0,0 +1,166 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the validating webhook of the enclave requests
+ *********************************************************************************/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// validator denies the pods requesting enclaves against the rules of the
+// configuration.
+type validator struct {
+	config *webhookConfig
+	// pods counts the enclaves of the namespaces, nil without namespace
+	// limits.
+	pods podLister
+}
+
+func (v *validator) admit(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation != admissionv1.Create {
+		return allowed()
+	}
+	pod, err := decodePod(req)
+	if err != nil {
+		return denied(http.StatusBadRequest, "%v", err)
+	}
+
+	violations, err := v.validate(context.Background(), req.Namespace, pod)
+	if err != nil {
+		glog.Errorf("Failed to validate pod %s: %v", podName(req, pod), err)
+		return denied(http.StatusInternalServerError, "failed to validate the enclave request: %v", err)
+	}
+	if len(violations) > 0 {
+		glog.V(0).Infof("Denied pod %s: %s", podName(req, pod), strings.Join(violations, "; "))
+		return denied(http.StatusForbidden, "%s violates the enclave policy: %s",
+			podName(req, pod), strings.Join(violations, "; "))
+	}
+	return allowed()
+}
+
+// podEnclaves returns the enclaves a pod holds, as the scheduler counts
+// them, and the containers requesting invalid amounts.
+func podEnclaves(pod *corev1.Pod, name corev1.ResourceName) (int64, []string) {
+	var violations []string
+	amount := func(c corev1.Container) int64 {
+		q, ok := c.Resources.Limits[name]
+		if !ok {
+			if q, ok = c.Resources.Requests[name]; !ok {
+				return 0
+			}
+		}
+		if q.Sign() <= 0 || q.MilliValue()%1000 != 0 {
+			violations = append(violations, fmt.Sprintf("container %q requests %s %s, expected a positive whole number",
+				c.Name, q.String(), name))
+			return 0
+		}
+		return q.Value()
+	}
+
+	var sum, init int64
+	for _, c := range pod.Spec.Containers {
+		sum += amount(c)
+	}
+	// Init containers run one after the other before the containers.
+	for _, c := range pod.Spec.InitContainers {
+		if n := amount(c); n > init {
+			init = n
+		}
+	}
+	if init > sum {
+		sum = init
+	}
+	return sum, violations
+}
+
+// validate returns the rules pod violates.
+func (v *validator) validate(ctx context.Context, namespace string, pod *corev1.Pod) ([]string, error) {
+	rules := &v.config.Validation
+	resourceName := corev1.ResourceName(v.config.ResourceName)
+	enclaves, violations := podEnclaves(pod, resourceName)
+	if enclaves == 0 && len(violations) == 0 {
+		return nil, nil
+	}
+
+	if len(rules.AllowedNamespaces) > 0 && !containsString(rules.AllowedNamespaces, namespace) {
+		violations = append(violations, fmt.Sprintf("namespace %s is not allowed to use %s", namespace, resourceName))
+	}
+	if max := rules.MaxEnclavesPerPod; max > 0 && enclaves > int64(max) {
+		violations = append(violations, fmt.Sprintf("requests %d %s, more than the %d allowed per pod",
+			enclaves, resourceName, max))
+	}
+	if !rules.AllowPrivileged {
+		for _, c := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+			if c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged {
+				violations = append(violations, fmt.Sprintf("container %q is privileged, privileged pods may not use enclaves", c.Name))
+			}
+		}
+	}
+
+	names := make([]string, 0, len(rules.RequiredAnnotations))
+	for name := range rules.RequiredAnnotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, ok := pod.Annotations[name]
+		switch {
+		case !ok || value == "":
+			violations = append(violations, fmt.Sprintf("missing annotation %s", name))
+		case !rules.patterns[name].MatchString(value):
+			violations = append(violations, fmt.Sprintf("annotation %s=%q does not match %s",
+				name, value, rules.RequiredAnnotations[name]))
+		}
+	}
+
+	// The pods of the namespace are only listed for otherwise valid pods.
+	limit := rules.namespaceLimit(namespace)
+	if len(violations) > 0 || limit <= 0 || v.pods == nil {
+		return violations, nil
+	}
+	pods, err := v.pods.listPods(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count the enclaves of namespace %s: %v", namespace, err)
+	}
+	var used int64
+	for i := range pods {
+		if p := &pods[i]; p.Status.Phase != corev1.PodSucceeded && p.Status.Phase != corev1.PodFailed {
+			n, _ := podEnclaves(p, resourceName)
+			used += n
+		}
+	}
+	if used+enclaves > int64(limit) {
+		violations = append(violations, fmt.Sprintf("namespace %s already uses %d of its %d %s, the pod requests %d more",
+			namespace, used, limit, resourceName, enclaves))
+	}
+	return violations, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
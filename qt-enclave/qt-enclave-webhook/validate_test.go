@@ -0,0 +1,191 @@
+/******************************************************************************
+ * Copyright (c) Huawei Technologies Co., Ltd. 2026. All rights reserved.
+ * iSulad licensed under the Mulan PSL v2.
+ * You can use this software according to the terms and conditions of the Mulan PSL v2.
+ * You may obtain a copy of Mulan PSL v2 at:
+ *     http://license.coscl.org.cn/MulanPSL2
+ * THIS SOFTWARE IS PROVIDED ON AN "AS IS" BASIS, WITHOUT WARRANTIES OF ANY KIND, EITHER EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO NON-INFRINGEMENT, MERCHANTABILITY OR FIT FOR A PARTICULAR
+ * PURPOSE.
+ * See the Mulan PSL v2 for more details.
+ * Author: liuxu
+ * Create: 2026-10-19
+ * Description: provide the validating webhook of the enclave requests testcase
+ *********************************************************************************/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testMeasurement = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+type fakePods struct {
+	pods  []corev1.Pod
+	err   error
+	calls int
+}
+
+func (f *fakePods) listPods(ctx context.Context, namespace string) ([]corev1.Pod, error) {
+	f.calls++
+	return f.pods, f.err
+}
+
+// enclavePod returns a pod whose app container requests amount enclaves.
+func enclavePod(amount string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "app",
+			Annotations: map[string]string{"qt-enclave.huawei.com/measurement": testMeasurement},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:      "app",
+			Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{defaultResourceName: resource.MustParse(amount)}},
+		}}},
+	}
+}
+
+func TestValidateAllows(t *testing.T) {
+	pods := &fakePods{}
+	srv := newTestServer(t, pods)
+
+	if resp := review(t, srv, "/validate", "default", enclavePod("2")); !resp.Allowed {
+		t.Fatalf("Pod within the rules should be allowed, got %+v", resp.Result)
+	}
+	if pods.calls != 1 {
+		t.Fatalf("Namespace pods should be listed once, got %d", pods.calls)
+	}
+
+	// Pods without enclaves are not checked.
+	pod := enclavePod("1")
+	pod.Spec.Containers[0].Resources = corev1.ResourceRequirements{}
+	pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{Privileged: boolPtr(true)}
+	if resp := review(t, srv, "/validate", "other", pod); !resp.Allowed {
+		t.Fatalf("Pod without enclaves should be allowed, got %+v", resp.Result)
+	}
+}
+
+func TestValidateDenies(t *testing.T) {
+	pods := &fakePods{}
+	srv := newTestServer(t, pods)
+
+	for _, tc := range []struct {
+		name      string
+		namespace string
+		change    func(*corev1.Pod)
+		message   string
+	}{
+		{"fractional", "default", func(p *corev1.Pod) {
+			p.Spec.Containers[0].Resources.Limits[defaultResourceName] = resource.MustParse("500m")
+		}, `container "app" requests 500m huawei.com/qt_enclaves, expected a positive whole number`},
+		{"zero", "default", func(p *corev1.Pod) {
+			p.Spec.Containers[0].Resources.Limits[defaultResourceName] = resource.MustParse("0")
+		}, "expected a positive whole number"},
+		{"namespace", "other", func(p *corev1.Pod) {}, "namespace other is not allowed to use huawei.com/qt_enclaves"},
+		{"per pod", "default", func(p *corev1.Pod) {
+			p.Spec.Containers = append(p.Spec.Containers, *p.Spec.Containers[0].DeepCopy())
+			p.Spec.Containers[1].Name = "second"
+			p.Spec.Containers[1].Resources.Limits[defaultResourceName] = resource.MustParse("4")
+		}, "requests 5 huawei.com/qt_enclaves, more than the 4 allowed per pod"},
+		{"privileged", "default", func(p *corev1.Pod) {
+			p.Spec.InitContainers = []corev1.Container{{Name: "setup", SecurityContext: &corev1.SecurityContext{Privileged: boolPtr(true)}}}
+		}, `container "setup" is privileged, privileged pods may not use enclaves`},
+		{"missing annotation", "default", func(p *corev1.Pod) {
+			p.Annotations = nil
+		}, "missing annotation qt-enclave.huawei.com/measurement"},
+		{"invalid annotation", "default", func(p *corev1.Pod) {
+			p.Annotations["qt-enclave.huawei.com/measurement"] = "abc"
+		}, `annotation qt-enclave.huawei.com/measurement="abc" does not match [0-9a-f]{64}`},
+	} {
+		pod := enclavePod("1")
+		tc.change(pod)
+		resp := review(t, srv, "/validate", tc.namespace, pod)
+		if resp.Allowed || resp.Result.Code != http.StatusForbidden || !strings.Contains(resp.Result.Message, tc.message) {
+			t.Fatalf("%s: expected a denial with %q, got %+v", tc.name, tc.message, resp.Result)
+		}
+		if !strings.HasPrefix(resp.Result.Message, tc.namespace+"/app violates the enclave policy: ") {
+			t.Fatalf("%s: denial should name the pod, got %q", tc.name, resp.Result.Message)
+		}
+	}
+	if pods.calls != 0 {
+		t.Fatalf("Namespace pods should not be listed for invalid pods, got %d calls", pods.calls)
+	}
+}
+
+func TestValidateNamespaceLimit(t *testing.T) {
+	running := *enclavePod("2")
+	initOnly := *enclavePod("1")
+	initOnly.Spec.InitContainers = []corev1.Container{{
+		Name:      "init",
+		Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{defaultResourceName: resource.MustParse("2")}},
+	}}
+	done := *enclavePod("4")
+	done.Status.Phase = corev1.PodSucceeded
+	pods := &fakePods{pods: []corev1.Pod{running, initOnly, done}}
+	srv := newTestServer(t, pods)
+
+	if resp := review(t, srv, "/validate", "default", enclavePod("2")); !resp.Allowed {
+		t.Fatalf("Pod filling the namespace limit should be allowed, got %+v", resp.Result)
+	}
+	resp := review(t, srv, "/validate", "default", enclavePod("3"))
+	if resp.Allowed || !strings.Contains(resp.Result.Message, "namespace default already uses 4 of its 6 huawei.com/qt_enclaves, the pod requests 3 more") {
+		t.Fatalf("Pod over the namespace limit should be denied, got %+v", resp.Result)
+	}
+
+	// The namespace override replaces the default limit.
+	pods.pods = nil
+	resp = review(t, srv, "/validate", "monitoring", enclavePod("3"))
+	if resp.Allowed || !strings.Contains(resp.Result.Message, "already uses 0 of its 2") {
+		t.Fatalf("Namespace override should apply, got %+v", resp.Result)
+	}
+
+	pods.err = errors.New("connection refused")
+	resp = review(t, srv, "/validate", "default", enclavePod("1"))
+	if resp.Allowed || resp.Result.Code != http.StatusInternalServerError || !strings.Contains(resp.Result.Message, "connection refused") {
+		t.Fatalf("Failed count should deny the pod, got %+v", resp.Result)
+	}
+}
+
+func TestKubeClientListPods(t *testing.T) {
+	token := writeTestConfig(t, "secret-token\n")
+	api := http.NewServeMux()
+	api.HandleFunc("/api/v1/namespaces/default/pods", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"apiVersion":"v1","kind":"Status","status":"Failure","message":"pods is forbidden","code":403}`))
+			return
+		}
+		w.Write([]byte(`{"apiVersion":"v1","kind":"PodList","items":[{"metadata":{"name":"a"}},{"metadata":{"name":"b"}}]}`))
+	})
+	srv := newHTTPTestServer(t, api)
+
+	client, err := newKubeClient(srv.URL, token, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods, err := client.listPods(context.Background(), "default")
+	if err != nil || len(pods) != 2 || pods[1].Name != "b" {
+		t.Fatalf("Unexpected pods %v: %v", pods, err)
+	}
+
+	client, err = newKubeClient(srv.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.listPods(context.Background(), "default"); err == nil || !strings.Contains(err.Error(), "pods is forbidden") {
+		t.Fatalf("Expected the API server message, got %v", err)
+	}
+	if _, err := newKubeClient("ftp://api", "", ""); err == nil {
+		t.Fatal("Invalid API server URL should fail")
+	}
+}